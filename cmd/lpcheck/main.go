@@ -0,0 +1,147 @@
+// Command lpcheck evaluates a JSON permission tree offline in order to
+// reproduce access decisions.
+//
+// Usage:
+//
+//	lpcheck -policy policy.json [-context context.json] [-data types.json] [-bypass] [-no-bypass] [-trace] [-json]
+//
+// The data file registers stub permission types. Each key is a type name and
+// each value lists the permissions that are granted for that type, e.g.
+// {"role": ["admin"], "flag": ["beta"]}. The context file is passed unchanged
+// to the type callbacks.
+//
+// The exit status is 0 if access is granted, 1 if access is denied and 2 if an
+// error occurred.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/ordermind/logical-permissions-go"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+type result struct {
+	Access bool                      `json:"access"`
+	Error  string                    `json:"error,omitempty"`
+	Trace  *logicalpermissions.Trace `json:"trace,omitempty"`
+}
+
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("lpcheck", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	policy_file := flags.String("policy", "", "JSON file containing the permission tree")
+	context_file := flags.String("context", "", "JSON file containing the context object")
+	data_file := flags.String("data", "", "JSON file mapping permission types to granted permissions")
+	bypass := flags.Bool("bypass", false, "register a bypass callback that grants bypass access")
+	no_bypass := flags.Bool("no-bypass", false, "check access while explicitly disallowing access bypass")
+	show_trace := flags.Bool("trace", false, "print an explanation of the decision")
+	output_json := flags.Bool("json", false, "print the result as JSON")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *policy_file == "" || flags.NArg() > 0 {
+		fmt.Fprintln(stderr, "usage: lpcheck -policy policy.json [-context context.json] [-data types.json] [-bypass] [-no-bypass] [-trace] [-json]")
+		return 2
+	}
+
+	policy, err := ioutil.ReadFile(*policy_file)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	context := make(map[string]interface{})
+	if *context_file != "" {
+		if err := readJSONFile(*context_file, &context); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+	}
+	data := make(map[string][]string)
+	if *data_file != "" {
+		if err := readJSONFile(*data_file, &data); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+	}
+
+	lp := logicalpermissions.LogicalPermissions{}
+	for name, granted := range data {
+		if err := lp.AddType(name, newStubCallback(granted)); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+	}
+	if *bypass {
+		lp.SetBypassCallback(func(map[string]interface{}) (bool, error) { return true, nil })
+	}
+
+	var access bool
+	var trace *logicalpermissions.Trace
+	if *no_bypass {
+		access, trace, err = lp.CheckAccessNoBypassWithTrace(string(policy), context)
+	} else {
+		access, trace, err = lp.CheckAccessWithTrace(string(policy), context)
+	}
+
+	if *output_json {
+		output := result{Access: access}
+		if err != nil {
+			output.Error = err.Error()
+		}
+		if *show_trace {
+			output.Trace = trace
+		}
+		encoded, _ := json.MarshalIndent(output, "", "  ")
+		fmt.Fprintf(stdout, "%s\n", encoded)
+	} else {
+		if err != nil {
+			fmt.Fprintf(stdout, "error: %s\n", err)
+		} else if access {
+			fmt.Fprintln(stdout, "access granted")
+		} else {
+			fmt.Fprintln(stdout, "access denied")
+		}
+		if *show_trace {
+			fmt.Fprint(stdout, trace.String())
+		}
+	}
+
+	if err != nil {
+		return 2
+	}
+	if !access {
+		return 1
+	}
+	return 0
+}
+
+func readJSONFile(filename string, value interface{}) error {
+	contents, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(contents, value); err != nil {
+		return fmt.Errorf("Error parsing %s: %s", filename, err)
+	}
+	return nil
+}
+
+func newStubCallback(granted []string) func(string, map[string]interface{}) (bool, error) {
+	return func(permission string, context map[string]interface{}) (bool, error) {
+		for _, value := range granted {
+			if value == permission {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTempFile(t *testing.T, dir string, name string, contents string) string {
+	filename := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(filename, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lpcheck")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	policy := writeTempFile(t, dir, "policy.json", `{"AND": {"role": "admin", "flag": {"NOT": "beta"}}}`)
+	data := writeTempFile(t, dir, "data.json", `{"role": ["admin"], "flag": ["beta"]}`)
+
+	var stdout, stderr bytes.Buffer
+	status := run([]string{"-policy", policy, "-data", data}, &stdout, &stderr)
+	assert.Equal(t, 1, status)
+	assert.Equal(t, "access denied\n", stdout.String())
+
+	stdout.Reset()
+	status = run([]string{"-policy", policy, "-data", data, "-bypass"}, &stdout, &stderr)
+	assert.Equal(t, 0, status)
+	assert.Equal(t, "access granted\n", stdout.String())
+
+	stdout.Reset()
+	status = run([]string{"-policy", policy, "-data", data, "-bypass", "-no-bypass", "-trace", "-json"}, &stdout, &stderr)
+	assert.Equal(t, 1, status)
+	output := make(map[string]interface{})
+	assert.Nil(t, json.Unmarshal(stdout.Bytes(), &output))
+	assert.Equal(t, false, output["access"])
+	trace := output["trace"].(map[string]interface{})
+	assert.Equal(t, false, trace["bypass_checked"])
+	assert.NotNil(t, trace["root"])
+}
+
+func TestRunErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lpcheck")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	policy := writeTempFile(t, dir, "policy.json", `{"role": "admin"}`)
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 2, run([]string{}, &stdout, &stderr))
+
+	status := run([]string{"-policy", policy}, &stdout, &stderr)
+	assert.Equal(t, 2, status)
+	assert.Contains(t, stdout.String(), "has not been registered")
+}
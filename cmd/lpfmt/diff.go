@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+const diffContext = 3
+
+// unifiedDiff returns the differences between two texts in unified format.
+func unifiedDiff(filename string, a string, b string) string {
+	lines_a := splitLines(a)
+	lines_b := splitLines(b)
+
+	// Longest common subsequence table.
+	lcs := make([][]int, len(lines_a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(lines_b)+1)
+	}
+	for i := len(lines_a) - 1; i >= 0; i-- {
+		for j := len(lines_b) - 1; j >= 0; j-- {
+			if lines_a[i] == lines_b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	type edit struct {
+		op   byte
+		line string
+		a    int
+		b    int
+	}
+	edits := []edit{}
+	i, j := 0, 0
+	for i < len(lines_a) || j < len(lines_b) {
+		if i < len(lines_a) && j < len(lines_b) && lines_a[i] == lines_b[j] {
+			edits = append(edits, edit{' ', lines_a[i], i, j})
+			i++
+			j++
+		} else if i < len(lines_a) && (j == len(lines_b) || lcs[i+1][j] >= lcs[i][j+1]) {
+			edits = append(edits, edit{'-', lines_a[i], i, j})
+			i++
+		} else {
+			edits = append(edits, edit{'+', lines_b[j], i, j})
+			j++
+		}
+	}
+
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "--- %s.orig\n+++ %s\n", filename, filename)
+	for start := 0; start < len(edits); {
+		if edits[start].op == ' ' {
+			start++
+			continue
+		}
+		// Extend the hunk while changes are at most twice the context lines apart.
+		hunk_start := start - diffContext
+		if hunk_start < 0 {
+			hunk_start = 0
+		}
+		end := start
+		for unchanged := 0; end < len(edits) && unchanged <= 2*diffContext; end++ {
+			if edits[end].op == ' ' {
+				unchanged++
+			} else {
+				unchanged = 0
+			}
+		}
+		for end > start && edits[end-1].op == ' ' {
+			end--
+		}
+		hunk_end := end + diffContext
+		if hunk_end > len(edits) {
+			hunk_end = len(edits)
+		}
+
+		count_a, count_b := 0, 0
+		for _, e := range edits[hunk_start:hunk_end] {
+			if e.op != '+' {
+				count_a++
+			}
+			if e.op != '-' {
+				count_b++
+			}
+		}
+		fmt.Fprintf(&buffer, "@@ -%d,%d +%d,%d @@\n", edits[hunk_start].a+1, count_a, edits[hunk_start].b+1, count_b)
+		for _, e := range edits[hunk_start:hunk_end] {
+			fmt.Fprintf(&buffer, "%c%s\n", e.op, e.line)
+		}
+		start = hunk_end
+	}
+	return buffer.String()
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
@@ -0,0 +1,98 @@
+// Command lpfmt rewrites JSON permission trees in canonical form.
+//
+// Usage:
+//
+//	lpfmt [-l] [-d] [-w] [file...]
+//
+// Without files, lpfmt formats standard input. By default the formatted
+// permission trees are printed to standard output.
+//
+//	-l	list files whose formatting differs from lpfmt's
+//	-d	display diffs instead of rewriting files
+//	-w	write the result to the source file instead of standard output
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/ordermind/logical-permissions-go"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("lpfmt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	list := flags.Bool("l", false, "list files whose formatting differs from lpfmt's")
+	diff := flags.Bool("d", false, "display diffs instead of rewriting files")
+	write := flags.Bool("w", false, "write the result to the source file instead of standard output")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	lp := logicalpermissions.LogicalPermissions{}
+	if flags.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(stderr, "lpfmt: cannot use -w with standard input")
+			return 2
+		}
+		contents, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		if err := processFile(&lp, "<standard input>", contents, *list, *diff, false, stdout); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		return 0
+	}
+
+	status := 0
+	for _, filename := range flags.Args() {
+		contents, err := ioutil.ReadFile(filename)
+		if err == nil {
+			err = processFile(&lp, filename, contents, *list, *diff, *write, stdout)
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			status = 2
+		}
+	}
+	return status
+}
+
+func processFile(lp *logicalpermissions.LogicalPermissions, filename string, contents []byte, list bool, diff bool, write bool, stdout io.Writer) error {
+	formatted, err := lp.Format(string(contents))
+	if err != nil {
+		return fmt.Errorf("%s: %s", filename, err)
+	}
+	changed := !bytes.Equal(contents, []byte(formatted))
+
+	if list && changed {
+		fmt.Fprintln(stdout, filename)
+	}
+	if diff && changed {
+		fmt.Fprint(stdout, unifiedDiff(filename, string(contents), formatted))
+	}
+	if write && changed {
+		info, err := os.Stat(filename)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filename, []byte(formatted), info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	if !list && !diff && !write {
+		fmt.Fprint(stdout, formatted)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunStdin(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	status := run([]string{}, strings.NewReader(`{"no_bypass": true, "role": {"or": ["a", "b"]}}`), &stdout, &stderr)
+	assert.Equal(t, 0, status)
+	assert.Equal(t, "{\n  \"NO_BYPASS\": true,\n  \"role\": {\n    \"OR\": [\n      \"a\",\n      \"b\"\n    ]\n  }\n}\n", stdout.String())
+
+	stdout.Reset()
+	status = run([]string{}, strings.NewReader(`{"role": `), &stdout, &stderr)
+	assert.Equal(t, 2, status)
+}
+
+func TestRunFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lpfmt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	formatted := filepath.Join(dir, "formatted.json")
+	if err := ioutil.WriteFile(formatted, []byte("{\n  \"role\": \"admin\"\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	unformatted := filepath.Join(dir, "unformatted.json")
+	if err := ioutil.WriteFile(unformatted, []byte("{\"role\": \"admin\", \"and\": [true]}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 0, run([]string{"-l", formatted, unformatted}, nil, &stdout, &stderr))
+	assert.Equal(t, unformatted+"\n", stdout.String())
+
+	stdout.Reset()
+	assert.Equal(t, 0, run([]string{"-d", formatted, unformatted}, nil, &stdout, &stderr))
+	expected := "--- " + unformatted + ".orig\n" +
+		"+++ " + unformatted + "\n" +
+		"@@ -1,1 +1,6 @@\n" +
+		"-{\"role\": \"admin\", \"and\": [true]}\n" +
+		"+{\n" +
+		"+  \"AND\": [\n" +
+		"+    true\n" +
+		"+  ],\n" +
+		"+  \"role\": \"admin\"\n" +
+		"+}\n"
+	assert.Equal(t, expected, stdout.String())
+
+	stdout.Reset()
+	assert.Equal(t, 0, run([]string{"-w", formatted, unformatted}, nil, &stdout, &stderr))
+	assert.Equal(t, "", stdout.String())
+	contents, err := ioutil.ReadFile(unformatted)
+	assert.Nil(t, err)
+	assert.Equal(t, "{\n  \"AND\": [\n    true\n  ],\n  \"role\": \"admin\"\n}\n", string(contents))
+}
+
+func TestUnifiedDiffContext(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n"
+	b := "1\n2\n3\nfour\n5\n6\n7\n8\n9\n10\n11\n12\nthirteen\n"
+	expected := "--- f.orig\n+++ f\n" +
+		"@@ -1,7 +1,7 @@\n 1\n 2\n 3\n-4\n+four\n 5\n 6\n 7\n" +
+		"@@ -10,4 +10,4 @@\n 10\n 11\n 12\n-13\n+thirteen\n"
+	assert.Equal(t, expected, unifiedDiff("f", a, b))
+
+	a = "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	b = "1\n2\n3\n4\nfive\n6\n7\n8\n9\n10\n11\ntwelve\n"
+	expected = "--- f.orig\n+++ f\n" +
+		"@@ -2,11 +2,11 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n 9\n 10\n 11\n-12\n+twelve\n"
+	assert.Equal(t, expected, unifiedDiff("f", a, b))
+}
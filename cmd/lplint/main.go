@@ -0,0 +1,111 @@
+// Command lplint reports problems in JSON permission trees.
+//
+// Usage:
+//
+//	lplint [-types role,flag] [-bypasses name,...] [-format text|json|sarif] file...
+//
+// The types flag lists the registered permission types and the bypasses flag
+// lists the registered named bypasses. Diagnostics are printed
+// as text by default, or as JSON or SARIF 2.1.0 for consumption by other tools.
+//
+// The exit status is 0 if no diagnostics were reported, 1 if diagnostics were
+// reported and 2 if an error occurred.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/ordermind/logical-permissions-go"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+type fileDiagnostic struct {
+	File string `json:"file"`
+	logicalpermissions.Diagnostic
+}
+
+var ruleDescriptions = map[string]string{
+	logicalpermissions.LintRuleInvalidPermissions:   "The permission tree cannot be evaluated.",
+	logicalpermissions.LintRuleXORConstantChild:     "An XOR gate has a constant child.",
+	logicalpermissions.LintRuleDoubleNegation:       "A NOT gate directly contains another NOT gate.",
+	logicalpermissions.LintRuleDuplicateORChild:     "An OR gate contains duplicate children.",
+	logicalpermissions.LintRuleUnreachableAfterTrue: "A child of an OR gate can never affect the result because the gate contains TRUE.",
+	logicalpermissions.LintRuleNoBypassFalse:        "NO_BYPASS is set to FALSE or to an empty slice, which has no effect.",
+	logicalpermissions.LintRuleUnregisteredBypass:   "A NO_BYPASS slice contains the name of a bypass that has not been registered.",
+	logicalpermissions.LintRuleTypeCaseConflict:     "Permission types differ only by case.",
+}
+
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("lplint", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	types := flags.String("types", "", "comma-separated list of registered permission types")
+	bypasses := flags.String("bypasses", "", "comma-separated list of registered named bypasses")
+	format := flags.String("format", "text", "output format: text, json or sarif")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 || (*format != "text" && *format != "json" && *format != "sarif") {
+		fmt.Fprintln(stderr, "usage: lplint [-types role,flag] [-bypasses name,...] [-format text|json|sarif] file...")
+		return 2
+	}
+
+	lp := logicalpermissions.LogicalPermissions{}
+	for _, name := range strings.Split(*types, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if err := lp.AddType(name, func(string, map[string]interface{}) (bool, error) { return false, nil }); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+	}
+	for _, name := range strings.Split(*bypasses, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if err := lp.AddBypass(name, func(map[string]interface{}) (bool, error) { return false, nil }); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+	}
+
+	diagnostics := []fileDiagnostic{}
+	for _, filename := range flags.Args() {
+		contents, err := ioutil.ReadFile(filename)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		for _, diagnostic := range lp.Lint(string(contents)) {
+			diagnostics = append(diagnostics, fileDiagnostic{File: filename, Diagnostic: diagnostic})
+		}
+	}
+
+	if *format == "json" {
+		encoded, _ := json.MarshalIndent(diagnostics, "", "  ")
+		fmt.Fprintf(stdout, "%s\n", encoded)
+	} else if *format == "sarif" {
+		encoded, _ := json.MarshalIndent(newSARIFLog(diagnostics), "", "  ")
+		fmt.Fprintf(stdout, "%s\n", encoded)
+	} else {
+		for _, diagnostic := range diagnostics {
+			fmt.Fprintf(stdout, "%s:%s: %s: %s (%s)\n", diagnostic.File, diagnostic.Path, diagnostic.Severity, diagnostic.Message, diagnostic.Rule)
+		}
+	}
+
+	if len(diagnostics) > 0 {
+		return 1
+	}
+	return 0
+}
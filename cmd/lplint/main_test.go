@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lplint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	clean := filepath.Join(dir, "clean.json")
+	if err := ioutil.WriteFile(clean, []byte(`{"role": "admin"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	smelly := filepath.Join(dir, "smelly.json")
+	if err := ioutil.WriteFile(smelly, []byte(`{"NO_BYPASS": false, "role": {"NOT": {"NOT": "admin"}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 0, run([]string{"-types", "role", clean}, &stdout, &stderr))
+	assert.Equal(t, "", stdout.String())
+
+	assert.Equal(t, 1, run([]string{"-types", "role", clean, smelly}, &stdout, &stderr))
+	assert.Equal(t, smelly+":/NO_BYPASS: warning: NO_BYPASS is set to FALSE, which has no effect. Remove it instead. (no-bypass-false)\n"+
+		smelly+":/role/NOT: warning: A NOT gate directly containing another NOT gate cancels out. Use the inner permissions directly instead. (double-negation)\n", stdout.String())
+
+	stdout.Reset()
+	assert.Equal(t, 1, run([]string{"-format", "json", smelly}, &stdout, &stderr))
+	diagnostics := []map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal(stdout.Bytes(), &diagnostics))
+	assert.Equal(t, 2, len(diagnostics))
+	assert.Equal(t, "invalid-permissions", diagnostics[1]["rule"])
+	assert.Equal(t, smelly, diagnostics[1]["file"])
+
+	stdout.Reset()
+	assert.Equal(t, 1, run([]string{"-format", "sarif", "-types", "role", smelly}, &stdout, &stderr))
+	log := make(map[string]interface{})
+	assert.Nil(t, json.Unmarshal(stdout.Bytes(), &log))
+	assert.Equal(t, "2.1.0", log["version"])
+	results := log["runs"].([]interface{})[0].(map[string]interface{})["results"].([]interface{})
+	assert.Equal(t, 2, len(results))
+	assert.Equal(t, "warning", results[0].(map[string]interface{})["level"])
+
+	bypass := filepath.Join(dir, "bypass.json")
+	if err := ioutil.WriteFile(bypass, []byte(`{"NO_BYPASS": ["!support", "audit"], "role": "admin"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stdout.Reset()
+	assert.Equal(t, 0, run([]string{"-types", "role", "-bypasses", "support,audit", bypass}, &stdout, &stderr))
+	assert.Equal(t, "", stdout.String())
+	assert.Equal(t, 1, run([]string{"-types", "role", "-bypasses", "support", bypass}, &stdout, &stderr))
+	assert.Contains(t, stdout.String(), "(unregistered-bypass)")
+}
+
+func TestRunUsage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 2, run([]string{}, &stdout, &stderr))
+	assert.Equal(t, 2, run([]string{"-format", "xml", "policy.json"}, &stdout, &stderr))
+	assert.Equal(t, 2, run([]string{"-bypasses", "default", "policy.json"}, &stdout, &stderr))
+}
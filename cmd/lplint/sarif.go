@@ -0,0 +1,99 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/ordermind/logical-permissions-go"
+)
+
+// The subset of the SARIF 2.1.0 format that is needed for reporting diagnostics.
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation  `json:"physicalLocation"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+}
+
+func newSARIFLog(diagnostics []fileDiagnostic) *sarifLog {
+	rule_ids := make([]string, 0, len(ruleDescriptions))
+	for id := range ruleDescriptions {
+		rule_ids = append(rule_ids, id)
+	}
+	sort.Strings(rule_ids)
+	rules := make([]sarifRule, len(rule_ids))
+	for i, id := range rule_ids {
+		rules[i] = sarifRule{ID: id, ShortDescription: sarifMessage{Text: ruleDescriptions[id]}}
+	}
+
+	results := make([]sarifResult, len(diagnostics))
+	for i, diagnostic := range diagnostics {
+		level := "note"
+		if diagnostic.Severity == logicalpermissions.SeverityError {
+			level = "error"
+		} else if diagnostic.Severity == logicalpermissions.SeverityWarning {
+			level = "warning"
+		}
+		location := sarifLocation{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: diagnostic.File}}}
+		if diagnostic.Path != "" {
+			location.LogicalLocations = []sarifLogicalLocation{{FullyQualifiedName: diagnostic.Path}}
+		}
+		results[i] = sarifResult{RuleID: diagnostic.Rule, Level: level, Message: sarifMessage{Text: diagnostic.Message}, Locations: []sarifLocation{location}}
+	}
+
+	return &sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs: []sarifRun{{
+			Tool:    sarifTool{Driver: sarifDriver{Name: "lplint", InformationURI: "https://github.com/ordermind/logical-permissions-go", Rules: rules}},
+			Results: results,
+		}},
+	}
+}
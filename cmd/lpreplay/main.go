@@ -0,0 +1,109 @@
+// Command lpreplay replays recorded access decisions against candidate
+// permission trees and reports every decision whose result would change.
+//
+// Usage:
+//
+//	lpreplay -policies policies.json [-json] [decisions.log ...]
+//
+// The policies file maps policy IDs to candidate permission trees, e.g.
+// {"edit-article": {"role": "editor"}}. The decision logs contain the json
+// lines written by a DecisionLogger, and standard input is read if no logs are
+// given. The recorded callback results are used instead of live callbacks.
+//
+// Decisions that cannot be replayed because a candidate needs a callback result
+// or a permission type that was not recorded are reported as inconclusive. The
+// exit status is 0 if no decision changes, 1 if at least one decision changes
+// or is inconclusive and 2 if an error occurred.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ordermind/logical-permissions-go"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("lpreplay", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	policies_file := flags.String("policies", "", "JSON file mapping policy IDs to candidate permission trees")
+	output_json := flags.Bool("json", false, "print the report as JSON")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *policies_file == "" {
+		fmt.Fprintln(stderr, "usage: lpreplay -policies policies.json [-json] [decisions.log ...]")
+		return 2
+	}
+
+	candidates := make(map[string]interface{})
+	if err := readJSONFile(*policies_file, &candidates); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	records := []*logicalpermissions.DecisionRecord{}
+	if flags.NArg() == 0 {
+		file_records, err := logicalpermissions.ReadDecisionRecords(stdin)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		records = file_records
+	}
+	for _, filename := range flags.Args() {
+		file, err := os.Open(filename)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		file_records, err := logicalpermissions.ReadDecisionRecords(file)
+		file.Close()
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %s\n", filename, err)
+			return 2
+		}
+		records = append(records, file_records...)
+	}
+
+	report := logicalpermissions.ReplayDecisions(records, candidates)
+	if *output_json {
+		encoded, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Fprintf(stdout, "%s\n", encoded)
+	} else {
+		for _, change := range report.Changes {
+			fmt.Fprintf(stdout, "%s: check %d of policy %s at %s", change.Kind, change.Record.CheckID, change.Record.PolicyID, change.Record.Timestamp.Format("2006-01-02T15:04:05Z07:00"))
+			if change.Error != "" {
+				fmt.Fprintf(stdout, " (error: %s)", change.Error)
+			}
+			fmt.Fprintln(stdout)
+		}
+		fmt.Fprintf(stdout, "replayed: %d, skipped: %d, unchanged: %d, grant to deny: %d, deny to grant: %d, inconclusive: %d\n", report.Replayed, report.Skipped, report.Unchanged, report.GrantToDeny, report.DenyToGrant, report.Inconclusive)
+	}
+
+	if report.GrantToDeny > 0 || report.DenyToGrant > 0 || report.Inconclusive > 0 {
+		return 1
+	}
+	return 0
+}
+
+func readJSONFile(filename string, value interface{}) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	decoder := json.NewDecoder(file)
+	decoder.UseNumber()
+	if err := decoder.Decode(value); err != nil {
+		return fmt.Errorf("Error parsing %s: %s", filename, err)
+	}
+	return nil
+}
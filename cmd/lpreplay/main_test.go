@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const decisionLog = `{"check_id":1,"policy_id":"edit","fingerprint":"","context":{},"allow_bypass":true,"bypass_checked":false,"bypass_used":false,"access":true,"duration_ns":0,"leaves":[{"type":"role","permission":"admin","access":true,"duration_ns":0}]}
+{"check_id":2,"policy_id":"edit","fingerprint":"","context":{},"allow_bypass":true,"bypass_checked":false,"bypass_used":false,"access":false,"duration_ns":0,"leaves":[{"type":"role","permission":"admin","access":false,"duration_ns":0}]}
+{"check_id":3,"policy_id":"view","fingerprint":"","context":{},"allow_bypass":true,"bypass_checked":false,"bypass_used":false,"access":true,"duration_ns":0,"leaves":[]}
+`
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lpreplay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	policies := filepath.Join(dir, "policies.json")
+	if err := ioutil.WriteFile(policies, []byte(`{"edit": {"role": "editor"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	status := run([]string{"-policies", policies}, strings.NewReader(decisionLog), &stdout, &stderr)
+	assert.Equal(t, 1, status)
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[0], "inconclusive: check 1 of policy edit"))
+	assert.Contains(t, lines[0], "No result was recorded")
+	assert.True(t, strings.HasPrefix(lines[1], "inconclusive: check 2 of policy edit"))
+	assert.Equal(t, "replayed: 2, skipped: 1, unchanged: 0, grant to deny: 0, deny to grant: 0, inconclusive: 2", lines[2])
+
+	if err := ioutil.WriteFile(policies, []byte(`{"edit": {"NOT": {"role": "admin"}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stdout.Reset()
+	status = run([]string{"-policies", policies}, strings.NewReader(decisionLog), &stdout, &stderr)
+	assert.Equal(t, 1, status)
+	lines = strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	assert.Equal(t, "replayed: 2, skipped: 1, unchanged: 0, grant to deny: 1, deny to grant: 1, inconclusive: 0", lines[2])
+
+	decisions := filepath.Join(dir, "decisions.log")
+	if err := ioutil.WriteFile(decisions, []byte(decisionLog), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(policies, []byte(`{"edit": {"role": "admin"}, "view": true}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stdout.Reset()
+	status = run([]string{"-policies", policies, "-json", decisions}, nil, &stdout, &stderr)
+	assert.Equal(t, 0, status)
+	report := make(map[string]interface{})
+	assert.Nil(t, json.Unmarshal(stdout.Bytes(), &report))
+	assert.Equal(t, float64(3), report["unchanged"])
+}
+
+func TestRunErrors(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 2, run([]string{}, nil, &stdout, &stderr))
+	assert.Equal(t, 2, run([]string{"-policies", "missing.json"}, nil, &stdout, &stderr))
+}
@@ -5,11 +5,43 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type LogicalPermissions struct {
-	types           map[string]func(string, map[string]interface{}) (bool, error)
-	bypass_callback func(map[string]interface{}) (bool, error)
+	// last_check_id and shadow_dropped are accessed atomically and are the
+	// first fields so that they are 64-bit aligned on 32-bit platforms.
+	last_check_id     uint64
+	shadow_dropped    uint64
+	types             map[string]func(string, map[string]interface{}) (bool, error)
+	bypass_callback   func(map[string]interface{}) (bool, error)
+	bypasses          map[string]func(map[string]interface{}) (bool, error)
+	type_templates    map[string]string
+	negated_templates map[string]string
+	phrasebook        Phrasebook
+	observers         []Observer
+	shadow_observer   ShadowObserver
+	shadow_slots      chan struct{}
+	max_shadow_checks int
+	metrics           *Metrics
+	tracer            Tracer
+	cache             Cache
+	cache_decisions   bool
+	type_cache_keys   map[string][]string
+	bypass_cache_keys []string
+	batch_callbacks   map[string]func([]string, map[string]interface{}) (map[string]bool, error)
+	parallelism       int
+	three_valued      bool
+	error_policy      ErrorPolicy
+	type_policies     map[string]ErrorPolicy
+	continue_on_error bool
+	recover_panics    bool
+	type_limiters     map[string]*typeLimiter
+	policy_limits     PolicyLimits
+	strict            bool
+	type_costs        map[string]float64
+	learned_costs     *learnedCosts
+	optimize_order    bool
 }
 
 func (this *LogicalPermissions) AddType(name string, callback func(string, map[string]interface{}) (bool, error)) error {
@@ -41,6 +73,17 @@ func (this *LogicalPermissions) RemoveType(name string) error {
 	types := this.GetTypes()
 	delete(types, name)
 	this.SetTypes(types)
+	delete(this.type_templates, name)
+	delete(this.negated_templates, name)
+	delete(this.type_cache_keys, name)
+	delete(this.batch_callbacks, name)
+	delete(this.type_costs, name)
+	delete(this.type_policies, name)
+	delete(this.type_limiters, name)
+	if this.learned_costs != nil {
+		this.learned_costs.remove(name)
+	}
+	this.InvalidateCacheType(name)
 	return nil
 }
 
@@ -78,6 +121,7 @@ func (this *LogicalPermissions) SetTypeCallback(name string, callback func(strin
 	types := this.GetTypes()
 	types[name] = callback
 	this.SetTypes(types)
+	this.InvalidateCacheType(name)
 	return nil
 }
 
@@ -115,6 +159,7 @@ func (this *LogicalPermissions) GetBypassCallback() func(map[string]interface{})
 
 func (this *LogicalPermissions) SetBypassCallback(callback func(map[string]interface{}) (bool, error)) {
 	this.bypass_callback = callback
+	this.InvalidateCacheBypass()
 }
 
 func (this *LogicalPermissions) GetValidPermissionKeys() []string {
@@ -130,11 +175,19 @@ func (this *LogicalPermissions) GetValidPermissionKeys() []string {
 }
 
 func (this *LogicalPermissions) CheckAccess(permissions interface{}, context map[string]interface{}) (bool, error) {
-	return this.checkAccess(permissions, context, true)
+	return this.check(permissions, context, true, &evaluation{})
 }
 
 func (this *LogicalPermissions) CheckAccessNoBypass(permissions interface{}, context map[string]interface{}) (bool, error) {
-	return this.checkAccess(permissions, context, false)
+	return this.check(permissions, context, false, &evaluation{})
+}
+
+func (this *LogicalPermissions) CheckAccessWithTrace(permissions interface{}, context map[string]interface{}) (bool, *Trace, error) {
+	return this.checkAccessWithTrace(permissions, context, true, &evaluation{})
+}
+
+func (this *LogicalPermissions) CheckAccessNoBypassWithTrace(permissions interface{}, context map[string]interface{}) (bool, *Trace, error) {
+	return this.checkAccessWithTrace(permissions, context, false, &evaluation{})
 }
 
 func (this *LogicalPermissions) stringInSlice(a string, slice []string) bool {
@@ -146,11 +199,50 @@ func (this *LogicalPermissions) stringInSlice(a string, slice []string) bool {
 	return false
 }
 
-func (this *LogicalPermissions) checkAccess(permissions interface{}, context map[string]interface{}, allow_bypass bool) (bool, error) {
+func (this *LogicalPermissions) checkAccessWithTrace(permissions interface{}, context map[string]interface{}, allow_bypass bool, eval *evaluation) (bool, *Trace, error) {
+	trace := &Trace{}
+	eval.trace = trace
+	access, err := this.check(permissions, context, allow_bypass, eval)
+	trace.Result = access
+	trace.Unknown = eval.unknown
+	if err != nil {
+		trace.Error = err.Error()
+	}
+	return access, trace, err
+}
+
+func (this *LogicalPermissions) check(permissions interface{}, context map[string]interface{}, allow_bypass bool, eval *evaluation) (bool, error) {
+	return this.observeCheck(permissions, context, allow_bypass, eval, func() (bool, error) {
+		span := this.startCheckSpan(context, allow_bypass, eval)
+		metrics := this.GetMetrics()
+		start := time.Now()
+		access, err := this.checkAccessCached(permissions, context, allow_bypass, eval)
+		if metrics != nil {
+			metrics.observeCheck(access, err, eval, time.Since(start))
+		}
+		this.endCheckSpan(span, access, err, eval)
+		return access, err
+	})
+}
+
+func (this *LogicalPermissions) checkAccess(permissions interface{}, context map[string]interface{}, allow_bypass bool, eval *evaluation) (bool, error) {
+	if this.parallelism > 1 && eval.slots == nil {
+		eval.slots = make(chan struct{}, this.parallelism-1)
+	}
+	if this.policy_limits.MaxCallbacks > 0 {
+		eval.callbacks = new(int32)
+	}
 	map_permissions, err := this.preparePermissions(permissions)
 	if err != nil {
 		return false, err
 	}
+	if len(this.batch_callbacks) > 0 || this.optimize_order {
+		eval.tree = this.parsePreparedTree(map_permissions)
+	}
+	eval.optimize_order = this.canOptimizeOrder(eval.tree)
+	if len(this.batch_callbacks) > 0 {
+		eval.batch = newBatchResults()
+	}
 
 	// uppercasing of no_bypass key for backward compatibility
 	if no_bypass, ok := map_permissions["no_bypass"]; ok {
@@ -159,34 +251,105 @@ func (this *LogicalPermissions) checkAccess(permissions interface{}, context map
 	}
 
 	//Bypass access check
+	// bypass_unknown is set if the bypass may grant access but the result is
+	// unknown.
+	var no_bypass_unknown *unknownResult
+	var bypass_unknown *unknownResult
+	// applied holds the bypasses that apply to the permission tree, and
+	// available also holds the named bypasses that NO_BYPASS slices below the
+	// root can accept.
+	applied, available := []string{}, []string{}
+	if allow_bypass {
+		var err_custom CustomErrorInterface
+		available, err_custom = this.getBypassCandidates(map_permissions)
+		if err_custom != nil {
+			return false, err_custom
+		}
+		if this.GetBypassCallback() != nil {
+			applied = []string{DefaultBypass}
+		}
+	}
 	if no_bypass_upper, ok := map_permissions["NO_BYPASS"]; ok {
 		if allow_bypass {
-			result, err_custom := this.checkAllowBypass(no_bypass_upper, context)
+			allowed, allowed_available, err_custom := this.getAllowedBypasses(no_bypass_upper, applied, available, context, eval)
+			if isUnknownResult(err_custom) {
+				no_bypass_unknown = err_custom.(*unknownResult)
+				allowed, allowed_available, err_custom = applied, available, nil
+			}
 			if err_custom != nil {
 				return false, err_custom
 			}
-			allow_bypass = result
+			applied, available = allowed, allowed_available
 		}
 		delete(map_permissions, "NO_BYPASS")
 	}
-	if allow_bypass {
-		access, err_custom := this.checkBypassAccess(context)
+	// Unless a NO_BYPASS key below the root may refuse or accept some of the
+	// bypasses, the first bypass that applies and grants access is enough.
+	tree_bypass := !this.containsNoBypass(map_permissions)
+	candidates := available
+	if tree_bypass {
+		candidates = applied
+	}
+	if len(candidates) > 0 {
+		granted, err_custom := this.checkBypassAccess(candidates, tree_bypass, context, eval)
+		access := len(granted) > 0
+		if eval.trace != nil {
+			eval.trace.BypassChecked = true
+			eval.trace.BypassAccess = access
+			if access {
+				eval.trace.Bypass = granted[0]
+			}
+		}
+		if isUnknownResult(err_custom) {
+			bypass_unknown = err_custom.(*unknownResult)
+			err_custom = nil
+		}
 		if err_custom != nil {
 			err_custom.setMessage(fmt.Sprintf("Error checking bypass access: %s", err_custom.Error()))
 			return false, err_custom
 		}
-		if access {
+		if access && no_bypass_unknown != nil {
+			bypass_unknown = no_bypass_unknown
+		} else if access && tree_bypass {
+			eval.bypass_granted = true
 			return access, nil
+		} else if access {
+			// Bypass access applies to the permission tree except where a
+			// NO_BYPASS key below the root refuses the granted bypasses, and
+			// where a NO_BYPASS slice accepts a granted named bypass.
+			eval.bypass = this.intersectBypasses(applied, granted)
+			eval.bypass_available = this.intersectBypasses(available, granted)
 		}
 	}
 
 	//Normal access check
 	if len(map_permissions) > 0 {
-		access, err_custom := this.processOR(map_permissions, "", context)
+		node := eval.beginNode(TraceNodeGate, "OR", "")
+		access, err_custom := this.processOR(map_permissions, "", context, eval)
+		eval.endNode(node, access, err_custom)
+		if eval.trace != nil {
+			eval.trace.Root = node
+		}
+		if isUnknownResult(err_custom) {
+			unknown := err_custom.(*unknownResult)
+			if unknown.cause != nil {
+				unknown.cause.setMessage(fmt.Sprintf("Error checking access: %s", unknown.cause.Error()))
+			}
+			if bypass_unknown != nil {
+				unknown = mergeUnknown(unknown, bypass_unknown)
+			}
+			return this.setUnknown(unknown, eval)
+		}
 		if err_custom != nil {
 			err_custom.setMessage(fmt.Sprintf("Error checking access: %s", err_custom.Error()))
 			return false, err_custom
 		}
+		if !access && bypass_unknown != nil {
+			return this.setUnknown(bypass_unknown, eval)
+		}
+		if access && eval.bypass_used {
+			eval.bypass_granted = true
+		}
 		return access, nil
 	}
 
@@ -223,18 +386,34 @@ func (this *LogicalPermissions) preparePermissions(permissions interface{}) (map
 		return nil, &CustomError{fmt.Sprintf("permissions must be a boolean, a string, a slice or a map[string]interface{}. Evaluated permissions: %v", permissions)}
 	}
 
+	if err := this.checkInputSize(json_permissions); err != nil {
+		return nil, err
+	}
 	if json_permissions[:1] == "[" {
 		json_permissions = fmt.Sprintf("{\"OR\": %s}", json_permissions)
 	}
+	if _, okString := permissions.(string); okString && this.strict {
+		if err := this.checkDuplicateKeys(json_permissions); err != nil {
+			return nil, err
+		}
+	}
 	map_permissions := make(map[string]interface{})
 	err := json.Unmarshal([]byte(json_permissions), &map_permissions)
 	if err != nil {
 		return nil, &InvalidArgumentValueError{CustomError{fmt.Sprintf("Error parsing json permissions: %s. Evaluated permissions: %s", err.Error(), json_permissions)}}
 	}
+	if err := this.checkTreeLimits(map_permissions); err != nil {
+		return nil, err
+	}
+	if this.strict {
+		if err := this.checkStrict(map_permissions); err != nil {
+			return nil, err
+		}
+	}
 	return map_permissions, nil
 }
 
-func (this *LogicalPermissions) checkAllowBypass(no_bypass interface{}, context map[string]interface{}) (bool, CustomErrorInterface) {
+func (this *LogicalPermissions) checkAllowBypass(no_bypass interface{}, context map[string]interface{}, eval *evaluation) (bool, CustomErrorInterface) {
 	if boolval, ok := no_bypass.(bool); ok {
 		return !boolval, nil
 	}
@@ -252,7 +431,25 @@ func (this *LogicalPermissions) checkAllowBypass(no_bypass interface{}, context
 		}
 	}
 	if mapval, ok := no_bypass.(map[string]interface{}); ok {
-		result, err_custom := this.processOR(mapval, "", context)
+		if _, ok := this.getNoBypassKey(mapval); ok {
+			return false, &InvalidArgumentValueError{CustomError{fmt.Sprintf("The NO_BYPASS permissions cannot have a NO_BYPASS key. Current value: %v", no_bypass)}}
+		}
+		// The NO_BYPASS permissions of the root map have no parent node.
+		root := len(eval.nodes) == 0
+		eval.pushPath("NO_BYPASS")
+		node := eval.beginNode(TraceNodeGate, "OR", "")
+		result, err_custom := this.processOR(mapval, "", context, eval)
+		eval.endNode(node, result, err_custom)
+		eval.popPath()
+		if eval.trace != nil && root {
+			eval.trace.NoBypass = node
+		}
+		if isUnknownResult(err_custom) {
+			if cause := err_custom.(*unknownResult).cause; cause != nil {
+				cause.setMessage(fmt.Sprintf("Error checking NO_BYPASS permissions: %s", cause.Error()))
+			}
+			return false, err_custom
+		}
 		if err_custom != nil {
 			err_custom.setMessage(fmt.Sprintf("Error checking NO_BYPASS permissions: %s", err_custom.Error()))
 			return false, err_custom
@@ -262,46 +459,35 @@ func (this *LogicalPermissions) checkAllowBypass(no_bypass interface{}, context
 	return false, &InvalidArgumentValueError{CustomError{fmt.Sprintf("The NO_BYPASS value must be a boolean, a boolean string or a map. Current value: %v", no_bypass)}}
 }
 
-func (this *LogicalPermissions) checkBypassAccess(context map[string]interface{}) (bool, CustomErrorInterface) {
-	bypass_callback := this.GetBypassCallback()
-	if bypass_callback != nil {
-		bypass_access, err_custom := bypass_callback(context)
-		if err_custom != nil {
-			return false, &CustomError{err_custom.Error()}
-		}
-		return bypass_access, nil
+func (this *LogicalPermissions) dispatch(permissions interface{}, permtype string, context map[string]interface{}, eval *evaluation) (bool, CustomErrorInterface) {
+	if eval != nil && len(eval.bypass) > 0 && !this.containsNoBypass(permissions) {
+		return this.bypassPermissions(permissions, eval), nil
 	}
-	return false, nil
-}
-
-func (this *LogicalPermissions) dispatch(permissions interface{}, permtype string, context map[string]interface{}) (bool, CustomErrorInterface) {
 	if bool_permissions, ok := permissions.(bool); ok {
-		if bool_permissions == true {
-			if permtype != "" {
-				return false, &InvalidArgumentValueError{CustomError{fmt.Sprintf("You cannot put a boolean permission as a descendant to a permission type. Existing type: %s. Evaluated permissions: %v", permtype, bool_permissions)}}
-			}
-			return true, nil
-		} else if bool_permissions == false {
-			if permtype != "" {
-				return false, &InvalidArgumentValueError{CustomError{fmt.Sprintf("You cannot put a boolean permission as a descendant to a permission type. Existing type: %s. Evaluated permissions: %v", permtype, bool_permissions)}}
-			}
-			return false, nil
+		if permtype != "" {
+			return false, &InvalidArgumentValueError{CustomError{fmt.Sprintf("You cannot put a boolean permission as a descendant to a permission type. Existing type: %s. Evaluated permissions: %v", permtype, bool_permissions)}}
 		}
+		node := eval.beginNode(TraceNodeBoolean, strings.ToUpper(strconv.FormatBool(bool_permissions)), "")
+		eval.endNode(node, bool_permissions, nil)
+		return bool_permissions, nil
 	}
 	if str_permissions, ok := permissions.(string); ok {
-		if strings.ToUpper(str_permissions) == "TRUE" {
+		if strings.ToUpper(str_permissions) == "TRUE" || strings.ToUpper(str_permissions) == "FALSE" {
 			if permtype != "" {
 				return false, &InvalidArgumentValueError{CustomError{fmt.Sprintf("You cannot put a boolean permission as a descendant to a permission type. Existing type: %s. Evaluated permissions: %v", permtype, str_permissions)}}
 			}
-			return true, nil
-		} else if strings.ToUpper(str_permissions) == "FALSE" {
-			if permtype != "" {
-				return false, &InvalidArgumentValueError{CustomError{fmt.Sprintf("You cannot put a boolean permission as a descendant to a permission type. Existing type: %s. Evaluated permissions: %v", permtype, str_permissions)}}
-			}
-			return false, nil
+			access := strings.ToUpper(str_permissions) == "TRUE"
+			node := eval.beginNode(TraceNodeBoolean, strings.ToUpper(str_permissions), "")
+			eval.endNode(node, access, nil)
+			return access, nil
 		}
 
-		access, err_custom := this.externalAccessCheck(str_permissions, permtype, context)
+		node := eval.beginNode(TraceNodeValue, "", permtype)
+		if node != nil {
+			node.Value = str_permissions
+		}
+		access, err_custom := this.externalAccessCheck(str_permissions, permtype, context, eval)
+		eval.endNode(node, access, err_custom)
 		if err_custom != nil {
 			return false, err_custom
 		}
@@ -309,7 +495,9 @@ func (this *LogicalPermissions) dispatch(permissions interface{}, permtype strin
 	}
 	if slice_permissions, ok := permissions.([]interface{}); ok {
 		if len(slice_permissions) > 0 {
-			access, err_custom := this.processOR(slice_permissions, permtype, context)
+			node := eval.beginNode(TraceNodeGate, "OR", permtype)
+			access, err_custom := this.processOR(slice_permissions, permtype, context, eval)
+			eval.endNode(node, access, err_custom)
 			if err_custom != nil {
 				return false, err_custom
 			}
@@ -318,6 +506,11 @@ func (this *LogicalPermissions) dispatch(permissions interface{}, permtype strin
 		return false, nil
 	}
 	if map_permissions, ok := permissions.(map[string]interface{}); ok {
+		if key, ok := this.getNoBypassKey(map_permissions); ok {
+			return this.checkNoBypassScope(map_permissions, key, permtype, context, eval, func(permissions interface{}) (bool, CustomErrorInterface) {
+				return this.dispatch(permissions, permtype, context, eval)
+			})
+		}
 		if len(map_permissions) == 1 {
 			key := ""
 			for k, _ := range map_permissions {
@@ -325,48 +518,19 @@ func (this *LogicalPermissions) dispatch(permissions interface{}, permtype strin
 				break
 			}
 			value := map_permissions[key]
+			eval.pushPath(key)
+			defer eval.popPath()
+			node_kind := TraceNodeGate
+			node_name := "OR"
 			if _, err := strconv.Atoi(key); err != nil {
 				key_upper := strings.ToUpper(key)
-				if key_upper == "NO_BYPASS" {
-					return false, &InvalidArgumentValueError{CustomError{fmt.Sprintf("The NO_BYPASS key must be placed highest in the permission hierarchy. Evaluated permissions: %v", map_permissions)}}
-				}
-				if key_upper == "AND" {
-					access, err_custom := this.processAND(value, permtype, context)
-					if err_custom != nil {
-						return false, err_custom
-					}
-					return access, nil
-				}
-				if key_upper == "NAND" {
-					access, err_custom := this.processNAND(value, permtype, context)
-					if err_custom != nil {
-						return false, err_custom
-					}
-					return access, nil
-				}
-				if key_upper == "OR" {
-					access, err_custom := this.processOR(value, permtype, context)
-					if err_custom != nil {
-						return false, err_custom
-					}
-					return access, nil
-				}
-				if key_upper == "NOR" {
-					access, err_custom := this.processNOR(value, permtype, context)
-					if err_custom != nil {
-						return false, err_custom
-					}
-					return access, nil
-				}
-				if key_upper == "XOR" {
-					access, err_custom := this.processXOR(value, permtype, context)
-					if err_custom != nil {
-						return false, err_custom
-					}
-					return access, nil
-				}
-				if key_upper == "NOT" {
-					access, err_custom := this.processNOT(value, permtype, context)
+				if this.stringInSlice(key_upper, this.getGateKeys()) {
+					access, err_custom := this.checkScopedValue(value, permtype, context, eval, func(value interface{}) (bool, CustomErrorInterface) {
+						node := eval.beginNode(TraceNodeGate, key_upper, permtype)
+						access, err_custom := this.processGate(key_upper, value, permtype, context, eval)
+						eval.endNode(node, access, err_custom)
+						return access, err_custom
+					})
 					if err_custom != nil {
 						return false, err_custom
 					}
@@ -389,6 +553,8 @@ func (this *LogicalPermissions) dispatch(permissions interface{}, permtype strin
 				}
 
 				permtype = key
+				node_kind = TraceNodeType
+				node_name = key
 			}
 			value_type := ""
 			if _, ok := value.([]interface{}); ok {
@@ -397,14 +563,19 @@ func (this *LogicalPermissions) dispatch(permissions interface{}, permtype strin
 				value_type = "map"
 			}
 			if value_type == "slice" || value_type == "map" {
-				access, err_custom := this.processOR(value, permtype, context)
+				access, err_custom := this.checkScopedValue(value, permtype, context, eval, func(value interface{}) (bool, CustomErrorInterface) {
+					node := eval.beginNode(node_kind, node_name, permtype)
+					access, err_custom := this.processOR(value, permtype, context, eval)
+					eval.endNode(node, access, err_custom)
+					return access, err_custom
+				})
 				if err_custom != nil {
 					return false, err_custom
 				}
 				return access, nil
 			}
 
-			access, err_custom := this.dispatch(value, permtype, context)
+			access, err_custom := this.dispatch(value, permtype, context, eval)
 			if err_custom != nil {
 				return false, err_custom
 			}
@@ -412,7 +583,9 @@ func (this *LogicalPermissions) dispatch(permissions interface{}, permtype strin
 
 		}
 		if len(map_permissions) > 1 {
-			access, err_custom := this.processOR(map_permissions, permtype, context)
+			node := eval.beginNode(TraceNodeGate, "OR", permtype)
+			access, err_custom := this.processOR(map_permissions, permtype, context, eval)
+			eval.endNode(node, access, err_custom)
 			if err_custom != nil {
 				return false, err_custom
 			}
@@ -424,15 +597,76 @@ func (this *LogicalPermissions) dispatch(permissions interface{}, permtype strin
 	return false, &InvalidArgumentValueError{CustomError{fmt.Sprintf("A permission value must either be a boolean, a string, a slice or a map. Evaluated permissions: %v", permissions)}}
 }
 
-func (this *LogicalPermissions) processAND(permissions interface{}, permtype string, context map[string]interface{}) (bool, CustomErrorInterface) {
+func (this *LogicalPermissions) processGate(gate string, permissions interface{}, permtype string, context map[string]interface{}, eval *evaluation) (bool, CustomErrorInterface) {
+	metrics := this.GetMetrics()
+	if metrics == nil || eval.isShadow() {
+		return this.evaluateGate(gate, permissions, permtype, context, eval)
+	}
+	start := time.Now()
+	access, err_custom := this.evaluateGate(gate, permissions, permtype, context, eval)
+	metrics.observeGate(gate, access, err_custom, time.Since(start))
+	return access, err_custom
+}
+
+func (this *LogicalPermissions) evaluateGate(gate string, permissions interface{}, permtype string, context map[string]interface{}, eval *evaluation) (bool, CustomErrorInterface) {
+	if gate != "AND" && gate != "OR" && eval != nil && len(eval.bypass_available) > 0 {
+		// Bypass access only applies to the children of AND and OR gates,
+		// which cannot deny access because a child grants it.
+		bypass, bypass_available := eval.bypass, eval.bypass_available
+		eval.bypass, eval.bypass_available = nil, nil
+		defer func() {
+			eval.bypass, eval.bypass_available = bypass, bypass_available
+		}()
+	}
+	if gate == "AND" {
+		return this.processAND(permissions, permtype, context, eval)
+	}
+	if gate == "NAND" {
+		return this.processNAND(permissions, permtype, context, eval)
+	}
+	if gate == "OR" {
+		return this.processOR(permissions, permtype, context, eval)
+	}
+	if gate == "NOR" {
+		return this.processNOR(permissions, permtype, context, eval)
+	}
+	if gate == "XOR" {
+		return this.processXOR(permissions, permtype, context, eval)
+	}
+	return this.processNOT(permissions, permtype, context, eval)
+}
+
+func (this *LogicalPermissions) processAND(permissions interface{}, permtype string, context map[string]interface{}, eval *evaluation) (bool, CustomErrorInterface) {
+	if children, ok := this.getParallelChildren(permissions, "AND", 1, permtype, eval); ok {
+		access := true
+		unknown, err_custom := this.dispatchParallel(children, permtype, context, eval, func(result bool) bool {
+			access = access && result
+			return !access
+		})
+		if err_custom != nil {
+			return false, err_custom
+		}
+		if access && unknown != nil {
+			return false, unknown
+		}
+		return access, nil
+	}
 	if slice_permissions, ok := permissions.([]interface{}); ok {
 		if len(slice_permissions) < 1 {
 			return false, &InvalidValueForLogicGateError{CustomError{fmt.Sprintf("The value slice of an AND gate must contain a minimum of one element. Current value: %v", slice_permissions)}}
 		}
 
 		access := true
-		for _, permission := range slice_permissions {
-			result, err_custom := this.dispatch(permission, permtype, context)
+		var unknown *unknownResult
+		for _, i := range this.getChildOrder(slice_permissions, "AND", permtype, eval) {
+			permission := slice_permissions[i]
+			eval.pushPath(strconv.Itoa(i))
+			result, err_custom := this.dispatch(permission, permtype, context, eval)
+			eval.popPath()
+			if isUnknownResult(err_custom) {
+				unknown = mergeUnknown(unknown, err_custom)
+				continue
+			}
 			if err_custom != nil {
 				return false, err_custom
 			}
@@ -441,6 +675,9 @@ func (this *LogicalPermissions) processAND(permissions interface{}, permtype str
 				break
 			}
 		}
+		if access && unknown != nil {
+			return false, unknown
+		}
 		return access, nil
 	}
 	if map_permissions, ok := permissions.(map[string]interface{}); ok {
@@ -449,9 +686,15 @@ func (this *LogicalPermissions) processAND(permissions interface{}, permtype str
 		}
 
 		access := true
-		for k, v := range map_permissions {
+		var unknown *unknownResult
+		for _, k := range this.getKeyOrder(map_permissions, "AND", permtype, eval) {
+			v := map_permissions[k]
 			subpermissions := map[string]interface{}{k: v}
-			result, err_custom := this.dispatch(subpermissions, permtype, context)
+			result, err_custom := this.dispatch(subpermissions, permtype, context, eval)
+			if isUnknownResult(err_custom) {
+				unknown = mergeUnknown(unknown, err_custom)
+				continue
+			}
 			if err_custom != nil {
 				return false, err_custom
 			}
@@ -460,13 +703,16 @@ func (this *LogicalPermissions) processAND(permissions interface{}, permtype str
 				break
 			}
 		}
+		if access && unknown != nil {
+			return false, unknown
+		}
 		return access, nil
 	}
 
 	return false, &InvalidValueForLogicGateError{CustomError{fmt.Sprintf("The value of an AND gate must be a slice or map. Current value: %v", permissions)}}
 }
 
-func (this *LogicalPermissions) processNAND(permissions interface{}, permtype string, context map[string]interface{}) (bool, CustomErrorInterface) {
+func (this *LogicalPermissions) processNAND(permissions interface{}, permtype string, context map[string]interface{}, eval *evaluation) (bool, CustomErrorInterface) {
 	if slice_permissions, ok := permissions.([]interface{}); ok {
 		if len(slice_permissions) < 1 {
 			return false, &InvalidValueForLogicGateError{CustomError{fmt.Sprintf("The value slice of a NAND gate must contain a minimum of one element. Current value: %v", slice_permissions)}}
@@ -479,7 +725,7 @@ func (this *LogicalPermissions) processNAND(permissions interface{}, permtype st
 		return false, &InvalidValueForLogicGateError{CustomError{fmt.Sprintf("The value of a NAND gate must be a slice or map. Current value: %v", permissions)}}
 	}
 
-	result, err_custom := this.processAND(permissions, permtype, context)
+	result, err_custom := this.processAND(permissions, permtype, context, eval)
 	if err_custom != nil {
 		return false, err_custom
 	}
@@ -487,15 +733,37 @@ func (this *LogicalPermissions) processNAND(permissions interface{}, permtype st
 	return access, nil
 }
 
-func (this *LogicalPermissions) processOR(permissions interface{}, permtype string, context map[string]interface{}) (bool, CustomErrorInterface) {
+func (this *LogicalPermissions) processOR(permissions interface{}, permtype string, context map[string]interface{}, eval *evaluation) (bool, CustomErrorInterface) {
+	if children, ok := this.getParallelChildren(permissions, "OR", 1, permtype, eval); ok {
+		access := false
+		unknown, err_custom := this.dispatchParallel(children, permtype, context, eval, func(result bool) bool {
+			access = access || result
+			return access
+		})
+		if err_custom != nil {
+			return false, err_custom
+		}
+		if !access && unknown != nil {
+			return false, unknown
+		}
+		return access, nil
+	}
 	if slice_permissions, ok := permissions.([]interface{}); ok {
 		if len(slice_permissions) < 1 {
 			return false, &InvalidValueForLogicGateError{CustomError{fmt.Sprintf("The value slice of an OR gate must contain a minimum of one element. Current value: %v", slice_permissions)}}
 		}
 
 		access := false
-		for _, permission := range slice_permissions {
-			result, err_custom := this.dispatch(permission, permtype, context)
+		var unknown *unknownResult
+		for _, i := range this.getChildOrder(slice_permissions, "OR", permtype, eval) {
+			permission := slice_permissions[i]
+			eval.pushPath(strconv.Itoa(i))
+			result, err_custom := this.dispatch(permission, permtype, context, eval)
+			eval.popPath()
+			if isUnknownResult(err_custom) {
+				unknown = mergeUnknown(unknown, err_custom)
+				continue
+			}
 			if err_custom != nil {
 				return false, err_custom
 			}
@@ -504,6 +772,9 @@ func (this *LogicalPermissions) processOR(permissions interface{}, permtype stri
 				break
 			}
 		}
+		if !access && unknown != nil {
+			return false, unknown
+		}
 		return access, nil
 	}
 	if map_permissions, ok := permissions.(map[string]interface{}); ok {
@@ -512,9 +783,15 @@ func (this *LogicalPermissions) processOR(permissions interface{}, permtype stri
 		}
 
 		access := false
-		for k, v := range map_permissions {
+		var unknown *unknownResult
+		for _, k := range this.getKeyOrder(map_permissions, "OR", permtype, eval) {
+			v := map_permissions[k]
 			subpermissions := map[string]interface{}{k: v}
-			result, err_custom := this.dispatch(subpermissions, permtype, context)
+			result, err_custom := this.dispatch(subpermissions, permtype, context, eval)
+			if isUnknownResult(err_custom) {
+				unknown = mergeUnknown(unknown, err_custom)
+				continue
+			}
 			if err_custom != nil {
 				return false, err_custom
 			}
@@ -523,13 +800,16 @@ func (this *LogicalPermissions) processOR(permissions interface{}, permtype stri
 				break
 			}
 		}
+		if !access && unknown != nil {
+			return false, unknown
+		}
 		return access, nil
 	}
 
 	return false, &InvalidValueForLogicGateError{CustomError{fmt.Sprintf("The value of an OR gate must be a slice or map. Current value: %v", permissions)}}
 }
 
-func (this *LogicalPermissions) processNOR(permissions interface{}, permtype string, context map[string]interface{}) (bool, CustomErrorInterface) {
+func (this *LogicalPermissions) processNOR(permissions interface{}, permtype string, context map[string]interface{}, eval *evaluation) (bool, CustomErrorInterface) {
 	if slice_permissions, ok := permissions.([]interface{}); ok {
 		if len(slice_permissions) < 1 {
 			return false, &InvalidValueForLogicGateError{CustomError{fmt.Sprintf("The value slice of a NOR gate must contain a minimum of one element. Current value: %v", slice_permissions)}}
@@ -542,7 +822,7 @@ func (this *LogicalPermissions) processNOR(permissions interface{}, permtype str
 		return false, &InvalidValueForLogicGateError{CustomError{fmt.Sprintf("The value of a NOR gate must be a slice or map. Current value: %v", permissions)}}
 	}
 
-	result, err_custom := this.processOR(permissions, permtype, context)
+	result, err_custom := this.processOR(permissions, permtype, context, eval)
 	if err_custom != nil {
 		return false, err_custom
 	}
@@ -550,7 +830,27 @@ func (this *LogicalPermissions) processNOR(permissions interface{}, permtype str
 	return access, nil
 }
 
-func (this *LogicalPermissions) processXOR(permissions interface{}, permtype string, context map[string]interface{}) (bool, CustomErrorInterface) {
+func (this *LogicalPermissions) processXOR(permissions interface{}, permtype string, context map[string]interface{}, eval *evaluation) (bool, CustomErrorInterface) {
+	if children, ok := this.getParallelChildren(permissions, "XOR", 2, permtype, eval); ok {
+		count_true := 0
+		count_false := 0
+		unknown, err_custom := this.dispatchParallel(children, permtype, context, eval, func(result bool) bool {
+			if result {
+				count_true++
+			} else {
+				count_false++
+			}
+			return count_true > 0 && count_false > 0
+		})
+		if err_custom != nil {
+			return false, err_custom
+		}
+		access := count_true > 0 && count_false > 0
+		if !access && unknown != nil {
+			return false, unknown
+		}
+		return access, nil
+	}
 	if slice_permissions, ok := permissions.([]interface{}); ok {
 		if len(slice_permissions) < 2 {
 			return false, &InvalidValueForLogicGateError{CustomError{fmt.Sprintf("The value slice of an XOR gate must contain a minimum of two elements. Current value: %v", slice_permissions)}}
@@ -559,8 +859,16 @@ func (this *LogicalPermissions) processXOR(permissions interface{}, permtype str
 		access := false
 		count_true := 0
 		count_false := 0
-		for _, permission := range slice_permissions {
-			result, err_custom := this.dispatch(permission, permtype, context)
+		var unknown *unknownResult
+		for _, i := range this.getChildOrder(slice_permissions, "XOR", permtype, eval) {
+			permission := slice_permissions[i]
+			eval.pushPath(strconv.Itoa(i))
+			result, err_custom := this.dispatch(permission, permtype, context, eval)
+			eval.popPath()
+			if isUnknownResult(err_custom) {
+				unknown = mergeUnknown(unknown, err_custom)
+				continue
+			}
 			if err_custom != nil {
 				return false, err_custom
 			}
@@ -574,6 +882,9 @@ func (this *LogicalPermissions) processXOR(permissions interface{}, permtype str
 				break
 			}
 		}
+		if !access && unknown != nil {
+			return false, unknown
+		}
 		return access, nil
 	}
 	if map_permissions, ok := permissions.(map[string]interface{}); ok {
@@ -584,9 +895,15 @@ func (this *LogicalPermissions) processXOR(permissions interface{}, permtype str
 		access := false
 		count_true := 0
 		count_false := 0
-		for k, v := range map_permissions {
+		var unknown *unknownResult
+		for _, k := range this.getKeyOrder(map_permissions, "XOR", permtype, eval) {
+			v := map_permissions[k]
 			subpermissions := map[string]interface{}{k: v}
-			result, err_custom := this.dispatch(subpermissions, permtype, context)
+			result, err_custom := this.dispatch(subpermissions, permtype, context, eval)
+			if isUnknownResult(err_custom) {
+				unknown = mergeUnknown(unknown, err_custom)
+				continue
+			}
 			if err_custom != nil {
 				return false, err_custom
 			}
@@ -600,13 +917,16 @@ func (this *LogicalPermissions) processXOR(permissions interface{}, permtype str
 				break
 			}
 		}
+		if !access && unknown != nil {
+			return false, unknown
+		}
 		return access, nil
 	}
 
 	return false, &InvalidValueForLogicGateError{CustomError{fmt.Sprintf("The value of an XOR gate must be a slice or map. Current value: %v", permissions)}}
 }
 
-func (this *LogicalPermissions) processNOT(permissions interface{}, permtype string, context map[string]interface{}) (bool, CustomErrorInterface) {
+func (this *LogicalPermissions) processNOT(permissions interface{}, permtype string, context map[string]interface{}, eval *evaluation) (bool, CustomErrorInterface) {
 	if map_permissions, ok := permissions.(map[string]interface{}); ok {
 		if len(map_permissions) != 1 {
 			return false, &InvalidValueForLogicGateError{CustomError{fmt.Sprintf("A NOT permission must have exactly one child in the value map. Current value: %v", map_permissions)}}
@@ -619,7 +939,7 @@ func (this *LogicalPermissions) processNOT(permissions interface{}, permtype str
 		return false, &InvalidValueForLogicGateError{CustomError{fmt.Sprintf("The value of a NOT gate must be a map or string. Current value: %v", permissions)}}
 	}
 
-	result, err_custom := this.dispatch(permissions, permtype, context)
+	result, err_custom := this.dispatch(permissions, permtype, context, eval)
 	if err_custom != nil {
 		return false, err_custom
 	}
@@ -627,7 +947,13 @@ func (this *LogicalPermissions) processNOT(permissions interface{}, permtype str
 	return access, nil
 }
 
-func (this *LogicalPermissions) externalAccessCheck(permission string, permtype string, context map[string]interface{}) (bool, CustomErrorInterface) {
+func (this *LogicalPermissions) externalAccessCheck(permission string, permtype string, context map[string]interface{}, eval *evaluation) (bool, CustomErrorInterface) {
+	if eval.isCancelled() {
+		return false, &CustomError{evaluationCancelledMessage}
+	}
+	if err_custom := this.countCallback(eval); err_custom != nil {
+		return false, err_custom
+	}
 	exists, err_custom := this.TypeExists(permtype)
 	if err_custom != nil {
 		return false, &CustomError{err_custom.Error()}
@@ -641,10 +967,70 @@ func (this *LogicalPermissions) externalAccessCheck(permission string, permtype
 		return false, &CustomError{err_custom.Error()}
 	}
 
-	access, err_custom := callback(permission, context)
+	key := getCallbackResultKey(permtype, permission)
+	result, ok := eval.getCallbackResult(key)
+	if ok {
+		this.observeCachedResult(permtype, permission, context, eval, result)
+	} else {
+		result = this.getCachedResult(permtype, permission, context, eval, func() callbackResult {
+			if batch_result, ok := this.getBatchResult(permtype, permission, context, eval); ok {
+				return batch_result
+			}
+			return this.callTypeCallback(callback, permission, permtype, context, eval)
+		})
+		eval.setCallbackResult(key, result)
+	}
+	access, err_custom := result.access, result.err
+	if err_custom == ErrUnknown && this.three_valued {
+		return false, newUnknownResult()
+	}
 	if err_custom != nil {
-		return false, &CustomError{err_custom.Error()}
+		return this.applyErrorPolicy(permtype, getCallbackError(err_custom), eval)
 	}
 
 	return access, nil
 }
+
+// callBypassCallback calls the callback of a bypass and reports the call to the
+// tracer and the observers.
+func (this *LogicalPermissions) callBypassCallback(name string, callback func(map[string]interface{}) (bool, error), context map[string]interface{}, eval *evaluation) callbackResult {
+	result := callbackResult{}
+	var attributes map[string]interface{}
+	if name != DefaultBypass {
+		attributes = map[string]interface{}{"logicalpermissions.bypass": name}
+	}
+	span := this.startCallbackSpan(SpanNameBypass, context, eval, attributes)
+	start := time.Now()
+	result.err = this.callRecovered("", "", func() (err error) {
+		result.access, err = callback(context)
+		return err
+	})
+	this.endSpan(span, result.access, result.err)
+	this.observeBypass(name, context, eval, result.access, time.Since(start), result.err)
+	return result
+}
+
+// callTypeCallback calls the callback of a permission type and reports the call
+// to the tracer, the observers and the metrics collector.
+func (this *LogicalPermissions) callTypeCallback(callback func(string, map[string]interface{}) (bool, error), permission string, permtype string, context map[string]interface{}, eval *evaluation) callbackResult {
+	result := callbackResult{}
+	span := this.startCallbackSpan(SpanNameCallback, context, eval, map[string]interface{}{"logicalpermissions.type": permtype, "logicalpermissions.permission": permission})
+	start := time.Now()
+	result.access, result.err = this.callLimited(permtype, func() (access bool, err error) {
+		err = this.callRecovered(permtype, permission, func() (err error) {
+			access, err = callback(permission, context)
+			return err
+		})
+		return access, err
+	})
+	this.endSpan(span, result.access, result.err)
+	duration := time.Since(start)
+	this.observeCallback(permission, permtype, context, eval, result.access, duration, result.err)
+	if metrics := this.GetMetrics(); metrics != nil && !eval.isShadow() {
+		metrics.observeCallback(permtype, result.access, result.err, duration)
+	}
+	if this.learned_costs != nil && !eval.isShadow() {
+		this.learned_costs.learn(permtype, result.access, result.err, duration)
+	}
+	return result
+}
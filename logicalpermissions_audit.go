@@ -0,0 +1,401 @@
+package logicalpermissions
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DecisionRecord is the audit record of a single access check.
+// BypassRegistered, BypassChecked and BypassUsed refer to the bypass callback,
+// and Bypasses holds the results of the named bypasses that were checked. A Cached record holds a decision
+// that was returned by the cache, and its callback results are not recorded.
+type DecisionRecord struct {
+	Timestamp        time.Time              `json:"timestamp"`
+	CheckID          uint64                 `json:"check_id"`
+	PolicyID         string                 `json:"policy_id,omitempty"`
+	Fingerprint      string                 `json:"fingerprint"`
+	Context          map[string]interface{} `json:"context"`
+	AllowBypass      bool                   `json:"allow_bypass"`
+	BypassRegistered bool                   `json:"bypass_registered,omitempty"`
+	BypassChecked    bool                   `json:"bypass_checked"`
+	BypassUsed       bool                   `json:"bypass_used"`
+	Bypasses         map[string]bool        `json:"bypasses,omitempty"`
+	Access           bool                   `json:"access"`
+	Unknown          bool                   `json:"unknown,omitempty"`
+	Cached           bool                   `json:"cached,omitempty"`
+	Error            string                 `json:"error,omitempty"`
+	Duration         time.Duration          `json:"duration_ns"`
+	Leaves           []DecisionLeaf         `json:"leaves"`
+}
+
+// DecisionLeaf is a permission type callback that was called during an access
+// check, or whose result was returned by the cache or a Session. The ErrorType
+// identifies errors that are handled differently from other errors, such as
+// ErrUnknown or a CallbackTimeoutError, so that replays can restore them.
+type DecisionLeaf struct {
+	Type       string        `json:"type"`
+	Permission string        `json:"permission"`
+	Access     bool          `json:"access"`
+	Error      string        `json:"error,omitempty"`
+	ErrorType  string        `json:"error_type,omitempty"`
+	Cached     bool          `json:"cached,omitempty"`
+	Duration   time.Duration `json:"duration_ns"`
+}
+
+// Error types of a DecisionLeaf.
+const (
+	leafErrorUnknown          = "unknown"
+	leafErrorPanic            = "panic"
+	leafErrorTimeout          = "timeout"
+	leafErrorConcurrencyLimit = "concurrency_limit"
+	leafErrorCircuitOpen      = "circuit_open"
+)
+
+// getLeafErrorType returns the error type of a DecisionLeaf for an error
+// returned by a callback, which is empty for errors without a special type.
+func getLeafErrorType(err error) string {
+	switch err.(type) {
+	case *CallbackPanicError:
+		return leafErrorPanic
+	case *CallbackTimeoutError:
+		return leafErrorTimeout
+	case *CallbackConcurrencyLimitError:
+		return leafErrorConcurrencyLimit
+	case *CircuitOpenError:
+		return leafErrorCircuitOpen
+	}
+	if err == ErrUnknown {
+		return leafErrorUnknown
+	}
+	return ""
+}
+
+// DecisionSink receives the records of a DecisionLogger.
+type DecisionSink interface {
+	WriteDecision(record *DecisionRecord) error
+}
+
+// DecisionLogger is an Observer that records every access check to a sink.
+// Configure it before registering it with LogicalPermissions::AddObserver(), and
+// register it with a single LogicalPermissions because check IDs are only
+// unique per LogicalPermissions.
+type DecisionLogger struct {
+	Sink DecisionSink
+	// SampleRate is the fraction of access checks that are recorded, from 0 to 1.
+	SampleRate float64
+	// RedactKeys lists context keys whose values are replaced with "[REDACTED]".
+	// Nested keys are separated by dots, such as "user.password".
+	RedactKeys []string
+	// PolicyID optionally returns an identifier for the evaluated permissions.
+	PolicyID func(permissions interface{}, context map[string]interface{}) string
+	// ErrorHandler is optionally called with errors returned by the sink.
+	ErrorHandler func(err error)
+
+	mutex   sync.Mutex
+	pending map[uint64]*DecisionRecord
+}
+
+func NewDecisionLogger(sink DecisionSink) *DecisionLogger {
+	return &DecisionLogger{Sink: sink, SampleRate: 1}
+}
+
+func (this *DecisionLogger) OnCheckStart(event CheckStartEvent) {
+	if this.SampleRate < 1 && rand.Float64() >= this.SampleRate {
+		return
+	}
+	record := &DecisionRecord{
+		Timestamp:        time.Now(),
+		CheckID:          event.CheckID,
+		Fingerprint:      getPolicyFingerprint(event.Permissions),
+		Context:          this.redact(event.Context),
+		AllowBypass:      event.AllowBypass,
+		BypassRegistered: event.BypassRegistered,
+		Leaves:           []DecisionLeaf{},
+	}
+	if this.PolicyID != nil {
+		record.PolicyID = this.PolicyID(event.Permissions, event.Context)
+	}
+	this.mutex.Lock()
+	if this.pending == nil {
+		this.pending = make(map[uint64]*DecisionRecord)
+	}
+	this.pending[event.CheckID] = record
+	this.mutex.Unlock()
+}
+
+func (this *DecisionLogger) OnBypass(event BypassEvent) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if record, ok := this.pending[event.CheckID]; ok {
+		if event.Name != DefaultBypass {
+			if record.Bypasses == nil {
+				record.Bypasses = make(map[string]bool)
+			}
+			record.Bypasses[event.Name] = event.Access && event.Err == nil
+			return
+		}
+		record.BypassChecked = true
+		record.BypassUsed = event.Access && event.Err == nil
+	}
+}
+
+func (this *DecisionLogger) OnCallback(event CallbackEvent) {
+	leaf := DecisionLeaf{Type: event.Type, Permission: event.Permission, Access: event.Access, Cached: event.Cached, Duration: event.Duration}
+	if event.Err != nil {
+		leaf.Error = event.Err.Error()
+		leaf.ErrorType = getLeafErrorType(event.Err)
+	}
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if record, ok := this.pending[event.CheckID]; ok {
+		record.Leaves = append(record.Leaves, leaf)
+	}
+}
+
+func (this *DecisionLogger) OnCheckEnd(event CheckEndEvent) {
+	this.mutex.Lock()
+	record, ok := this.pending[event.CheckID]
+	delete(this.pending, event.CheckID)
+	this.mutex.Unlock()
+	if !ok {
+		return
+	}
+
+	record.Access = event.Access
+	record.Unknown = event.Unknown
+	record.Cached = event.Cached
+	record.Duration = event.Duration
+	if event.Err != nil {
+		record.Error = event.Err.Error()
+	}
+	if err := this.Sink.WriteDecision(record); err != nil && this.ErrorHandler != nil {
+		this.ErrorHandler(err)
+	}
+}
+
+// redact returns a copy of the context in which the values of RedactKeys are
+// replaced. Nested maps and slices are copied as well, because the record is
+// handed to sinks that may write it from other goroutines while the caller
+// reuses the context.
+func (this *DecisionLogger) redact(context map[string]interface{}) map[string]interface{} {
+	redacted := make(map[string]interface{}, len(context))
+	for key, value := range context {
+		redacted[key] = copyValue(value)
+	}
+	for _, redact_key := range this.RedactKeys {
+		segments := strings.Split(redact_key, ".")
+		current := redacted
+		for i, segment := range segments {
+			value, ok := current[segment]
+			if !ok {
+				break
+			}
+			if i == len(segments)-1 {
+				current[segment] = "[REDACTED]"
+				break
+			}
+			nested, ok := value.(map[string]interface{})
+			if !ok {
+				break
+			}
+			current = nested
+		}
+	}
+	return redacted
+}
+
+func getPolicyFingerprint(permissions interface{}) string {
+	lp := LogicalPermissions{}
+	formatted, err := lp.Format(permissions)
+	if err != nil {
+		formatted = fmt.Sprintf("%v", permissions)
+	}
+	sum := sha256.Sum256([]byte(formatted))
+	return hex.EncodeToString(sum[:])
+}
+
+// WriterSink writes decision records as json lines to an io.Writer.
+type WriterSink struct {
+	mutex  sync.Mutex
+	writer io.Writer
+}
+
+func NewWriterSink(writer io.Writer) *WriterSink {
+	return &WriterSink{writer: writer}
+}
+
+func (this *WriterSink) WriteDecision(record *DecisionRecord) error {
+	line, err := marshalDecisionRecord(record)
+	if err != nil {
+		return err
+	}
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	_, err = this.writer.Write(line)
+	return err
+}
+
+// ChannelSink sends decision records to a channel. Records are dropped if the
+// channel is full, so that access checks are never blocked.
+type ChannelSink struct {
+	channel chan<- *DecisionRecord
+}
+
+func NewChannelSink(channel chan<- *DecisionRecord) *ChannelSink {
+	return &ChannelSink{channel: channel}
+}
+
+func (this *ChannelSink) WriteDecision(record *DecisionRecord) error {
+	select {
+	case this.channel <- record:
+		return nil
+	default:
+		return errors.New("The decision record was dropped because the channel is full.")
+	}
+}
+
+// RotatingFileSink writes decision records as json lines to a local file. When
+// the file would exceed the maximum size, it is renamed with the suffix ".1"
+// and older files are shifted up to the maximum number of backups. If the file
+// cannot be rotated, records are appended to it and the error is returned.
+type RotatingFileSink struct {
+	mutex       sync.Mutex
+	filename    string
+	max_bytes   int64
+	max_backups int
+	file        *os.File
+	size        int64
+	closed      bool
+}
+
+func NewRotatingFileSink(filename string, max_bytes int64, max_backups int) (*RotatingFileSink, error) {
+	if max_bytes <= 0 {
+		return nil, &InvalidArgumentValueError{CustomError{"The max_bytes parameter must be greater than zero."}}
+	}
+	if max_backups < 0 {
+		return nil, &InvalidArgumentValueError{CustomError{"The max_backups parameter cannot be negative."}}
+	}
+	sink := &RotatingFileSink{filename: filename, max_bytes: max_bytes, max_backups: max_backups}
+	if err := sink.open(); err != nil {
+		return nil, err
+	}
+	return sink, nil
+}
+
+func (this *RotatingFileSink) WriteDecision(record *DecisionRecord) error {
+	line, err := marshalDecisionRecord(record)
+	if err != nil {
+		return err
+	}
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if this.closed {
+		return errors.New("The rotating file sink has been closed.")
+	}
+	if this.file == nil {
+		if err := this.open(); err != nil {
+			return err
+		}
+	}
+	var rotate_err error
+	if this.size > 0 && this.size+int64(len(line)) > this.max_bytes {
+		rotate_err = this.rotate()
+		if this.file == nil {
+			return rotate_err
+		}
+	}
+	n, err := this.file.Write(line)
+	this.size += int64(n)
+	if err == nil {
+		err = rotate_err
+	}
+	return err
+}
+
+func (this *RotatingFileSink) Close() error {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.closed = true
+	if this.file == nil {
+		return nil
+	}
+	err := this.file.Close()
+	this.file = nil
+	return err
+}
+
+func (this *RotatingFileSink) open() error {
+	file, err := os.OpenFile(this.filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	this.file = file
+	this.size = info.Size()
+	return nil
+}
+
+// rotate replaces the file with a new one. If the file cannot be renamed or
+// removed, it is opened again so that records can still be appended to it.
+func (this *RotatingFileSink) rotate() error {
+	err := this.file.Close()
+	this.file = nil
+	if err == nil {
+		err = this.shiftFiles()
+	}
+	if open_err := this.open(); err == nil {
+		err = open_err
+	}
+	return err
+}
+
+// shiftFiles renames the file and its backups, or removes the file if there
+// are no backups.
+func (this *RotatingFileSink) shiftFiles() error {
+	if this.max_backups == 0 {
+		if err := os.Remove(this.filename); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	for i := this.max_backups - 1; i >= 1; i-- {
+		if err := os.Rename(fmt.Sprintf("%s.%d", this.filename, i), fmt.Sprintf("%s.%d", this.filename, i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.Rename(this.filename, this.filename+".1")
+}
+
+func marshalDecisionRecord(record *DecisionRecord) ([]byte, error) {
+	line, err := json.Marshal(record)
+	if err != nil {
+		// Context values that cannot be converted to json are recorded as strings.
+		context := make(map[string]interface{}, len(record.Context))
+		for key, value := range record.Context {
+			if _, err := json.Marshal(value); err != nil {
+				value = fmt.Sprintf("%v", value)
+			}
+			context[key] = value
+		}
+		record_copy := *record
+		record_copy.Context = context
+		line, err = json.Marshal(&record_copy)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return append(line, '\n'), nil
+}
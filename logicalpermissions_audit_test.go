@@ -0,0 +1,258 @@
+package logicalpermissions_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/ordermind/logical-permissions-go"
+	"github.com/stretchr/testify/assert"
+)
+
+/*-------------DecisionLogger--------------*/
+
+func TestDecisionLoggerWriterSink(t *testing.T) {
+	t.Parallel()
+	lp := newRoleLogicalPermissions(t, nil)
+	var buffer bytes.Buffer
+	logger := NewDecisionLogger(NewWriterSink(&buffer))
+	logger.RedactKeys = []string{"token", "user.email", "missing.key"}
+	logger.PolicyID = func(permissions interface{}, context map[string]interface{}) string {
+		return "edit-article"
+	}
+	lp.AddObserver(logger)
+
+	user := map[string]interface{}{"id": 1, "email": "user@example.com"}
+	context := map[string]interface{}{"token": "secret", "user": user}
+	access, err := lp.CheckAccess(map[string]interface{}{"role": []interface{}{"editor", "guest"}}, context)
+	assert.Nil(t, err)
+	assert.False(t, access)
+	context["role"] = "superuser"
+	access, err = lp.CheckAccess(map[string]interface{}{"role": "admin"}, context)
+	assert.Nil(t, err)
+	assert.True(t, access)
+	_, err = lp.CheckAccessNoBypass(map[string]interface{}{"role": "broken"}, map[string]interface{}{})
+	assert.NotNil(t, err)
+
+	// The redaction must not modify the context itself.
+	assert.Equal(t, "secret", context["token"])
+	assert.Equal(t, "user@example.com", user["email"])
+
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	assert.Len(t, lines, 3)
+	records := make([]DecisionRecord, len(lines))
+	for i, line := range lines {
+		assert.Nil(t, json.Unmarshal([]byte(line), &records[i]))
+	}
+
+	assert.Equal(t, "edit-article", records[0].PolicyID)
+	assert.Len(t, records[0].Fingerprint, 64)
+	assert.False(t, records[0].Timestamp.IsZero())
+	assert.Equal(t, "[REDACTED]", records[0].Context["token"])
+	assert.Equal(t, map[string]interface{}{"id": float64(1), "email": "[REDACTED]"}, records[0].Context["user"])
+	assert.True(t, records[0].AllowBypass)
+	assert.True(t, records[0].BypassChecked)
+	assert.False(t, records[0].BypassUsed)
+	assert.False(t, records[0].Access)
+	assert.Equal(t, "", records[0].Error)
+	assert.Len(t, records[0].Leaves, 2)
+	assert.Equal(t, "role", records[0].Leaves[0].Type)
+	assert.Equal(t, "editor", records[0].Leaves[0].Permission)
+	assert.False(t, records[0].Leaves[0].Access)
+	assert.Equal(t, "guest", records[0].Leaves[1].Permission)
+
+	assert.NotEqual(t, records[0].Fingerprint, records[1].Fingerprint)
+	assert.True(t, records[1].BypassUsed)
+	assert.True(t, records[1].Access)
+	assert.Len(t, records[1].Leaves, 0)
+
+	assert.False(t, records[2].AllowBypass)
+	assert.False(t, records[2].BypassChecked)
+	assert.NotEqual(t, "", records[2].Error)
+	assert.Equal(t, "broken role", records[2].Leaves[0].Error)
+}
+
+func TestDecisionLoggerFingerprint(t *testing.T) {
+	t.Parallel()
+	lp := newRoleLogicalPermissions(t, nil)
+	ch := make(chan *DecisionRecord, 2)
+	lp.AddObserver(NewDecisionLogger(NewChannelSink(ch)))
+
+	// Equivalent permission trees have the same fingerprint.
+	lp.CheckAccess(map[string]interface{}{"or": []interface{}{map[string]interface{}{"role": "admin"}}}, map[string]interface{}{})
+	lp.CheckAccess(map[string]interface{}{"OR": []interface{}{map[string]interface{}{"role": "admin"}}}, map[string]interface{}{})
+	first := <-ch
+	second := <-ch
+	assert.Equal(t, first.Fingerprint, second.Fingerprint)
+	assert.Equal(t, "", first.PolicyID)
+}
+
+func TestDecisionLoggerChannelSinkContext(t *testing.T) {
+	t.Parallel()
+	lp := newRoleLogicalPermissions(t, nil)
+	ch := make(chan *DecisionRecord, 1)
+	lp.AddObserver(NewDecisionLogger(NewChannelSink(ch)))
+
+	// Records handed to the channel do not share the nested maps and slices
+	// of the context, which the caller may reuse.
+	user := map[string]interface{}{"id": 1, "groups": []interface{}{"staff"}}
+	headers := map[string][]string{"Accept": {"text/html"}}
+	lp.CheckAccess(map[string]interface{}{"role": "admin"}, map[string]interface{}{"user": user, "headers": headers})
+	user["id"] = 2
+	user["groups"].([]interface{})[0] = "admin"
+	headers["Accept"][0] = "application/json"
+	record := <-ch
+	assert.Equal(t, map[string]interface{}{"id": 1, "groups": []interface{}{"staff"}}, record.Context["user"])
+	assert.Equal(t, map[string][]string{"Accept": {"text/html"}}, record.Context["headers"])
+}
+
+func TestDecisionLoggerSampling(t *testing.T) {
+	t.Parallel()
+	lp := newRoleLogicalPermissions(t, nil)
+	var buffer bytes.Buffer
+	logger := NewDecisionLogger(NewWriterSink(&buffer))
+	logger.SampleRate = 0
+	lp.AddObserver(logger)
+	for i := 0; i < 10; i++ {
+		lp.CheckAccess(map[string]interface{}{"role": "admin"}, map[string]interface{}{})
+	}
+	assert.Equal(t, "", buffer.String())
+}
+
+func TestDecisionLoggerChannelSinkFull(t *testing.T) {
+	t.Parallel()
+	lp := newRoleLogicalPermissions(t, nil)
+	ch := make(chan *DecisionRecord, 1)
+	logger := NewDecisionLogger(NewChannelSink(ch))
+	errs := []error{}
+	logger.ErrorHandler = func(err error) {
+		errs = append(errs, err)
+	}
+	lp.AddObserver(logger)
+	lp.CheckAccess(map[string]interface{}{"role": "admin"}, map[string]interface{}{"role": "admin"})
+	lp.CheckAccess(map[string]interface{}{"role": "editor"}, map[string]interface{}{"role": "admin"})
+	assert.Len(t, errs, 1)
+	record := <-ch
+	assert.True(t, record.Access)
+}
+
+func TestDecisionLoggerUnmarshalableContext(t *testing.T) {
+	t.Parallel()
+	lp := newRoleLogicalPermissions(t, nil)
+	var buffer bytes.Buffer
+	lp.AddObserver(NewDecisionLogger(NewWriterSink(&buffer)))
+	lp.CheckAccess(map[string]interface{}{"role": "admin"}, map[string]interface{}{"callback": func() {}, "id": 1})
+	record := DecisionRecord{}
+	assert.Nil(t, json.Unmarshal(buffer.Bytes(), &record))
+	assert.IsType(t, "", record.Context["callback"])
+	assert.Equal(t, float64(1), record.Context["id"])
+}
+
+/*-------------RotatingFileSink--------------*/
+
+func TestNewRotatingFileSinkParamMaxBytes(t *testing.T) {
+	t.Parallel()
+	_, err := NewRotatingFileSink("decisions.log", 0, 1)
+	assert.IsType(t, &InvalidArgumentValueError{}, err)
+	_, err = NewRotatingFileSink("decisions.log", 100, -1)
+	assert.IsType(t, &InvalidArgumentValueError{}, err)
+}
+
+func TestRotatingFileSink(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "lpaudit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "decisions.log")
+
+	sink, err := NewRotatingFileSink(filename, 100, 2)
+	assert.Nil(t, err)
+	for i := 0; i < 4; i++ {
+		assert.Nil(t, sink.WriteDecision(&DecisionRecord{CheckID: uint64(i + 1), Context: map[string]interface{}{}}))
+	}
+	assert.Nil(t, sink.Close())
+	assert.NotNil(t, sink.WriteDecision(&DecisionRecord{}))
+
+	// Every record is larger than half the maximum size, so each one gets its own file.
+	for suffix, check_id := range map[string]uint64{"": 4, ".1": 3, ".2": 2} {
+		content, err := ioutil.ReadFile(filename + suffix)
+		assert.Nil(t, err)
+		record := DecisionRecord{}
+		assert.Nil(t, json.Unmarshal(content, &record))
+		assert.Equal(t, check_id, record.CheckID)
+	}
+	_, err = os.Stat(filename + ".3")
+	assert.True(t, os.IsNotExist(err))
+
+	// Reopening appends to the existing file.
+	sink, err = NewRotatingFileSink(filename, 1000, 0)
+	assert.Nil(t, err)
+	assert.Nil(t, sink.WriteDecision(&DecisionRecord{CheckID: 5}))
+	assert.Nil(t, sink.Close())
+	content, _ := ioutil.ReadFile(filename)
+	assert.Equal(t, 2, strings.Count(string(content), "\n"))
+}
+
+func TestRotatingFileSinkRotationError(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "lpaudit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "decisions.log")
+	// The file cannot be renamed to a directory that is not empty.
+	if err := os.MkdirAll(filepath.Join(filename+".1", "blocked"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	sink, err := NewRotatingFileSink(filename, 100, 1)
+	assert.Nil(t, err)
+	assert.Nil(t, sink.WriteDecision(&DecisionRecord{CheckID: 1, Context: map[string]interface{}{}}))
+	assert.NotNil(t, sink.WriteDecision(&DecisionRecord{CheckID: 2, Context: map[string]interface{}{}}))
+
+	// Records are still appended to the file after the rotation failed.
+	os.RemoveAll(filename + ".1")
+	assert.Nil(t, sink.WriteDecision(&DecisionRecord{CheckID: 3, Context: map[string]interface{}{}}))
+	assert.Nil(t, sink.Close())
+	records := []*DecisionRecord{}
+	for _, suffix := range []string{".1", ""} {
+		file, err := os.Open(filename + suffix)
+		if assert.Nil(t, err) {
+			file_records, err := ReadDecisionRecords(file)
+			assert.Nil(t, err)
+			records = append(records, file_records...)
+			file.Close()
+		}
+	}
+	if assert.Len(t, records, 3) {
+		assert.Equal(t, uint64(3), records[2].CheckID)
+	}
+}
+
+func TestDecisionLoggerPanic(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	lp.AddType("role", func(role string, context map[string]interface{}) (bool, error) {
+		panic("role panic")
+	})
+	var buffer bytes.Buffer
+	lp.AddObserver(NewDecisionLogger(NewWriterSink(&buffer)))
+	assert.Panics(t, func() {
+		lp.CheckAccess(map[string]interface{}{"role": "admin"}, map[string]interface{}{})
+	})
+
+	// The pending record of the access check is written when it panics.
+	records, err := ReadDecisionRecords(&buffer)
+	assert.Nil(t, err)
+	if assert.Len(t, records, 1) {
+		assert.False(t, records[0].Access)
+		assert.Contains(t, records[0].Error, "panicked")
+	}
+}
@@ -0,0 +1,126 @@
+package logicalpermissions
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+func (this *LogicalPermissions) GetTypeBatchCallback(name string) (func([]string, map[string]interface{}) (map[string]bool, error), error) {
+	if name == "" {
+		return nil, &InvalidArgumentValueError{CustomError{"The name parameter cannot be empty."}}
+	}
+	exists, _ := this.TypeExists(name)
+	if !exists {
+		return nil, &PermissionTypeNotRegisteredError{CustomError{fmt.Sprintf("The permission type \"%s\" has not been registered. Please use LogicalPermissions::AddType() or LogicalPermissions::SetTypes() to register permission types.", name)}}
+	}
+	return this.batch_callbacks[name], nil
+}
+
+func (this *LogicalPermissions) SetTypeBatchCallback(name string, callback func([]string, map[string]interface{}) (map[string]bool, error)) error {
+	if name == "" {
+		return &InvalidArgumentValueError{CustomError{"The name parameter cannot be empty."}}
+	}
+	exists, _ := this.TypeExists(name)
+	if !exists {
+		return &PermissionTypeNotRegisteredError{CustomError{fmt.Sprintf("The permission type \"%s\" has not been registered. Please use LogicalPermissions::AddType() or LogicalPermissions::SetTypes() to register permission types.", name)}}
+	}
+	if callback == nil {
+		delete(this.batch_callbacks, name)
+		return nil
+	}
+	if this.batch_callbacks == nil {
+		this.batch_callbacks = make(map[string]func([]string, map[string]interface{}) (map[string]bool, error))
+	}
+	this.batch_callbacks[name] = callback
+	return nil
+}
+
+// getBatchResult returns the result of a permission of a type with a batch
+// callback. The first time such a permission is needed during an access check,
+// every permission of the same type in the permission tree is resolved with a
+// single call to the batch callback. Results are observed when they are used
+// rather than when they are resolved. It returns false if the permission must
+// be checked with the single callback instead.
+func (this *LogicalPermissions) getBatchResult(permtype string, permission string, context map[string]interface{}, eval *evaluation) (callbackResult, bool) {
+	batch_callback := this.batch_callbacks[permtype]
+	if batch_callback == nil || eval == nil || eval.batch == nil {
+		return callbackResult{}, false
+	}
+	result, ok, duration := this.resolveBatchResult(batch_callback, permtype, permission, context, eval)
+	if ok {
+		this.observeCallback(permission, permtype, context, eval, result.access, duration, result.err)
+		if metrics := this.GetMetrics(); metrics != nil && !eval.isShadow() {
+			metrics.observeCallback(permtype, result.access, result.err, duration)
+		}
+	}
+	return result, ok
+}
+
+// resolveBatchResult returns the batch result of a permission and the duration
+// of the batch call of its type, calling the batch callback first if the type
+// has not been resolved yet. Forks that need the same type wait for the call.
+func (this *LogicalPermissions) resolveBatchResult(batch_callback func([]string, map[string]interface{}) (map[string]bool, error), permtype string, permission string, context map[string]interface{}, eval *evaluation) (callbackResult, bool, time.Duration) {
+	eval.batch.mutex.Lock()
+	defer eval.batch.mutex.Unlock()
+	if _, ok := eval.batch.durations[permtype]; !ok {
+		this.callBatchCallback(batch_callback, permtype, permission, context, eval)
+	}
+	result, ok := eval.batch.results[getCallbackResultKey(permtype, permission)]
+	return result, ok, eval.batch.durations[permtype]
+}
+
+// callBatchCallback resolves every permission of a type in the permission tree
+// of the access check with a single call to the batch callback of the type,
+// within the limits of the type options. The mutex of the batch results must
+// be locked.
+func (this *LogicalPermissions) callBatchCallback(batch_callback func([]string, map[string]interface{}) (map[string]bool, error), permtype string, permission string, context map[string]interface{}, eval *evaluation) {
+	permissions := this.getBatchPermissions(permtype, permission, eval)
+	span := this.startCallbackSpan(SpanNameBatch, context, eval, map[string]interface{}{"logicalpermissions.type": permtype, "logicalpermissions.permissions": len(permissions)})
+	start := time.Now()
+	// The results are passed through a channel because a call that times out
+	// keeps running in its own goroutine.
+	done := make(chan map[string]bool, 1)
+	_, err := this.callLimited(permtype, func() (bool, error) {
+		err := this.callRecovered(permtype, "", func() error {
+			results, err := batch_callback(permissions, context)
+			done <- results
+			return err
+		})
+		return false, err
+	})
+	var results map[string]bool
+	if err == nil {
+		results = <-done
+	}
+	eval.batch.durations[permtype] = time.Since(start)
+	if span != nil {
+		span.End(err)
+	}
+	for _, batch_permission := range permissions {
+		access, ok := results[batch_permission]
+		if err != nil {
+			access, ok = false, true
+		}
+		if ok {
+			eval.batch.results[getCallbackResultKey(permtype, batch_permission)] = callbackResult{access: access, err: err}
+		}
+	}
+}
+
+// getBatchPermissions returns every permission of a type in the permission
+// tree of the access check, including the requested permission.
+func (this *LogicalPermissions) getBatchPermissions(permtype string, permission string, eval *evaluation) []string {
+	seen := map[string]bool{permission: true}
+	permissions := []string{permission}
+	if eval.tree != nil {
+		eval.tree.walk(func(node *permissionNode, parent *permissionNode) {
+			if node.kind == TraceNodeValue && node.permtype == permtype && !seen[node.value] {
+				seen[node.value] = true
+				permissions = append(permissions, node.value)
+			}
+		})
+	}
+	sort.Strings(permissions)
+	return permissions
+}
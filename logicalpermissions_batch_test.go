@@ -0,0 +1,203 @@
+package logicalpermissions_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/ordermind/logical-permissions-go"
+	"github.com/stretchr/testify/assert"
+)
+
+type batchRecorder struct {
+	mutex   sync.Mutex
+	batches [][]string
+}
+
+func (this *batchRecorder) add(permissions []string) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.batches = append(this.batches, permissions)
+}
+
+func (this *batchRecorder) get() [][]string {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.batches
+}
+
+/*-------------LogicalPermissions::SetTypeBatchCallback()--------------*/
+
+func TestSetTypeBatchCallbackParams(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	callback := func([]string, map[string]interface{}) (map[string]bool, error) { return nil, nil }
+	err := lp.SetTypeBatchCallback("", callback)
+	assert.IsType(t, &InvalidArgumentValueError{}, err)
+	err = lp.SetTypeBatchCallback("unregistered", callback)
+	assert.IsType(t, &PermissionTypeNotRegisteredError{}, err)
+	_, err = lp.GetTypeBatchCallback("")
+	assert.IsType(t, &InvalidArgumentValueError{}, err)
+	_, err = lp.GetTypeBatchCallback("unregistered")
+	assert.IsType(t, &PermissionTypeNotRegisteredError{}, err)
+}
+
+func TestSetTypeBatchCallback(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	lp.AddType("role", func(string, map[string]interface{}) (bool, error) { return true, nil })
+	callback, err := lp.GetTypeBatchCallback("role")
+	assert.Nil(t, err)
+	assert.Nil(t, callback)
+	assert.Nil(t, lp.SetTypeBatchCallback("role", func([]string, map[string]interface{}) (map[string]bool, error) { return nil, nil }))
+	callback, _ = lp.GetTypeBatchCallback("role")
+	assert.NotNil(t, callback)
+	assert.Nil(t, lp.SetTypeBatchCallback("role", nil))
+	callback, _ = lp.GetTypeBatchCallback("role")
+	assert.Nil(t, callback)
+
+	lp.SetTypeBatchCallback("role", func([]string, map[string]interface{}) (map[string]bool, error) { return nil, nil })
+	lp.RemoveType("role")
+	lp.AddType("role", func(string, map[string]interface{}) (bool, error) { return true, nil })
+	callback, _ = lp.GetTypeBatchCallback("role")
+	assert.Nil(t, callback)
+}
+
+/*-------------Batch evaluation--------------*/
+
+func TestCheckAccessBatch(t *testing.T) {
+	t.Parallel()
+	counter := &callCounter{calls: make(map[string]int)}
+	recorder := &batchRecorder{}
+	lp := newRoleLogicalPermissions(t, counter)
+	lp.SetTypeBatchCallback("role", func(roles []string, context map[string]interface{}) (map[string]bool, error) {
+		recorder.add(roles)
+		if context["role"] == "broken" {
+			return nil, errors.New("broken batch")
+		}
+		results := make(map[string]bool)
+		for _, role := range roles {
+			// The batch callback does not know about guests.
+			if role != "guest" {
+				results[role] = role == context["role"]
+			}
+		}
+		return results, nil
+	})
+	observer := &callbackCounter{}
+	lp.AddObserver(observer)
+	permissions := map[string]interface{}{
+		"OR": []interface{}{
+			map[string]interface{}{"role": "editor"},
+			map[string]interface{}{"AND": []interface{}{
+				map[string]interface{}{"role": "admin"},
+				map[string]interface{}{"NOT": map[string]interface{}{"role": "guest"}},
+			}},
+			map[string]interface{}{"role": []interface{}{"admin", "writer"}},
+		},
+	}
+
+	access, err := lp.CheckAccess(permissions, map[string]interface{}{"role": "admin"})
+	assert.Nil(t, err)
+	assert.True(t, access)
+	// Every role in the tree is resolved with a single call, and the role that
+	// the batch callback left out falls back to the single callback.
+	assert.Equal(t, [][]string{{"admin", "editor", "guest", "writer"}}, recorder.get())
+	assert.Equal(t, 0, counter.get("role:editor"))
+	assert.Equal(t, 0, counter.get("role:admin"))
+	assert.Equal(t, 1, counter.get("role:guest"))
+	// Each evaluated permission is observed, whether it was resolved in a batch or not.
+	assert.Equal(t, 3, observer.count)
+
+	// The batch callback is not called if the bypass grants access.
+	access, err = lp.CheckAccess(permissions, map[string]interface{}{"role": "superuser"})
+	assert.Nil(t, err)
+	assert.True(t, access)
+	assert.Len(t, recorder.get(), 1)
+
+	// An error of the batch callback is an error of every permission in the batch.
+	_, err = lp.CheckAccess(permissions, map[string]interface{}{"role": "broken"})
+	assert.EqualError(t, err, "Error checking access: broken batch")
+	assert.Len(t, recorder.get(), 2)
+	assert.Equal(t, 1, counter.get("role:guest"))
+
+	// Traces and the single callback agree with the batch results.
+	_, trace, err := lp.CheckAccessNoBypassWithTrace(map[string]interface{}{"role": []interface{}{"editor", "writer"}}, map[string]interface{}{"role": "writer"})
+	assert.Nil(t, err)
+	assert.True(t, trace.Result)
+	assert.Equal(t, []string{"editor", "writer"}, recorder.get()[2])
+	assert.Equal(t, 0, counter.get("role:writer"))
+}
+
+func TestCheckAccessBatchTracing(t *testing.T) {
+	t.Parallel()
+	lp := newRoleLogicalPermissions(t, nil)
+	lp.SetTypeBatchCallback("role", func(roles []string, context map[string]interface{}) (map[string]bool, error) {
+		results := make(map[string]bool)
+		for _, role := range roles {
+			results[role] = role == context["role"]
+		}
+		return results, nil
+	})
+	recorder := &SpanRecorder{}
+	lp.SetTracer(recorder)
+	access, err := lp.CheckAccessNoBypass(map[string]interface{}{"role": []interface{}{"editor", "admin"}}, map[string]interface{}{"role": "admin"})
+	assert.Nil(t, err)
+	assert.True(t, access)
+	spans := recorder.Spans()
+	assert.Len(t, spans, 2)
+	assert.Equal(t, SpanNameBatch, spans[1].Name)
+	assert.Equal(t, spans[0].ID, spans[1].ParentID)
+	assert.Equal(t, "role", spans[1].Attributes["logicalpermissions.type"])
+	assert.Equal(t, 2, spans[1].Attributes["logicalpermissions.permissions"])
+}
+
+func TestCheckAccessBatchTypeOptions(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	lp.AddTypeWithOptions("remote", func(permission string, context map[string]interface{}) (bool, error) {
+		return true, nil
+	}, TypeOptions{Timeout: 20 * time.Millisecond, BreakerThreshold: 1, BreakerCooldown: time.Hour})
+	lp.SetTypeBatchCallback("remote", func(permissions []string, context map[string]interface{}) (map[string]bool, error) {
+		time.Sleep(200 * time.Millisecond)
+		return map[string]bool{"a": true, "b": true}, nil
+	})
+
+	// Batch calls are limited like single calls.
+	start := time.Now()
+	access, err := lp.CheckAccess(map[string]interface{}{"remote": []interface{}{"a", "b"}}, map[string]interface{}{})
+	assert.True(t, time.Since(start) < 150*time.Millisecond)
+	assert.False(t, access)
+	assert.IsType(t, &CallbackTimeoutError{}, err)
+	assert.EqualError(t, err, "Error checking access: The callback of the permission type \"remote\" did not return within 20ms.")
+	_, err = lp.CheckAccess(map[string]interface{}{"remote": "a"}, map[string]interface{}{})
+	assert.IsType(t, &CircuitOpenError{}, err)
+}
+
+func TestCheckAccessBatchPanic(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	lp.AddType("role", func(role string, context map[string]interface{}) (bool, error) {
+		return false, nil
+	})
+	lp.SetTypeBatchCallback("role", func(roles []string, context map[string]interface{}) (map[string]bool, error) {
+		time.Sleep(20 * time.Millisecond)
+		panic("batch panic")
+	})
+	lp.SetParallelism(2)
+
+	// Parallel siblings waiting for the batch call are released by a panic.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.PanicsWithValue(t, "batch panic", func() {
+			lp.CheckAccess(map[string]interface{}{"OR": []interface{}{map[string]interface{}{"role": "admin"}, map[string]interface{}{"role": "editor"}}}, map[string]interface{}{})
+		})
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The access check did not return after the batch callback panicked.")
+	}
+}
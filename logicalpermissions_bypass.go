@@ -0,0 +1,284 @@
+package logicalpermissions
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DefaultBypass is the name of the bypass registered with
+// SetBypassCallback(), which NO_BYPASS slices and traces refer to.
+const DefaultBypass = "default"
+
+func (this *LogicalPermissions) AddBypass(name string, callback func(map[string]interface{}) (bool, error)) error {
+	if name == "" {
+		return &InvalidArgumentValueError{CustomError{"The name parameter cannot be empty."}}
+	}
+	if name == DefaultBypass || name == "*" || strings.HasPrefix(name, "!") {
+		return &InvalidArgumentValueError{CustomError{fmt.Sprintf("The name parameter has the illegal value \"%s\". It cannot be \"%s\" or \"*\", and it cannot start with \"!\".", name, DefaultBypass)}}
+	}
+	exists, _ := this.BypassExists(name)
+	if exists {
+		return &BypassAlreadyExistsError{CustomError{fmt.Sprintf("The bypass \"%s\" already exists! If you want to change its callback, please remove it with LogicalPermissions::RemoveBypass() first.", name)}}
+	}
+	if this.bypasses == nil {
+		this.bypasses = make(map[string]func(map[string]interface{}) (bool, error))
+	}
+	this.bypasses[name] = callback
+	return nil
+}
+
+func (this *LogicalPermissions) RemoveBypass(name string) error {
+	if name == "" {
+		return &InvalidArgumentValueError{CustomError{"The name parameter cannot be empty."}}
+	}
+	exists, _ := this.BypassExists(name)
+	if !exists {
+		return &BypassNotRegisteredError{CustomError{fmt.Sprintf("The bypass \"%s\" has not been registered. Please use LogicalPermissions::AddBypass() to register bypasses.", name)}}
+	}
+	delete(this.bypasses, name)
+	return nil
+}
+
+func (this *LogicalPermissions) BypassExists(name string) (bool, error) {
+	if name == "" {
+		return false, &InvalidArgumentValueError{CustomError{"The name parameter cannot be empty."}}
+	}
+	_, exists := this.bypasses[name]
+	return exists, nil
+}
+
+func (this *LogicalPermissions) GetBypasses() map[string]func(map[string]interface{}) (bool, error) {
+	bypasses := make(map[string]func(map[string]interface{}) (bool, error))
+	for name, callback := range this.bypasses {
+		bypasses[name] = callback
+	}
+	return bypasses
+}
+
+// getBypassNames returns the names of the registered bypasses in the order in
+// which they are checked: the default bypass first, then the named bypasses in
+// alphabetical order.
+func (this *LogicalPermissions) getBypassNames() []string {
+	names := make([]string, 0, len(this.bypasses)+1)
+	for name := range this.bypasses {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if this.GetBypassCallback() != nil {
+		names = append([]string{DefaultBypass}, names...)
+	}
+	return names
+}
+
+func (this *LogicalPermissions) getBypassCallback(name string) func(map[string]interface{}) (bool, error) {
+	if name == DefaultBypass {
+		return this.GetBypassCallback()
+	}
+	return this.bypasses[name]
+}
+
+// getNoBypassSlice returns a NO_BYPASS value if it is a slice of bypass names.
+func (this *LogicalPermissions) getNoBypassSlice(no_bypass interface{}) ([]interface{}, bool) {
+	slice_value, ok := no_bypass.([]interface{})
+	return slice_value, ok
+}
+
+// bypassRefusal is a parsed NO_BYPASS slice. It refuses the bypasses in
+// refused, or every bypass if all is true, and accepts the named bypasses in
+// accepted.
+type bypassRefusal struct {
+	all      bool
+	refused  []string
+	accepted []string
+}
+
+// apply returns the bypasses that apply to the permissions below a NO_BYPASS
+// slice and the bypasses that NO_BYPASS slices further down can still accept,
+// given those of the enclosing permissions. A refused bypass cannot be
+// accepted again further down.
+func (this *bypassRefusal) apply(applied []string, available []string) ([]string, []string) {
+	contains := func(names []string, name string) bool {
+		for _, other := range names {
+			if other == name {
+				return true
+			}
+		}
+		return false
+	}
+	new_applied, new_available := []string{}, []string{}
+	for _, name := range available {
+		accepted := contains(this.accepted, name)
+		if (this.all && !accepted) || contains(this.refused, name) {
+			continue
+		}
+		new_available = append(new_available, name)
+		if accepted || contains(applied, name) {
+			new_applied = append(new_applied, name)
+		}
+	}
+	return new_applied, new_available
+}
+
+// parseBypassRefusal parses a NO_BYPASS slice, whose elements are names of
+// bypasses that are refused, "*" for all bypasses, or names prefixed with "!"
+// of bypasses that are accepted. The names in refused and accepted are sorted,
+// refused is empty if all is true, and it never contains accepted names.
+func (this *LogicalPermissions) parseBypassRefusal(no_bypass []interface{}) (*bypassRefusal, CustomErrorInterface) {
+	refusal := &bypassRefusal{refused: []string{}, accepted: []string{}}
+	refused := []string{}
+	for _, value := range no_bypass {
+		name, ok := value.(string)
+		if !ok || name == "" || name == "!" || name == "!*" {
+			return nil, &InvalidArgumentValueError{CustomError{fmt.Sprintf("The NO_BYPASS slice can only contain bypass names, \"*\" and bypass names prefixed with \"!\". Current value: %v", no_bypass)}}
+		}
+		if name == "*" {
+			refusal.all = true
+			continue
+		}
+		bypass_name := strings.TrimPrefix(name, "!")
+		if exists, _ := this.BypassExists(bypass_name); !exists && bypass_name != DefaultBypass {
+			return nil, &BypassNotRegisteredError{CustomError{fmt.Sprintf("The bypass \"%s\" in the NO_BYPASS slice has not been registered. Please use LogicalPermissions::AddBypass() to register bypasses. Current value: %v", bypass_name, no_bypass)}}
+		}
+		if bypass_name != name {
+			refusal.accepted = append(refusal.accepted, bypass_name)
+		} else {
+			refused = append(refused, name)
+		}
+	}
+	for _, name := range refused {
+		if !refusal.all && !this.stringInSlice(name, refusal.accepted) && !this.stringInSlice(name, refusal.refused) {
+			refusal.refused = append(refusal.refused, name)
+		}
+	}
+	sort.Strings(refusal.refused)
+	sort.Strings(refusal.accepted)
+	return refusal, nil
+}
+
+// getAcceptedBypasses returns the names of the bypasses that the NO_BYPASS
+// slices of permissions accept at any level, and validates the slices.
+func (this *LogicalPermissions) getAcceptedBypasses(permissions interface{}) ([]string, CustomErrorInterface) {
+	accepted := []string{}
+	if slice_permissions, ok := permissions.([]interface{}); ok {
+		for _, permission := range slice_permissions {
+			names, err_custom := this.getAcceptedBypasses(permission)
+			if err_custom != nil {
+				return nil, err_custom
+			}
+			accepted = append(accepted, names...)
+		}
+	} else if map_permissions, ok := permissions.(map[string]interface{}); ok {
+		for key, value := range map_permissions {
+			if slice_value, ok := this.getNoBypassSlice(value); ok && strings.ToUpper(key) == "NO_BYPASS" {
+				refusal, err_custom := this.parseBypassRefusal(slice_value)
+				if err_custom != nil {
+					return nil, err_custom
+				}
+				accepted = append(accepted, refusal.accepted...)
+				continue
+			}
+			names, err_custom := this.getAcceptedBypasses(value)
+			if err_custom != nil {
+				return nil, err_custom
+			}
+			accepted = append(accepted, names...)
+		}
+	}
+	return accepted, nil
+}
+
+// getBypassCandidates returns the names of the bypasses that may apply to
+// permissions, in the order in which they are checked: the default bypass,
+// which applies everywhere, and the named bypasses that a NO_BYPASS slice
+// accepts somewhere in the permission tree.
+func (this *LogicalPermissions) getBypassCandidates(map_permissions map[string]interface{}) ([]string, CustomErrorInterface) {
+	accepted, err_custom := this.getAcceptedBypasses(map_permissions)
+	if err_custom != nil {
+		return nil, err_custom
+	}
+	candidates := []string{}
+	for _, name := range this.getBypassNames() {
+		if (name == DefaultBypass && this.GetBypassCallback() != nil) || this.stringInSlice(name, accepted) {
+			candidates = append(candidates, name)
+		}
+	}
+	return candidates, nil
+}
+
+// getAllowedBypasses applies a NO_BYPASS value to the bypasses that apply to
+// the enclosing permissions and the bypasses that are available to NO_BYPASS
+// slices further down. A slice refuses or accepts the named bypasses, and any
+// other value allows either all of them or none.
+func (this *LogicalPermissions) getAllowedBypasses(no_bypass interface{}, applied []string, available []string, context map[string]interface{}, eval *evaluation) ([]string, []string, CustomErrorInterface) {
+	if slice_value, ok := this.getNoBypassSlice(no_bypass); ok {
+		refusal, err_custom := this.parseBypassRefusal(slice_value)
+		if err_custom != nil {
+			return nil, nil, err_custom
+		}
+		applied, available = refusal.apply(applied, available)
+		return applied, available, nil
+	}
+	allow_bypass, err_custom := this.checkAllowBypass(no_bypass, context, eval)
+	if err_custom != nil {
+		return nil, nil, err_custom
+	}
+	if !allow_bypass {
+		return []string{}, []string{}, nil
+	}
+	return applied, available, nil
+}
+
+// intersectBypasses returns the names that are also granted, in their order.
+func (this *LogicalPermissions) intersectBypasses(names []string, granted []string) []string {
+	intersection := []string{}
+	for _, name := range names {
+		if this.stringInSlice(name, granted) {
+			intersection = append(intersection, name)
+		}
+	}
+	return intersection
+}
+
+// checkBypassAccess calls the callbacks of the named bypasses in order and
+// returns the names of the bypasses that grant access. If first is true, it
+// stops at the first bypass that grants access. If no bypass grants access but
+// the result of a callback is unknown, the unknown result is returned.
+func (this *LogicalPermissions) checkBypassAccess(names []string, first bool, context map[string]interface{}, eval *evaluation) ([]string, CustomErrorInterface) {
+	granted := []string{}
+	var unknown *unknownResult
+	for _, name := range names {
+		callback := this.getBypassCallback(name)
+		key, permission := bypassResultKey, ""
+		if name != DefaultBypass {
+			key, permission = bypassResultKey+name, name
+		}
+		result, ok := eval.getCallbackResult(key)
+		if ok {
+			this.observeCachedResult("", permission, context, eval, result)
+		} else {
+			result = this.getCachedResult("", permission, context, eval, func() callbackResult {
+				return this.callBypassCallback(name, callback, context, eval)
+			})
+			eval.setCallbackResult(key, result)
+		}
+		if result.err == ErrUnknown && this.three_valued {
+			unknown = mergeUnknown(unknown, newUnknownResult())
+			continue
+		}
+		if result.err != nil {
+			eval.setErrorCategory(MetricsErrorBypassCallback)
+			return nil, getCallbackError(result.err)
+		}
+		if result.access {
+			granted = append(granted, name)
+			if first {
+				break
+			}
+		}
+	}
+	if len(granted) == 0 && unknown != nil {
+		return nil, unknown
+	}
+	return granted, nil
+}
@@ -0,0 +1,309 @@
+package logicalpermissions_test
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/ordermind/logical-permissions-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// staffBypass returns a bypass callback that counts its calls as
+// "bypass:<name>" and grants access if context["staff"] is the given staff.
+func (this *callCounter) staffBypass(name string, staff string) func(map[string]interface{}) (bool, error) {
+	return func(context map[string]interface{}) (bool, error) {
+		this.add("bypass:" + name)
+		return context["staff"] == staff, nil
+	}
+}
+
+/*-------------LogicalPermissions::AddBypass()--------------*/
+
+func TestAddBypass(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	callback := func(context map[string]interface{}) (bool, error) { return true, nil }
+	for _, name := range []string{"", DefaultBypass, "*", "!support"} {
+		err := lp.AddBypass(name, callback)
+		assert.IsType(t, &InvalidArgumentValueError{}, err)
+	}
+	assert.Nil(t, lp.AddBypass("support", callback))
+	err := lp.AddBypass("support", callback)
+	assert.IsType(t, &BypassAlreadyExistsError{}, err)
+
+	exists, err := lp.BypassExists("support")
+	assert.Nil(t, err)
+	assert.True(t, exists)
+	_, err = lp.BypassExists("")
+	assert.IsType(t, &InvalidArgumentValueError{}, err)
+	bypasses := lp.GetBypasses()
+	assert.Len(t, bypasses, 1)
+	assert.Contains(t, bypasses, "support")
+}
+
+/*-------------LogicalPermissions::RemoveBypass()--------------*/
+
+func TestRemoveBypass(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	err := lp.RemoveBypass("")
+	assert.IsType(t, &InvalidArgumentValueError{}, err)
+	err = lp.RemoveBypass("support")
+	assert.IsType(t, &BypassNotRegisteredError{}, err)
+	lp.AddBypass("support", func(context map[string]interface{}) (bool, error) { return true, nil })
+	assert.Nil(t, lp.RemoveBypass("support"))
+	exists, _ := lp.BypassExists("support")
+	assert.False(t, exists)
+
+	access, err := lp.CheckAccess(false, map[string]interface{}{})
+	assert.Nil(t, err)
+	assert.False(t, access)
+
+	// NO_BYPASS slices stay valid after the last named bypass is removed.
+	lp.SetBypassCallback(func(context map[string]interface{}) (bool, error) { return true, nil })
+	access, err = lp.CheckAccess(map[string]interface{}{"NO_BYPASS": []interface{}{"*"}, "OR": []interface{}{false}}, map[string]interface{}{})
+	assert.Nil(t, err)
+	assert.False(t, access)
+	access, err = lp.CheckAccess(map[string]interface{}{"NO_BYPASS": []interface{}{"default"}, "OR": []interface{}{false}}, map[string]interface{}{})
+	assert.Nil(t, err)
+	assert.False(t, access)
+	access, err = lp.CheckAccess(map[string]interface{}{"NO_BYPASS": []interface{}{}, "OR": []interface{}{false}}, map[string]interface{}{})
+	assert.Nil(t, err)
+	assert.True(t, access)
+	_, err = lp.CheckAccess(map[string]interface{}{"NO_BYPASS": []interface{}{"support"}, "OR": []interface{}{false}}, map[string]interface{}{})
+	assert.IsType(t, &BypassNotRegisteredError{}, err)
+}
+
+/*-------------Named bypasses--------------*/
+
+func TestNamedBypass(t *testing.T) {
+	t.Parallel()
+	counter := &callCounter{calls: make(map[string]int)}
+	lp := LogicalPermissions{}
+	lp.AddType("role", counter.contextValue)
+	lp.AddType("flag", counter.contextValue)
+	lp.SetBypassCallback(counter.staffBypass("default", "superuser"))
+	lp.AddBypass("support", counter.staffBypass("support", "support"))
+	lp.AddBypass("auditor", counter.staffBypass("auditor", "auditor"))
+
+	// Named bypasses only apply where a NO_BYPASS slice accepts them.
+	access, err := lp.CheckAccess(`{"role": "admin"}`, map[string]interface{}{"staff": "support"})
+	assert.Nil(t, err)
+	assert.False(t, access)
+	assert.Equal(t, 1, counter.get("admin"))
+	assert.Equal(t, 0, counter.get("bypass:support"))
+	access, err = lp.CheckAccess(`{"role": "admin"}`, map[string]interface{}{"staff": "superuser"})
+	assert.Nil(t, err)
+	assert.True(t, access)
+	assert.Equal(t, 1, counter.get("admin"))
+
+	tests := []struct {
+		no_bypass interface{}
+		staff     string
+		access    bool
+	}{
+		{[]interface{}{"!support"}, "support", true},
+		{[]interface{}{"!support"}, "superuser", true},
+		{[]interface{}{"!support"}, "auditor", false},
+		{[]interface{}{"*", "!support"}, "support", true},
+		{[]interface{}{"*", "!support"}, "superuser", false},
+		{[]interface{}{"default", "!auditor"}, "auditor", true},
+		{[]interface{}{"default", "!auditor"}, "superuser", false},
+		{[]interface{}{"support", "!support"}, "support", true},
+		{[]interface{}{"support"}, "support", false},
+		{[]interface{}{"*"}, "superuser", false},
+		{[]interface{}{}, "superuser", true},
+		{true, "superuser", false},
+		{false, "superuser", true},
+	}
+	for _, test := range tests {
+		access, err := lp.CheckAccess(map[string]interface{}{"NO_BYPASS": test.no_bypass, "role": "admin"}, map[string]interface{}{"staff": test.staff})
+		assert.Nil(t, err)
+		assert.Equal(t, test.access, access, "NO_BYPASS %v, staff %s", test.no_bypass, test.staff)
+	}
+}
+
+func TestNamedBypassScoped(t *testing.T) {
+	t.Parallel()
+	counter := &callCounter{calls: make(map[string]int)}
+	lp := LogicalPermissions{}
+	lp.AddType("role", counter.contextValue)
+	lp.AddType("flag", counter.contextValue)
+	lp.SetBypassCallback(counter.staffBypass("default", "superuser"))
+	lp.AddBypass("support", counter.staffBypass("support", "support"))
+	lp.AddBypass("auditor", counter.staffBypass("auditor", "auditor"))
+	permissions := `{"AND": [{"NO_BYPASS": ["!support"], "role": "editor"}, {"flag": "write"}]}`
+
+	// Support staff bypass the role, but not the write flag.
+	access, err := lp.CheckAccess(permissions, map[string]interface{}{"staff": "support"})
+	assert.Nil(t, err)
+	assert.False(t, access)
+	assert.Equal(t, 0, counter.get("editor"))
+	assert.Equal(t, 1, counter.get("write"))
+	access, err = lp.CheckAccess(permissions, map[string]interface{}{"staff": "support", "write": true})
+	assert.Nil(t, err)
+	assert.True(t, access)
+	assert.Equal(t, 0, counter.get("editor"))
+
+	// The default bypass applies everywhere.
+	access, err = lp.CheckAccess(permissions, map[string]interface{}{"staff": "superuser"})
+	assert.Nil(t, err)
+	assert.True(t, access)
+	assert.Equal(t, 0, counter.get("editor"))
+	assert.Equal(t, 2, counter.get("write"))
+
+	// Every accepted bypass is checked if a NO_BYPASS key below the root may
+	// refuse or accept some of them, but bypasses that no NO_BYPASS slice
+	// accepts are never checked.
+	assert.Equal(t, 3, counter.get("bypass:default"))
+	assert.Equal(t, 3, counter.get("bypass:support"))
+	assert.Equal(t, 0, counter.get("bypass:auditor"))
+
+	// A refused bypass cannot be accepted further down, and bypass access does
+	// not apply below NOT gates.
+	access, err = lp.CheckAccess(`{"NO_BYPASS": ["*"], "AND": [{"NO_BYPASS": ["!support"], "role": "editor"}]}`, map[string]interface{}{"staff": "support"})
+	assert.Nil(t, err)
+	assert.False(t, access)
+	assert.Equal(t, 1, counter.get("editor"))
+	access, err = lp.CheckAccess(`{"NOT": {"NO_BYPASS": ["!support"], "role": "banned"}}`, map[string]interface{}{"staff": "support", "banned": true})
+	assert.Nil(t, err)
+	assert.False(t, access)
+	assert.Equal(t, 1, counter.get("banned"))
+}
+
+func TestNamedBypassTrace(t *testing.T) {
+	t.Parallel()
+	counter := &callCounter{calls: make(map[string]int)}
+	lp := LogicalPermissions{}
+	lp.AddType("role", counter.contextValue)
+	lp.AddType("flag", counter.contextValue)
+	lp.SetBypassCallback(counter.staffBypass("default", "superuser"))
+	lp.AddBypass("support", counter.staffBypass("support", "support"))
+	lp.AddBypass("auditor", counter.staffBypass("auditor", "auditor"))
+	access, trace, err := lp.CheckAccessWithTrace(`{"NO_BYPASS": ["!support"], "role": "admin"}`, map[string]interface{}{"staff": "support"})
+	assert.Nil(t, err)
+	assert.True(t, access)
+	assert.True(t, trace.BypassAccess)
+	assert.Equal(t, "support", trace.Bypass)
+	assert.Equal(t, "Result: true\nBypass: granted by support\n", trace.String())
+
+	permissions := `{"AND": [{"NO_BYPASS": ["!support"], "role": "editor"}, {"flag": "write"}]}`
+	access, trace, err = lp.CheckAccessWithTrace(permissions, map[string]interface{}{"staff": "support", "write": true})
+	assert.Nil(t, err)
+	assert.True(t, access)
+	assert.Equal(t, `Result: true
+Bypass: granted by support
+Permissions:
+  OR []: true
+    AND [/AND]: true
+      NO_BYPASS [/AND/0]: true
+        BYPASS (support) [/AND/0/role]: true
+      flag: "write" [/AND/1/flag]: true
+`, trace.String())
+	assert.Equal(t, "support", trace.Root.Children[0].Children[0].Children[0].Value)
+
+	dot, err := lp.ExportDOT(permissions, trace)
+	assert.Nil(t, err)
+	assert.Contains(t, dot, "bypass granted by support")
+	assert.Contains(t, dot, "NO_BYPASS: [!support]")
+}
+
+func TestNamedBypassErrors(t *testing.T) {
+	t.Parallel()
+	counter := &callCounter{calls: make(map[string]int)}
+	lp := LogicalPermissions{}
+	lp.AddType("role", counter.contextValue)
+	lp.AddType("flag", counter.contextValue)
+	lp.SetBypassCallback(counter.staffBypass("default", "superuser"))
+	lp.AddBypass("support", counter.staffBypass("support", "support"))
+	lp.AddBypass("auditor", counter.staffBypass("auditor", "auditor"))
+	tests := []struct {
+		no_bypass []interface{}
+		err       error
+	}{
+		{[]interface{}{"!support", 5}, &InvalidArgumentValueError{}},
+		{[]interface{}{""}, &InvalidArgumentValueError{}},
+		{[]interface{}{"!"}, &InvalidArgumentValueError{}},
+		{[]interface{}{"*", "!*"}, &InvalidArgumentValueError{}},
+		{[]interface{}{"suport"}, &BypassNotRegisteredError{}},
+		{[]interface{}{"*", "!suport"}, &BypassNotRegisteredError{}},
+	}
+	for _, test := range tests {
+		permissions := map[string]interface{}{"NO_BYPASS": test.no_bypass, "role": "admin"}
+		access, err := lp.CheckAccess(permissions, map[string]interface{}{"staff": "support"})
+		assert.False(t, access)
+		assert.IsType(t, test.err, err)
+		assert.NotEmpty(t, lp.Lint(permissions))
+
+		// The NO_BYPASS slices below the root are checked even if no bypass
+		// grants access.
+		permissions = map[string]interface{}{"OR": []interface{}{map[string]interface{}{"NO_BYPASS": test.no_bypass, "role": "admin"}}}
+		_, err = lp.CheckAccess(permissions, map[string]interface{}{})
+		assert.IsType(t, test.err, err)
+		assert.NotEmpty(t, lp.Lint(permissions))
+	}
+	_, err := lp.CheckAccess(map[string]interface{}{"NO_BYPASS": []interface{}{"suport"}, "role": "admin"}, map[string]interface{}{})
+	assert.EqualError(t, err, "The bypass \"suport\" in the NO_BYPASS slice has not been registered. Please use LogicalPermissions::AddBypass() to register bypasses. Current value: [suport]")
+}
+
+func TestNamedBypassDescribe(t *testing.T) {
+	t.Parallel()
+	counter := &callCounter{calls: make(map[string]int)}
+	lp := LogicalPermissions{}
+	lp.AddType("role", counter.contextValue)
+	lp.AddType("flag", counter.contextValue)
+	lp.SetBypassCallback(counter.staffBypass("default", "superuser"))
+	lp.AddBypass("support", counter.staffBypass("support", "support"))
+	lp.AddBypass("auditor", counter.staffBypass("auditor", "auditor"))
+	tests := []struct {
+		permissions string
+		description string
+	}{
+		{`{"NO_BYPASS": ["!support"], "role": "admin"}`, "Requires role admin. Access can also be bypassed by support."},
+		{`{"NO_BYPASS": ["*", "!support", "!auditor"], "role": "admin"}`, "Requires role admin. Access can only be bypassed by auditor or support."},
+		{`{"NO_BYPASS": ["default", "!support"], "role": "admin"}`, "Requires role admin. Access cannot be bypassed by default but can also be bypassed by support."},
+		{`{"NO_BYPASS": ["*"], "role": "admin"}`, "Requires role admin. Access cannot be bypassed."},
+		{`{"NO_BYPASS": [], "role": "admin"}`, "Requires role admin."},
+		{`{"AND": [{"NO_BYPASS": ["!support"], "role": "editor"}, {"flag": "write"}]}`, "Requires role editor (which can also be bypassed by support) and flag write."},
+	}
+	for _, test := range tests {
+		description, err := lp.Describe(test.permissions)
+		assert.Nil(t, err)
+		assert.Equal(t, test.description, description)
+	}
+}
+
+func TestNamedBypassAudit(t *testing.T) {
+	t.Parallel()
+	counter := &callCounter{calls: make(map[string]int)}
+	lp := LogicalPermissions{}
+	lp.AddType("role", counter.contextValue)
+	lp.AddType("flag", counter.contextValue)
+	lp.SetBypassCallback(counter.staffBypass("default", "superuser"))
+	lp.AddBypass("support", counter.staffBypass("support", "support"))
+	lp.AddBypass("auditor", counter.staffBypass("auditor", "auditor"))
+	var buffer bytes.Buffer
+	logger := NewDecisionLogger(NewWriterSink(&buffer))
+	logger.PolicyID = func(permissions interface{}, context map[string]interface{}) string {
+		return "edit"
+	}
+	lp.AddObserver(logger)
+	access, err := lp.CheckAccess(`{"NO_BYPASS": ["!support"], "role": "admin"}`, map[string]interface{}{"staff": "support"})
+	assert.Nil(t, err)
+	assert.True(t, access)
+
+	records, err := ReadDecisionRecords(&buffer)
+	assert.Nil(t, err)
+	if assert.Len(t, records, 1) {
+		assert.True(t, records[0].BypassChecked)
+		assert.False(t, records[0].BypassUsed)
+		assert.Equal(t, map[string]bool{"support": true}, records[0].Bypasses)
+	}
+
+	// The recorded results of named bypasses are replayed. Without the named
+	// bypass the candidate needs a role that was not checked.
+	report := ReplayDecisions(records, map[string]interface{}{"edit": `{"role": "admin"}`})
+	assert.Equal(t, 1, report.Inconclusive)
+	report = ReplayDecisions(records, map[string]interface{}{"edit": `{"NO_BYPASS": ["!support"], "role": "editor"}`})
+	assert.Equal(t, 1, report.Unchanged)
+}
@@ -0,0 +1,352 @@
+package logicalpermissions
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache stores access results across access checks. Entries are tagged so that
+// they can be invalidated in groups. Errors are never cached.
+type Cache interface {
+	// Get returns the cached access and whether the key was found.
+	Get(key string) (bool, bool)
+	// Set stores the access under the key together with its tags.
+	Set(key string, access bool, tags []string)
+	// Invalidate removes every entry that has the tag.
+	Invalidate(tag string)
+	// Clear removes every entry.
+	Clear()
+}
+
+// LRUCache is an in-memory Cache that evicts the least recently used entry
+// when it is full, and expires entries after a time to live.
+type LRUCache struct {
+	mutex    sync.Mutex
+	capacity int
+	ttl      time.Duration
+	order    *list.List
+	entries  map[string]*list.Element
+	tags     map[string]map[string]bool
+}
+
+type lruCacheEntry struct {
+	key     string
+	access  bool
+	tags    []string
+	expires time.Time
+}
+
+// NewLRUCache creates an LRUCache that holds at most capacity entries. Entries
+// expire after ttl, or never if ttl is zero.
+func NewLRUCache(capacity int, ttl time.Duration) (*LRUCache, error) {
+	if capacity <= 0 {
+		return nil, &InvalidArgumentValueError{CustomError{"The capacity parameter must be greater than zero."}}
+	}
+	if ttl < 0 {
+		return nil, &InvalidArgumentValueError{CustomError{"The ttl parameter cannot be negative."}}
+	}
+	return &LRUCache{capacity: capacity, ttl: ttl, order: list.New(), entries: make(map[string]*list.Element), tags: make(map[string]map[string]bool)}, nil
+}
+
+func (this *LRUCache) Get(key string) (bool, bool) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	element, ok := this.entries[key]
+	if !ok {
+		return false, false
+	}
+	entry := element.Value.(*lruCacheEntry)
+	if this.ttl > 0 && time.Now().After(entry.expires) {
+		this.remove(element)
+		return false, false
+	}
+	this.order.MoveToFront(element)
+	return entry.access, true
+}
+
+func (this *LRUCache) Set(key string, access bool, tags []string) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if element, ok := this.entries[key]; ok {
+		this.remove(element)
+	}
+	entry := &lruCacheEntry{key: key, access: access, tags: tags, expires: time.Now().Add(this.ttl)}
+	this.entries[key] = this.order.PushFront(entry)
+	for _, tag := range tags {
+		if this.tags[tag] == nil {
+			this.tags[tag] = make(map[string]bool)
+		}
+		this.tags[tag][key] = true
+	}
+	for this.order.Len() > this.capacity {
+		this.remove(this.order.Back())
+	}
+}
+
+func (this *LRUCache) Invalidate(tag string) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	for key := range this.tags[tag] {
+		this.remove(this.entries[key])
+	}
+}
+
+func (this *LRUCache) Clear() {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.order.Init()
+	this.entries = make(map[string]*list.Element)
+	this.tags = make(map[string]map[string]bool)
+}
+
+// Len returns the number of entries, including expired entries that have not
+// been removed yet.
+func (this *LRUCache) Len() int {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.order.Len()
+}
+
+func (this *LRUCache) remove(element *list.Element) {
+	entry := element.Value.(*lruCacheEntry)
+	this.order.Remove(element)
+	delete(this.entries, entry.key)
+	for _, tag := range entry.tags {
+		delete(this.tags[tag], entry.key)
+		if len(this.tags[tag]) == 0 {
+			delete(this.tags, tag)
+		}
+	}
+}
+
+func (this *LogicalPermissions) GetCache() Cache {
+	return this.cache
+}
+
+func (this *LogicalPermissions) SetCache(cache Cache) {
+	this.cache = cache
+}
+
+func (this *LogicalPermissions) GetTypeCacheKeys(name string) ([]string, error) {
+	if name == "" {
+		return nil, &InvalidArgumentValueError{CustomError{"The name parameter cannot be empty."}}
+	}
+	exists, _ := this.TypeExists(name)
+	if !exists {
+		return nil, &PermissionTypeNotRegisteredError{CustomError{fmt.Sprintf("The permission type \"%s\" has not been registered. Please use LogicalPermissions::AddType() or LogicalPermissions::SetTypes() to register permission types.", name)}}
+	}
+	return this.copyCacheKeys(this.type_cache_keys[name]), nil
+}
+
+func (this *LogicalPermissions) SetTypeCacheKeys(name string, context_keys []string) error {
+	if name == "" {
+		return &InvalidArgumentValueError{CustomError{"The name parameter cannot be empty."}}
+	}
+	exists, _ := this.TypeExists(name)
+	if !exists {
+		return &PermissionTypeNotRegisteredError{CustomError{fmt.Sprintf("The permission type \"%s\" has not been registered. Please use LogicalPermissions::AddType() or LogicalPermissions::SetTypes() to register permission types.", name)}}
+	}
+	if context_keys == nil {
+		delete(this.type_cache_keys, name)
+		return nil
+	}
+	if this.type_cache_keys == nil {
+		this.type_cache_keys = make(map[string][]string)
+	}
+	this.type_cache_keys[name] = this.copyCacheKeys(context_keys)
+	return nil
+}
+
+func (this *LogicalPermissions) GetBypassCacheKeys() []string {
+	return this.copyCacheKeys(this.bypass_cache_keys)
+}
+
+func (this *LogicalPermissions) SetBypassCacheKeys(context_keys []string) {
+	this.bypass_cache_keys = this.copyCacheKeys(context_keys)
+}
+
+func (this *LogicalPermissions) GetCacheDecisions() bool {
+	return this.cache_decisions
+}
+
+func (this *LogicalPermissions) SetCacheDecisions(cache_decisions bool) {
+	this.cache_decisions = cache_decisions
+}
+
+func (this *LogicalPermissions) InvalidateCacheType(name string) {
+	if this.cache != nil {
+		this.cache.Invalidate("type:" + name)
+	}
+}
+
+func (this *LogicalPermissions) InvalidateCacheBypass() {
+	if this.cache != nil {
+		this.cache.Invalidate("bypass")
+	}
+}
+
+func (this *LogicalPermissions) InvalidateCacheSubject(context_key string, value interface{}) {
+	if this.cache != nil {
+		this.cache.Invalidate(this.getCacheSubjectTag(context_key, this.encodeCacheValue(value)))
+	}
+}
+
+// copyCacheKeys copies context keys, keeping nil for undeclared keys and an
+// empty slice for results that do not depend on the context.
+func (this *LogicalPermissions) copyCacheKeys(context_keys []string) []string {
+	if context_keys == nil {
+		return nil
+	}
+	keys := make([]string, len(context_keys))
+	copy(keys, context_keys)
+	return keys
+}
+
+func (this *LogicalPermissions) getCacheSubjectTag(context_key string, encoded_value string) string {
+	return "subject:" + context_key + "=" + encoded_value
+}
+
+func (this *LogicalPermissions) encodeCacheValue(value interface{}) string {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(encoded)
+}
+
+// getContextValue returns the value of a context key, where nested keys are
+// separated by dots, such as "user.id".
+func (this *LogicalPermissions) getContextValue(context map[string]interface{}, context_key string) interface{} {
+	var value interface{} = context
+	for _, segment := range strings.Split(context_key, ".") {
+		map_value, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = map_value[segment]
+	}
+	return value
+}
+
+// getCacheKey derives a cache key and tags from a prefix and the values of the
+// context keys.
+func (this *LogicalPermissions) getCacheKey(prefix string, context_keys []string, context map[string]interface{}, tags []string) (string, []string) {
+	key := prefix
+	for _, context_key := range context_keys {
+		encoded_value := this.encodeCacheValue(this.getContextValue(context, context_key))
+		key += "\x00" + context_key + "=" + encoded_value
+		tags = append(tags, this.getCacheSubjectTag(context_key, encoded_value))
+	}
+	return key, tags
+}
+
+// getCachedResult returns a cached callback result, or calls the callback and
+// caches its result if the permission type or the bypass callback declared its
+// cache keys. The permtype is empty for bypasses, and the permission is the
+// name of a named bypass, whose results are never cached. Cached results are
+// reported to the observers.
+func (this *LogicalPermissions) getCachedResult(permtype string, permission string, context map[string]interface{}, eval *evaluation, callback func() callbackResult) callbackResult {
+	context_keys, ok := this.bypass_cache_keys, this.bypass_cache_keys != nil && permission == ""
+	prefix, tags := "bypass", []string{"bypass"}
+	if permtype != "" {
+		context_keys, ok = this.type_cache_keys[permtype]
+		prefix, tags = "callback\x00"+permtype+"\x00"+permission, []string{"type:" + permtype}
+	}
+	if this.cache == nil || !ok {
+		return callback()
+	}
+	key, tags := this.getCacheKey(prefix, context_keys, context, tags)
+	if access, found := this.cache.Get(key); found {
+		result := callbackResult{access: access}
+		this.observeCachedResult(permtype, permission, context, eval, result)
+		return result
+	}
+	result := callback()
+	if result.err == nil {
+		this.cache.Set(key, result.access, tags)
+	}
+	return result
+}
+
+// checkAccessCached returns a cached decision if decision caching is enabled
+// and every callback that the decision may depend on declared its cache keys.
+func (this *LogicalPermissions) checkAccessCached(permissions interface{}, context map[string]interface{}, allow_bypass bool, eval *evaluation) (bool, error) {
+	if this.cache == nil || !this.cache_decisions || eval.trace != nil || eval.isShadow() {
+		return this.checkAccess(permissions, context, allow_bypass, eval)
+	}
+	key, tags, ok := this.getDecisionCacheKey(permissions, context, allow_bypass)
+	if !ok {
+		return this.checkAccess(permissions, context, allow_bypass, eval)
+	}
+	if access, found := this.cache.Get(key); found {
+		eval.cached = true
+		return access, nil
+	}
+	access, err := this.checkAccess(permissions, context, allow_bypass, eval)
+	// Decisions that depend on callback errors handled by an error policy are
+	// not cached, because the errors may be transient.
+	if err == nil && !eval.unknown && !eval.handled_error {
+		this.cache.Set(key, access, tags)
+	}
+	return access, err
+}
+
+func (this *LogicalPermissions) getDecisionCacheKey(permissions interface{}, context map[string]interface{}, allow_bypass bool) (string, []string, bool) {
+	tree, err := this.parsePermissionTree(permissions)
+	if err != nil || tree.err() != nil {
+		return "", nil, false
+	}
+	tags := []string{}
+	seen_keys := make(map[string]bool)
+	context_keys := []string{}
+	add_keys := func(keys []string) {
+		for _, key := range keys {
+			if !seen_keys[key] {
+				seen_keys[key] = true
+				context_keys = append(context_keys, key)
+			}
+		}
+	}
+
+	cacheable := true
+	seen_types := make(map[string]bool)
+	policies := []string{}
+	tree.walk(func(node *permissionNode, parent *permissionNode) {
+		if node.kind != TraceNodeValue || seen_types[node.permtype] {
+			return
+		}
+		seen_types[node.permtype] = true
+		policies = append(policies, node.permtype+"="+this.getErrorPolicy(node.permtype).String())
+		keys, ok := this.type_cache_keys[node.permtype]
+		cacheable = cacheable && ok
+		add_keys(keys)
+		tags = append(tags, "type:"+node.permtype)
+	})
+	if allow_bypass && this.GetBypassCallback() != nil {
+		cacheable = cacheable && this.bypass_cache_keys != nil
+		add_keys(this.bypass_cache_keys)
+		tags = append(tags, "bypass")
+	}
+	// The results of named bypasses are never cached.
+	if allow_bypass && len(this.bypasses) > 0 {
+		cacheable = false
+	}
+	if !cacheable {
+		return "", nil, false
+	}
+
+	sort.Strings(context_keys)
+	// The settings that can change the decision are part of the key, so that
+	// changing them does not return stale decisions.
+	sort.Strings(policies)
+	settings := fmt.Sprintf("%t,%t,%t,%+v,%s", this.three_valued, this.continue_on_error, this.strict, this.policy_limits, strings.Join(policies, ","))
+	prefix := "decision\x00" + getPolicyFingerprint(permissions) + "\x00" + strconv.FormatBool(allow_bypass) + "\x00" + settings
+	key, tags := this.getCacheKey(prefix, context_keys, context, tags)
+	return key, tags, true
+}
@@ -0,0 +1,318 @@
+package logicalpermissions_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	. "github.com/ordermind/logical-permissions-go"
+	"github.com/stretchr/testify/assert"
+)
+
+/*-------------LRUCache--------------*/
+
+func TestNewLRUCacheParams(t *testing.T) {
+	t.Parallel()
+	_, err := NewLRUCache(0, time.Minute)
+	assert.IsType(t, &InvalidArgumentValueError{}, err)
+	_, err = NewLRUCache(1, -time.Minute)
+	assert.IsType(t, &InvalidArgumentValueError{}, err)
+}
+
+func TestLRUCache(t *testing.T) {
+	t.Parallel()
+	cache, _ := NewLRUCache(2, 0)
+	cache.Set("a", true, []string{"x"})
+	cache.Set("b", false, []string{"x", "y"})
+	access, ok := cache.Get("a")
+	assert.True(t, ok)
+	assert.True(t, access)
+	access, ok = cache.Get("b")
+	assert.True(t, ok)
+	assert.False(t, access)
+
+	// The least recently used entry is evicted.
+	cache.Get("a")
+	cache.Set("c", true, []string{"y"})
+	assert.Equal(t, 2, cache.Len())
+	_, ok = cache.Get("b")
+	assert.False(t, ok)
+
+	cache.Invalidate("y")
+	assert.Equal(t, 1, cache.Len())
+	_, ok = cache.Get("a")
+	assert.True(t, ok)
+	cache.Invalidate("x")
+	assert.Equal(t, 0, cache.Len())
+
+	cache.Set("a", true, nil)
+	cache.Clear()
+	_, ok = cache.Get("a")
+	assert.False(t, ok)
+}
+
+func TestLRUCacheTTL(t *testing.T) {
+	t.Parallel()
+	cache, _ := NewLRUCache(10, 10*time.Millisecond)
+	cache.Set("a", true, nil)
+	_, ok := cache.Get("a")
+	assert.True(t, ok)
+	time.Sleep(20 * time.Millisecond)
+	_, ok = cache.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 0, cache.Len())
+}
+
+/*-------------LogicalPermissions::SetTypeCacheKeys()--------------*/
+
+func TestSetTypeCacheKeysParams(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	err := lp.SetTypeCacheKeys("", []string{})
+	assert.IsType(t, &InvalidArgumentValueError{}, err)
+	err = lp.SetTypeCacheKeys("unregistered", []string{})
+	assert.IsType(t, &PermissionTypeNotRegisteredError{}, err)
+	_, err = lp.GetTypeCacheKeys("")
+	assert.IsType(t, &InvalidArgumentValueError{}, err)
+	_, err = lp.GetTypeCacheKeys("unregistered")
+	assert.IsType(t, &PermissionTypeNotRegisteredError{}, err)
+}
+
+func TestSetTypeCacheKeys(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	lp.AddType("role", func(string, map[string]interface{}) (bool, error) { return true, nil })
+	keys, err := lp.GetTypeCacheKeys("role")
+	assert.Nil(t, err)
+	assert.Nil(t, keys)
+	context_keys := []string{"user.id"}
+	assert.Nil(t, lp.SetTypeCacheKeys("role", context_keys))
+	context_keys[0] = "changed"
+	keys, _ = lp.GetTypeCacheKeys("role")
+	assert.Equal(t, []string{"user.id"}, keys)
+	assert.Nil(t, lp.SetTypeCacheKeys("role", []string{}))
+	keys, _ = lp.GetTypeCacheKeys("role")
+	assert.Equal(t, []string{}, keys)
+	assert.Nil(t, lp.SetTypeCacheKeys("role", nil))
+	keys, _ = lp.GetTypeCacheKeys("role")
+	assert.Nil(t, keys)
+
+	assert.Nil(t, lp.GetBypassCacheKeys())
+	lp.SetBypassCacheKeys([]string{"user.id"})
+	assert.Equal(t, []string{"user.id"}, lp.GetBypassCacheKeys())
+}
+
+/*-------------Callback caching--------------*/
+
+func TestCacheCallbackResults(t *testing.T) {
+	t.Parallel()
+	counter := &callCounter{calls: make(map[string]int)}
+	lp := newRoleLogicalPermissions(t, counter)
+	lp.AddType("flag", func(flag string, context map[string]interface{}) (bool, error) {
+		counter.add("flag:" + flag)
+		return flag == "beta", nil
+	})
+	cache, _ := NewLRUCache(100, 0)
+	lp.SetCache(cache)
+	lp.SetTypeCacheKeys("role", []string{"user.id", "role"})
+	lp.SetTypeCacheKeys("flag", []string{})
+	alice := map[string]interface{}{"role": "admin", "user": map[string]interface{}{"id": 1}}
+	bob := map[string]interface{}{"role": "editor", "user": map[string]interface{}{"id": 2}}
+	permissions := map[string]interface{}{"AND": []interface{}{map[string]interface{}{"flag": "beta"}, map[string]interface{}{"role": "admin"}}}
+
+	for i := 0; i < 2; i++ {
+		access, err := lp.CheckAccessNoBypass(permissions, alice)
+		assert.Nil(t, err)
+		assert.True(t, access)
+		access, err = lp.CheckAccessNoBypass(permissions, bob)
+		assert.Nil(t, err)
+		assert.False(t, access)
+	}
+	assert.Equal(t, 1, counter.get("flag:beta"))
+	assert.Equal(t, 2, counter.get("role:admin"))
+
+	// The bypass callback is not cached without declared cache keys.
+	lp.CheckAccess(permissions, alice)
+	lp.CheckAccess(permissions, alice)
+	assert.Equal(t, 2, counter.get("bypass"))
+	assert.Equal(t, 2, counter.get("role:admin"))
+	lp.SetBypassCacheKeys([]string{"user.id"})
+	lp.CheckAccess(permissions, alice)
+	lp.CheckAccess(permissions, alice)
+	assert.Equal(t, 3, counter.get("bypass"))
+
+	// Invalidation per subject.
+	lp.InvalidateCacheSubject("user.id", 1)
+	lp.CheckAccess(permissions, alice)
+	lp.CheckAccess(permissions, bob)
+	assert.Equal(t, 3, counter.get("role:admin"))
+	assert.Equal(t, 5, counter.get("bypass"))
+	assert.Equal(t, 1, counter.get("flag:beta"))
+
+	// Invalidation per type.
+	lp.InvalidateCacheType("flag")
+	lp.CheckAccess(permissions, alice)
+	assert.Equal(t, 2, counter.get("flag:beta"))
+	assert.Equal(t, 3, counter.get("role:admin"))
+	lp.InvalidateCacheBypass()
+	lp.CheckAccess(permissions, alice)
+	assert.Equal(t, 6, counter.get("bypass"))
+
+	// Errors are not cached.
+	lp.CheckAccessNoBypass(map[string]interface{}{"role": "broken"}, alice)
+	lp.CheckAccessNoBypass(map[string]interface{}{"role": "broken"}, alice)
+	assert.Equal(t, 2, counter.get("role:broken"))
+
+	// Removing a type invalidates its results.
+	lp.RemoveType("flag")
+	lp.AddType("flag", func(string, map[string]interface{}) (bool, error) { return false, nil })
+	access, _ := lp.CheckAccessNoBypass(permissions, alice)
+	assert.False(t, access)
+
+	// Replacing a callback invalidates its results.
+	lp.SetTypeCacheKeys("flag", []string{})
+	lp.SetTypeCallback("flag", func(string, map[string]interface{}) (bool, error) { return true, nil })
+	access, _ = lp.CheckAccessNoBypass(permissions, alice)
+	assert.True(t, access)
+	lp.SetTypeCallback("flag", func(string, map[string]interface{}) (bool, error) { return false, nil })
+	access, _ = lp.CheckAccessNoBypass(permissions, alice)
+	assert.False(t, access)
+	lp.SetBypassCallback(func(map[string]interface{}) (bool, error) { return true, nil })
+	access, _ = lp.CheckAccess(permissions, alice)
+	assert.True(t, access)
+	lp.SetBypassCallback(func(map[string]interface{}) (bool, error) { return false, nil })
+	access, _ = lp.CheckAccess(permissions, alice)
+	assert.False(t, access)
+}
+
+func TestCacheDecisions(t *testing.T) {
+	t.Parallel()
+	counter := &callCounter{calls: make(map[string]int)}
+	lp := newRoleLogicalPermissions(t, counter)
+	lp.AddType("flag", func(flag string, context map[string]interface{}) (bool, error) {
+		counter.add("flag:" + flag)
+		return flag == "beta", nil
+	})
+	cache, _ := NewLRUCache(100, 0)
+	lp.SetCache(cache)
+	lp.SetCacheDecisions(true)
+	assert.True(t, lp.GetCacheDecisions())
+	permissions := map[string]interface{}{"OR": []interface{}{map[string]interface{}{"flag": "alpha"}, map[string]interface{}{"role": "admin"}}}
+	alice := map[string]interface{}{"role": "admin", "user": map[string]interface{}{"id": 1}}
+
+	// Decisions are not cached until every callback declared its cache keys.
+	lp.SetTypeCacheKeys("role", []string{"user.id"})
+	lp.CheckAccessNoBypass(permissions, alice)
+	lp.CheckAccessNoBypass(permissions, alice)
+	assert.Equal(t, 2, counter.get("flag:alpha"))
+
+	lp.SetTypeCacheKeys("flag", []string{"tenant"})
+	lp.SetCache(nil)
+	cache, _ = NewLRUCache(100, 0)
+	lp.SetCache(cache)
+	for i := 0; i < 3; i++ {
+		access, err := lp.CheckAccessNoBypass(permissions, alice)
+		assert.Nil(t, err)
+		assert.True(t, access)
+	}
+	// The decision and both callback results are cached.
+	assert.Equal(t, 3, cache.Len())
+	assert.Equal(t, 3, counter.get("flag:alpha"))
+
+	// Equivalent permission trees share cached decisions.
+	lp.CheckAccessNoBypass(`{"or": [{"flag": "alpha"}, {"role": "admin"}]}`, alice)
+	assert.Equal(t, 3, cache.Len())
+
+	// A trace always requires an evaluation, but callback results are still cached.
+	_, trace, _ := lp.CheckAccessNoBypassWithTrace(permissions, alice)
+	assert.True(t, trace.Root.Result)
+	assert.Equal(t, 3, counter.get("flag:alpha"))
+
+	// The decision depends on the union of the cache keys.
+	lp.CheckAccessNoBypass(permissions, map[string]interface{}{"tenant": "acme", "role": "admin", "user": map[string]interface{}{"id": 1}})
+	assert.Equal(t, 4, counter.get("flag:alpha"))
+	assert.Equal(t, 5, cache.Len())
+
+	lp.InvalidateCacheType("role")
+	assert.Equal(t, 2, cache.Len())
+	lp.InvalidateCacheSubject("tenant", nil)
+	assert.Equal(t, 1, cache.Len())
+
+	// Settings that can change the decision are part of its cache key.
+	beta := map[string]interface{}{"flag": "beta"}
+	lp.CheckAccessNoBypass(beta, alice)
+	lp.CheckAccessNoBypass(beta, alice)
+	assert.Equal(t, 3, cache.Len())
+	lp.SetStrict(true)
+	lp.CheckAccessNoBypass(beta, alice)
+	assert.Equal(t, 4, cache.Len())
+	lp.SetPolicyLimits(PolicyLimits{MaxNodes: 10})
+	lp.CheckAccessNoBypass(beta, alice)
+	assert.Equal(t, 5, cache.Len())
+	lp.SetTypeErrorPolicy("flag", ErrorPolicyTrue)
+	lp.CheckAccessNoBypass(beta, alice)
+	assert.Equal(t, 6, cache.Len())
+	lp.SetContinueOnError(true)
+	lp.CheckAccessNoBypass(beta, alice)
+	assert.Equal(t, 7, cache.Len())
+	lp.SetThreeValued(true)
+	lp.CheckAccessNoBypass(beta, alice)
+	lp.CheckAccessNoBypass(beta, alice)
+	assert.Equal(t, 8, cache.Len())
+}
+
+func TestCacheObservers(t *testing.T) {
+	t.Parallel()
+	counter := &callCounter{calls: make(map[string]int)}
+	lp := newRoleLogicalPermissions(t, counter)
+	lp.AddType("flag", func(flag string, context map[string]interface{}) (bool, error) {
+		counter.add("flag:" + flag)
+		return flag == "beta", nil
+	})
+	cache, _ := NewLRUCache(100, 0)
+	lp.SetCache(cache)
+	lp.SetTypeCacheKeys("role", []string{"user.id"})
+	lp.SetTypeCacheKeys("flag", []string{})
+	lp.SetBypassCacheKeys([]string{"user.id"})
+	var buffer bytes.Buffer
+	logger := NewDecisionLogger(NewWriterSink(&buffer))
+	logger.PolicyID = func(permissions interface{}, context map[string]interface{}) string {
+		return "edit"
+	}
+	lp.AddObserver(logger)
+	permissions := map[string]interface{}{"OR": []interface{}{map[string]interface{}{"flag": "alpha"}, map[string]interface{}{"role": "admin"}}}
+	alice := map[string]interface{}{"role": "admin", "user": map[string]interface{}{"id": 1}}
+
+	// Cached callback results are reported to the observers.
+	lp.CheckAccess(permissions, alice)
+	lp.CheckAccess(permissions, alice)
+	records, err := ReadDecisionRecords(&buffer)
+	assert.Nil(t, err)
+	if assert.Len(t, records, 2) {
+		assert.Equal(t, records[0].Leaves[0].Permission, records[1].Leaves[0].Permission)
+		assert.False(t, records[0].Leaves[0].Cached)
+		assert.True(t, records[1].Leaves[0].Cached)
+		assert.Len(t, records[1].Leaves, 2)
+		assert.True(t, records[1].BypassChecked)
+		assert.False(t, records[1].Cached)
+	}
+	report := ReplayDecisions(records, map[string]interface{}{"edit": permissions})
+	assert.Equal(t, 2, report.Unchanged)
+
+	// Cached decisions are recorded without their callback results.
+	lp.SetCacheDecisions(true)
+	lp.CheckAccess(permissions, alice)
+	lp.CheckAccess(permissions, alice)
+	records, err = ReadDecisionRecords(&buffer)
+	assert.Nil(t, err)
+	if assert.Len(t, records, 2) {
+		assert.False(t, records[0].Cached)
+		assert.True(t, records[1].Cached)
+		assert.True(t, records[1].Access)
+		assert.Len(t, records[1].Leaves, 0)
+	}
+	report = ReplayDecisions(records[1:], map[string]interface{}{"edit": permissions})
+	assert.Equal(t, 1, report.Inconclusive)
+	report = ReplayDecisions(records[1:], map[string]interface{}{"edit": map[string]interface{}{"NO_BYPASS": true, "0": true}})
+	assert.Equal(t, 1, report.Unchanged)
+}
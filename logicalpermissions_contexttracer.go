@@ -0,0 +1,52 @@
+//go:build go1.7
+// +build go1.7
+
+package logicalpermissions
+
+import (
+	gocontext "context"
+	"sync"
+)
+
+// ContextTracer adapts tracers that pass the parent span in a context.Context,
+// such as the OpenTelemetry tracer API. Start is typically implemented with
+// trace.Tracer.Start(), and the returned end function sets the attributes,
+// records the error and ends the span.
+type ContextTracer struct {
+	// ContextKey is the key in the context map of CheckAccess() that holds the
+	// context.Context of the access check. The background context is used if
+	// the key is empty or missing.
+	ContextKey string
+	Start      func(ctx gocontext.Context, name string, attributes map[string]interface{}) (gocontext.Context, func(attributes map[string]interface{}, err error))
+}
+
+type contextSpan struct {
+	mutex      sync.Mutex
+	ctx        gocontext.Context
+	end        func(attributes map[string]interface{}, err error)
+	attributes map[string]interface{}
+}
+
+func (this *ContextTracer) StartSpan(parent Span, name string, context map[string]interface{}, attributes map[string]interface{}) Span {
+	ctx := gocontext.Background()
+	if parent_span, ok := parent.(*contextSpan); ok {
+		ctx = parent_span.ctx
+	} else if check_ctx, ok := context[this.ContextKey].(gocontext.Context); ok && this.ContextKey != "" {
+		ctx = check_ctx
+	}
+	span := &contextSpan{attributes: make(map[string]interface{})}
+	span.ctx, span.end = this.Start(ctx, name, attributes)
+	return span
+}
+
+func (this *contextSpan) SetAttribute(key string, value interface{}) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.attributes[key] = value
+}
+
+func (this *contextSpan) End(err error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.end(this.attributes, err)
+}
@@ -0,0 +1,60 @@
+//go:build go1.7
+// +build go1.7
+
+package logicalpermissions_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/ordermind/logical-permissions-go"
+	"github.com/stretchr/testify/assert"
+)
+
+/*-------------ContextTracer--------------*/
+
+type contextSpanKey struct{}
+
+func TestContextTracer(t *testing.T) {
+	t.Parallel()
+	lp := newRoleLogicalPermissions(t, nil)
+	type endedSpan struct {
+		name       string
+		parent     interface{}
+		attributes map[string]interface{}
+		err        error
+	}
+	ended := []endedSpan{}
+	lp.SetTracer(&ContextTracer{
+		ContextKey: "ctx",
+		Start: func(ctx context.Context, name string, attributes map[string]interface{}) (context.Context, func(map[string]interface{}, error)) {
+			parent := ctx.Value(contextSpanKey{})
+			return context.WithValue(ctx, contextSpanKey{}, name), func(end_attributes map[string]interface{}, err error) {
+				for key, value := range end_attributes {
+					attributes[key] = value
+				}
+				ended = append(ended, endedSpan{name: name, parent: parent, attributes: attributes, err: err})
+			}
+		},
+	})
+
+	ctx := context.WithValue(context.Background(), contextSpanKey{}, "request")
+	access, err := lp.CheckAccessNoBypass(map[string]interface{}{"role": "admin"}, map[string]interface{}{"role": "admin", "ctx": ctx})
+	assert.Nil(t, err)
+	assert.True(t, access)
+	assert.Len(t, ended, 2)
+	assert.Equal(t, SpanNameCallback, ended[0].name)
+	assert.Equal(t, SpanNameCheck, ended[0].parent)
+	assert.Equal(t, "role", ended[0].attributes["logicalpermissions.type"])
+	assert.Equal(t, true, ended[0].attributes["logicalpermissions.access"])
+	assert.Equal(t, SpanNameCheck, ended[1].name)
+	assert.Equal(t, "request", ended[1].parent)
+	assert.Equal(t, true, ended[1].attributes["logicalpermissions.access"])
+
+	// The background context is used without a context.Context in the context map.
+	ended = ended[:0]
+	_, err = lp.CheckAccessNoBypass(map[string]interface{}{"role": "broken"}, map[string]interface{}{})
+	assert.Len(t, ended, 2)
+	assert.Nil(t, ended[1].parent)
+	assert.Equal(t, err, ended[1].err)
+}
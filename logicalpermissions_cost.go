@@ -0,0 +1,309 @@
+package logicalpermissions
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTypeCost is the estimated cost, in microseconds, of a permission type
+// that has neither a declared nor a learned cost.
+const DefaultTypeCost = 1.0
+
+// learnedCosts holds the average latency and the share of granted results of
+// the callbacks of each permission type.
+type learnedCosts struct {
+	mutex sync.Mutex
+	types map[string]*learnedCost
+}
+
+type learnedCost struct {
+	calls   int64
+	average float64
+	granted float64
+}
+
+func (this *learnedCosts) learn(permtype string, access bool, err error, duration time.Duration) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	cost, ok := this.types[permtype]
+	if !ok {
+		cost = &learnedCost{}
+		this.types[permtype] = cost
+	}
+	cost.calls++
+	// The first calls are averaged, and later calls are weighted so that the
+	// estimates follow changes in latency.
+	weight := math.Max(1/float64(cost.calls), 0.1)
+	cost.average += weight * (float64(duration)/float64(time.Microsecond) - cost.average)
+	if err == nil {
+		granted := 0.0
+		if access {
+			granted = 1
+		}
+		cost.granted += weight * (granted - cost.granted)
+	}
+}
+
+func (this *learnedCosts) get(permtype string) (learnedCost, bool) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	cost, ok := this.types[permtype]
+	if !ok {
+		return learnedCost{}, false
+	}
+	return *cost, true
+}
+
+func (this *learnedCosts) remove(permtype string) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	delete(this.types, permtype)
+}
+
+func (this *LogicalPermissions) GetTypeCost(name string) (float64, error) {
+	if name == "" {
+		return 0, &InvalidArgumentValueError{CustomError{"The name parameter cannot be empty."}}
+	}
+	exists, _ := this.TypeExists(name)
+	if !exists {
+		return 0, &PermissionTypeNotRegisteredError{CustomError{fmt.Sprintf("The permission type \"%s\" has not been registered. Please use LogicalPermissions::AddType() or LogicalPermissions::SetTypes() to register permission types.", name)}}
+	}
+	cost, _ := this.getTypeEstimate(name)
+	return cost, nil
+}
+
+func (this *LogicalPermissions) SetTypeCost(name string, cost float64) error {
+	if name == "" {
+		return &InvalidArgumentValueError{CustomError{"The name parameter cannot be empty."}}
+	}
+	exists, _ := this.TypeExists(name)
+	if !exists {
+		return &PermissionTypeNotRegisteredError{CustomError{fmt.Sprintf("The permission type \"%s\" has not been registered. Please use LogicalPermissions::AddType() or LogicalPermissions::SetTypes() to register permission types.", name)}}
+	}
+	if cost < 0 || math.IsNaN(cost) || math.IsInf(cost, 0) {
+		return &InvalidArgumentValueError{CustomError{fmt.Sprintf("The cost parameter must be a finite number that is not negative. Current value: %v", cost)}}
+	}
+	if this.type_costs == nil {
+		this.type_costs = make(map[string]float64)
+	}
+	this.type_costs[name] = cost
+	return nil
+}
+
+func (this *LogicalPermissions) GetLearnTypeCosts() bool {
+	return this.learned_costs != nil
+}
+
+func (this *LogicalPermissions) SetLearnTypeCosts(learn bool) {
+	if !learn {
+		this.learned_costs = nil
+	} else if this.learned_costs == nil {
+		this.learned_costs = &learnedCosts{types: make(map[string]*learnedCost)}
+	}
+}
+
+func (this *LogicalPermissions) GetOptimizeOrder() bool {
+	return this.optimize_order
+}
+
+func (this *LogicalPermissions) SetOptimizeOrder(optimize_order bool) {
+	this.optimize_order = optimize_order
+}
+
+// getTypeEstimate returns the estimated cost of a permission of a type and
+// the estimated probability that it grants access. A declared cost takes
+// precedence over the learned cost.
+func (this *LogicalPermissions) getTypeEstimate(permtype string) (float64, float64) {
+	cost, probability := DefaultTypeCost, 0.5
+	if this.learned_costs != nil {
+		if learned, ok := this.learned_costs.get(permtype); ok {
+			cost, probability = learned.average, learned.granted
+		}
+	}
+	if declared, ok := this.type_costs[permtype]; ok {
+		cost = declared
+	}
+	return cost, probability
+}
+
+// estimatePermissions estimates the cost of evaluating permissions and the
+// probability that they grant access, assuming that every child of a gate is
+// evaluated and that the permissions are independent.
+func (this *LogicalPermissions) estimatePermissions(permissions interface{}, permtype string) (float64, float64) {
+	switch value := permissions.(type) {
+	case bool:
+		if value {
+			return 0, 1
+		}
+		return 0, 0
+	case string:
+		if permtype == "" {
+			if strings.ToUpper(value) == "TRUE" {
+				return 0, 1
+			}
+			return 0, 0
+		}
+		return this.getTypeEstimate(permtype)
+	case []interface{}:
+		return this.estimateGate("OR", value, permtype)
+	case map[string]interface{}:
+		if len(value) != 1 {
+			return this.estimateGate("OR", value, permtype)
+		}
+		for key, child := range value {
+			if _, err := strconv.Atoi(key); err == nil {
+				return this.estimatePermissions(child, permtype)
+			}
+			key_upper := strings.ToUpper(key)
+			if this.stringInSlice(key_upper, this.getGateKeys()) {
+				return this.estimateGate(key_upper, child, permtype)
+			}
+			if key_upper == "NO_BYPASS" || key_upper == "TRUE" || key_upper == "FALSE" {
+				return 0, 0.5
+			}
+			return this.estimatePermissions(child, key)
+		}
+	}
+	return 0, 0.5
+}
+
+func (this *LogicalPermissions) estimateGate(gate string, permissions interface{}, permtype string) (float64, float64) {
+	children := []interface{}{}
+	if slice_permissions, ok := permissions.([]interface{}); ok {
+		children = slice_permissions
+	} else if map_permissions, ok := permissions.(map[string]interface{}); ok {
+		for key, child := range map_permissions {
+			children = append(children, map[string]interface{}{key: child})
+		}
+	} else if gate == "NOT" {
+		children = append(children, permissions)
+	}
+	cost, all_granted, all_denied := 0.0, 1.0, 1.0
+	for _, child := range children {
+		child_cost, probability := this.estimatePermissions(child, permtype)
+		cost += child_cost
+		all_granted *= probability
+		all_denied *= 1 - probability
+	}
+	switch gate {
+	case "AND":
+		return cost, all_granted
+	case "NAND":
+		return cost, 1 - all_granted
+	case "OR":
+		return cost, 1 - all_denied
+	case "NOR":
+		return cost, all_denied
+	case "XOR":
+		return cost, 1 - all_granted - all_denied
+	}
+	return cost, all_denied
+}
+
+// getChildRank ranks a child of a gate, where children with a lower rank are
+// evaluated first. Children are ranked by their cost per probability of
+// deciding the result of the gate on their own.
+func (this *LogicalPermissions) getChildRank(gate string, permissions interface{}, permtype string) float64 {
+	cost, probability := this.estimatePermissions(permissions, permtype)
+	decisive := 1.0
+	if gate == "AND" {
+		decisive = 1 - probability
+	} else if gate == "OR" {
+		decisive = probability
+	}
+	if decisive <= 0 {
+		return math.Inf(1)
+	}
+	return cost / decisive
+}
+
+// canOptimizeOrder returns whether the order of evaluation is optimized for the
+// parsed permission tree of an access check. Reordering the children of a gate does not change its result,
+// because the gates are commutative, unless an error aborts the evaluation of
+// children that the authored order would not have evaluated, or the other way
+// around. The order is therefore only optimized if the permission tree is valid,
+// the number of callbacks is not limited, and callback errors are handled by
+// continuing on errors or by error policies that do not abort.
+func (this *LogicalPermissions) canOptimizeOrder(tree *permissionTree) bool {
+	if !this.optimize_order || this.policy_limits.MaxCallbacks > 0 {
+		return false
+	}
+	if tree == nil || tree.err() != nil {
+		return false
+	}
+	if this.continue_on_error {
+		return true
+	}
+	handled := true
+	tree.walk(func(node *permissionNode, parent *permissionNode) {
+		if node.kind == TraceNodeValue && this.getErrorPolicy(node.permtype) == ErrorPolicyAbort {
+			handled = false
+		}
+	})
+	return handled
+}
+
+// getChildOrder returns the indexes of the children of an AND, OR or XOR gate
+// in the order of evaluation. Cheap and likely decisive children are evaluated
+// first if the order is optimized.
+func (this *LogicalPermissions) getChildOrder(permissions []interface{}, gate string, permtype string, eval *evaluation) []int {
+	order := make([]int, len(permissions))
+	for i := range order {
+		order[i] = i
+	}
+	if eval == nil || !eval.optimize_order {
+		return order
+	}
+	ranks := make([]float64, len(permissions))
+	for i, permission := range permissions {
+		ranks[i] = this.getChildRank(gate, permission, permtype)
+	}
+	sort.Stable(rankedChildren{order, ranks})
+	return order
+}
+
+// getKeyOrder returns the keys of the children of an AND, OR or XOR gate in
+// the order of evaluation, like getChildOrder().
+func (this *LogicalPermissions) getKeyOrder(permissions map[string]interface{}, gate string, permtype string, eval *evaluation) []string {
+	keys := make([]string, 0, len(permissions))
+	for key := range permissions {
+		keys = append(keys, key)
+	}
+	if eval == nil || !eval.optimize_order {
+		return keys
+	}
+	sort.Strings(keys)
+	order := make([]int, len(keys))
+	ranks := make([]float64, len(keys))
+	for i, key := range keys {
+		order[i] = i
+		ranks[i] = this.getChildRank(gate, map[string]interface{}{key: permissions[key]}, permtype)
+	}
+	sort.Stable(rankedChildren{order, ranks})
+	ordered_keys := make([]string, len(keys))
+	for i, index := range order {
+		ordered_keys[i] = keys[index]
+	}
+	return ordered_keys
+}
+
+// rankedChildren sorts the indexes of children by their ranks.
+type rankedChildren struct {
+	order []int
+	ranks []float64
+}
+
+func (this rankedChildren) Len() int { return len(this.order) }
+
+func (this rankedChildren) Swap(i, j int) {
+	this.order[i], this.order[j] = this.order[j], this.order[i]
+}
+
+func (this rankedChildren) Less(i, j int) bool {
+	return this.ranks[this.order[i]] < this.ranks[this.order[j]]
+}
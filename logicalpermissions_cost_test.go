@@ -0,0 +1,197 @@
+package logicalpermissions_test
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+
+	. "github.com/ordermind/logical-permissions-go"
+	"github.com/stretchr/testify/assert"
+)
+
+/*-------------LogicalPermissions::SetTypeCost()--------------*/
+
+func TestSetTypeCostParams(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	err := lp.SetTypeCost("", 1)
+	assert.IsType(t, &InvalidArgumentValueError{}, err)
+	err = lp.SetTypeCost("unregistered", 1)
+	assert.IsType(t, &PermissionTypeNotRegisteredError{}, err)
+	_, err = lp.GetTypeCost("")
+	assert.IsType(t, &InvalidArgumentValueError{}, err)
+	_, err = lp.GetTypeCost("unregistered")
+	assert.IsType(t, &PermissionTypeNotRegisteredError{}, err)
+	lp.AddType("role", func(string, map[string]interface{}) (bool, error) { return true, nil })
+	err = lp.SetTypeCost("role", -1)
+	assert.IsType(t, &InvalidArgumentValueError{}, err)
+	err = lp.SetTypeCost("role", math.NaN())
+	assert.IsType(t, &InvalidArgumentValueError{}, err)
+}
+
+func TestSetTypeCost(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	lp.AddType("role", func(string, map[string]interface{}) (bool, error) { return true, nil })
+	cost, err := lp.GetTypeCost("role")
+	assert.Nil(t, err)
+	assert.Equal(t, DefaultTypeCost, cost)
+	assert.Nil(t, lp.SetTypeCost("role", 250))
+	cost, _ = lp.GetTypeCost("role")
+	assert.Equal(t, 250.0, cost)
+	lp.RemoveType("role")
+	lp.AddType("role", func(string, map[string]interface{}) (bool, error) { return true, nil })
+	cost, _ = lp.GetTypeCost("role")
+	assert.Equal(t, DefaultTypeCost, cost)
+}
+
+/*-------------Optimized order--------------*/
+
+func TestOptimizeOrder(t *testing.T) {
+	t.Parallel()
+	counter := &callCounter{calls: make(map[string]int)}
+	lp := newRoleLogicalPermissions(t, counter)
+	lp.AddType("remote", func(permission string, context map[string]interface{}) (bool, error) {
+		counter.add("remote:" + permission)
+		time.Sleep(2 * time.Millisecond)
+		return permission == "allowed", nil
+	})
+	assert.False(t, lp.GetOptimizeOrder())
+	permissions := map[string]interface{}{"OR": []interface{}{
+		map[string]interface{}{"remote": "allowed"},
+		map[string]interface{}{"role": "admin"},
+	}}
+	context := map[string]interface{}{"role": "admin"}
+
+	// Without optimization the children are evaluated in authoring order.
+	access, err := lp.CheckAccessNoBypass(permissions, context)
+	assert.Nil(t, err)
+	assert.True(t, access)
+	assert.Equal(t, 1, counter.get("remote:allowed"))
+	assert.Equal(t, 0, counter.get("role:admin"))
+
+	// The order is not optimized while callback errors abort the access check,
+	// because the error of a reordered child could change the result.
+	lp.SetOptimizeOrder(true)
+	assert.True(t, lp.GetOptimizeOrder())
+	lp.SetTypeCost("remote", 2000)
+	lp.CheckAccessNoBypass(permissions, context)
+	assert.Equal(t, 2, counter.get("remote:allowed"))
+	assert.Equal(t, 0, counter.get("role:admin"))
+
+	lp.SetErrorPolicy(ErrorPolicyFalse)
+	access, trace, err := lp.CheckAccessNoBypassWithTrace(permissions, context)
+	assert.Nil(t, err)
+	assert.True(t, access)
+	assert.Equal(t, 2, counter.get("remote:allowed"))
+	assert.Equal(t, 1, counter.get("role:admin"))
+	// The trace keeps the paths of the authored permission tree.
+	gate := trace.Root.Children[0]
+	assert.Len(t, gate.Children, 1)
+	assert.Equal(t, "/OR/1/role", gate.Children[0].Path)
+
+	// Children of maps are reordered as well.
+	access, _ = lp.CheckAccessNoBypass(map[string]interface{}{"AND": map[string]interface{}{"remote": "allowed", "role": "editor"}}, context)
+	assert.False(t, access)
+	assert.Equal(t, 2, counter.get("remote:allowed"))
+	assert.Equal(t, 1, counter.get("role:editor"))
+
+	// A type with an aborting error policy, an invalid permission tree or a
+	// callback limit keeps the authored order.
+	lp.SetTypeErrorPolicy("remote", ErrorPolicyAbort)
+	lp.CheckAccessNoBypass(permissions, context)
+	assert.Equal(t, 3, counter.get("remote:allowed"))
+	assert.Equal(t, 1, counter.get("role:admin"))
+	lp.SetContinueOnError(true)
+	lp.CheckAccessNoBypass(permissions, context)
+	assert.Equal(t, 3, counter.get("remote:allowed"))
+	assert.Equal(t, 2, counter.get("role:admin"))
+	_, err = lp.CheckAccessNoBypass(map[string]interface{}{"OR": []interface{}{map[string]interface{}{"remote": "allowed"}, map[string]interface{}{"role": "admin"}, map[string]interface{}{"AND": "invalid"}}}, context)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, counter.get("remote:allowed"))
+	assert.Equal(t, 2, counter.get("role:admin"))
+	lp.SetPolicyLimits(PolicyLimits{MaxCallbacks: 10})
+	lp.CheckAccessNoBypass(permissions, context)
+	assert.Equal(t, 5, counter.get("remote:allowed"))
+	assert.Equal(t, 2, counter.get("role:admin"))
+}
+
+func TestLearnTypeCosts(t *testing.T) {
+	t.Parallel()
+	counter := &callCounter{calls: make(map[string]int)}
+	lp := newRoleLogicalPermissions(t, counter)
+	lp.AddType("remote", func(permission string, context map[string]interface{}) (bool, error) {
+		counter.add("remote:" + permission)
+		time.Sleep(2 * time.Millisecond)
+		return permission == "allowed", nil
+	})
+	lp.SetOptimizeOrder(true)
+	lp.SetContinueOnError(true)
+	assert.False(t, lp.GetLearnTypeCosts())
+	lp.SetLearnTypeCosts(true)
+	assert.True(t, lp.GetLearnTypeCosts())
+	context := map[string]interface{}{"role": "admin"}
+	lp.CheckAccessNoBypass(map[string]interface{}{"AND": []interface{}{map[string]interface{}{"remote": "allowed"}, map[string]interface{}{"role": "admin"}}}, context)
+	assert.Equal(t, 1, counter.get("remote:allowed"))
+
+	cost, _ := lp.GetTypeCost("remote")
+	assert.True(t, cost >= 2000)
+	cost, _ = lp.GetTypeCost("role")
+	assert.True(t, cost < 2000)
+
+	// The learned latency puts the cheap type first.
+	lp.CheckAccessNoBypass(map[string]interface{}{"OR": []interface{}{map[string]interface{}{"remote": "allowed"}, map[string]interface{}{"role": "admin"}}}, context)
+	assert.Equal(t, 1, counter.get("remote:allowed"))
+	assert.Equal(t, 2, counter.get("role:admin"))
+
+	// A declared cost takes precedence over the learned cost.
+	lp.SetTypeCost("role", 1000000)
+	lp.CheckAccessNoBypass(map[string]interface{}{"OR": []interface{}{map[string]interface{}{"role": "admin"}, map[string]interface{}{"remote": "allowed"}}}, context)
+	assert.Equal(t, 2, counter.get("remote:allowed"))
+	assert.Equal(t, 2, counter.get("role:admin"))
+
+	lp.SetLearnTypeCosts(false)
+	lp.SetTypeCost("role", 0)
+	cost, _ = lp.GetTypeCost("remote")
+	assert.Equal(t, DefaultTypeCost, cost)
+}
+
+func TestOptimizeOrderMatchesAuthoringOrder(t *testing.T) {
+	t.Parallel()
+	random := rand.New(rand.NewSource(2))
+	outcomes := make(map[string]bool)
+	for i := 0; i < 20; i++ {
+		outcomes["p"+strconv.Itoa(i)] = random.Intn(2) == 0
+	}
+	// Some callbacks fail, which does not change the result as long as the
+	// errors are handled by an error policy.
+	callback := func(role string, context map[string]interface{}) (bool, error) {
+		if role == "p0" || role == "p1" {
+			return false, errors.New("broken role")
+		}
+		return outcomes[role], nil
+	}
+	for _, policy := range []ErrorPolicy{ErrorPolicyFalse, ErrorPolicyTrue} {
+		authored := &LogicalPermissions{}
+		authored.AddType("role", callback)
+		authored.SetErrorPolicy(policy)
+		optimized := &LogicalPermissions{}
+		optimized.AddType("role", callback)
+		optimized.SetErrorPolicy(policy)
+		optimized.SetOptimizeOrder(true)
+		optimized.SetLearnTypeCosts(true)
+
+		for i := 0; i < 300; i++ {
+			permissions := getRandomPermissionTree(random, 4)
+			expected_access, expected_err := authored.CheckAccessNoBypass(permissions, map[string]interface{}{})
+			access, err := optimized.CheckAccessNoBypass(permissions, map[string]interface{}{})
+			message := fmt.Sprintf("Permissions: %v", permissions)
+			assert.Equal(t, expected_access, access, message)
+			assert.Equal(t, expected_err, err, message)
+		}
+	}
+}
@@ -0,0 +1,370 @@
+package logicalpermissions
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Phrasebook renders the parts of a permission tree description. Implement it
+// in order to translate descriptions into other languages.
+type Phrasebook interface {
+	// Permission describes a permission of a permission type. The template is
+	// the one registered with SetTypeTemplate(), or an empty string.
+	Permission(permtype string, value string, template string) string
+	// NegatedPermission describes a permission of a permission type in a NOT
+	// gate. The negated template is the one registered with
+	// SetTypeNegatedTemplate(), or an empty string.
+	NegatedPermission(permtype string, value string, template string, negated_template string) string
+	// Boolean describes a boolean permission.
+	Boolean(value bool) string
+	// Gate describes an AND, NAND, OR, NOR or XOR gate with at least two
+	// children. Nested is true if at least one of the parts is itself a gate.
+	Gate(gate string, parts []string, nested bool) string
+	// Not describes a NOT gate. Nested is true if the part is itself a gate.
+	Not(part string, nested bool) string
+	// Requirement describes a whole permission tree. Clause is true if the
+	// requirement contains verb phrases, such as "has role admin", instead of
+	// noun phrases, such as "role admin".
+	Requirement(requirement string, clause bool) string
+	// Constant describes a permission tree that always or never grants access.
+	Constant(access bool) string
+	// NoBypass describes the NO_BYPASS key. The condition is empty if bypass
+	// access is disallowed unconditionally, and clause is true if it contains
+	// verb phrases.
+	NoBypass(condition string, clause bool) string
+	// NoBypassScope describes permissions below the root that have a
+	// NO_BYPASS key. The condition is empty if bypass access is disallowed
+	// unconditionally, nested is true if the part is a gate and clause is true
+	// if the condition contains verb phrases.
+	NoBypassScope(part string, condition string, nested bool, clause bool) string
+	// NoBypassNames describes a NO_BYPASS slice, which refuses the bypasses
+	// in refused and accepts the named bypasses in accepted. If only is true,
+	// every bypass that is not accepted is refused and refused is empty.
+	NoBypassNames(refused []string, accepted []string, only bool) string
+	// NoBypassScopeNames describes permissions below the root that have a
+	// NO_BYPASS slice, like NoBypassScope() and NoBypassNames().
+	NoBypassScopeNames(part string, refused []string, accepted []string, only bool, nested bool) string
+}
+
+// EnglishPhrasebook is the default Phrasebook.
+type EnglishPhrasebook struct{}
+
+func (this EnglishPhrasebook) Permission(permtype string, value string, template string) string {
+	if template == "" {
+		return permtype + " " + value
+	}
+	return strings.Replace(template, "%s", value, -1)
+}
+
+func (this EnglishPhrasebook) NegatedPermission(permtype string, value string, template string, negated_template string) string {
+	if negated_template == "" {
+		return "not " + this.Permission(permtype, value, template)
+	}
+	return strings.Replace(negated_template, "%s", value, -1)
+}
+
+func (this EnglishPhrasebook) Boolean(value bool) string {
+	if value {
+		return "always"
+	}
+	return "never"
+}
+
+func (this EnglishPhrasebook) Gate(gate string, parts []string, nested bool) string {
+	if gate == "AND" {
+		return this.list(parts, "and", nested)
+	}
+	if gate == "NAND" {
+		if len(parts) == 2 {
+			return "not both " + this.list(parts, "and", nested)
+		}
+		return "not all of " + this.list(parts, "and", nested)
+	}
+	if gate == "NOR" {
+		if len(parts) == 2 {
+			return "neither " + this.list(parts, "nor", nested)
+		}
+		return "none of " + this.list(parts, "or", nested)
+	}
+	if gate == "XOR" {
+		if len(parts) == 2 {
+			return "either " + this.list(parts, "or", nested) + ", but not both"
+		}
+		return "some but not all of " + this.list(parts, "and", nested)
+	}
+	if len(parts) == 2 {
+		return "either " + this.list(parts, "or", nested)
+	}
+	return "one of " + this.list(parts, "or", nested)
+}
+
+func (this EnglishPhrasebook) Not(part string, nested bool) string {
+	if nested {
+		return "not (" + part + ")"
+	}
+	return "not " + part
+}
+
+func (this EnglishPhrasebook) Requirement(requirement string, clause bool) string {
+	if clause {
+		return "Allowed for anyone who " + requirement + "."
+	}
+	return "Requires " + requirement + "."
+}
+
+func (this EnglishPhrasebook) Constant(access bool) string {
+	if access {
+		return "Always allowed."
+	}
+	return "Never allowed."
+}
+
+func (this EnglishPhrasebook) NoBypass(condition string, clause bool) string {
+	if condition == "" {
+		return "Access cannot be bypassed."
+	}
+	return "Access cannot be bypassed " + this.condition(condition, clause) + "."
+}
+
+func (this EnglishPhrasebook) NoBypassScope(part string, condition string, nested bool, clause bool) string {
+	no_bypass := "which cannot be bypassed"
+	if condition != "" {
+		no_bypass += " " + this.condition(condition, clause)
+	}
+	if nested {
+		return "(" + part + ", " + no_bypass + ")"
+	}
+	return part + " (" + no_bypass + ")"
+}
+
+func (this EnglishPhrasebook) NoBypassNames(refused []string, accepted []string, only bool) string {
+	return "Access " + this.bypassNames(refused, accepted, only) + "."
+}
+
+func (this EnglishPhrasebook) NoBypassScopeNames(part string, refused []string, accepted []string, only bool, nested bool) string {
+	no_bypass := "which " + this.bypassNames(refused, accepted, only)
+	if nested {
+		return "(" + part + ", " + no_bypass + ")"
+	}
+	return part + " (" + no_bypass + ")"
+}
+
+func (this EnglishPhrasebook) bypassNames(refused []string, accepted []string, only bool) string {
+	names := func(names []string) string {
+		if len(names) == 1 {
+			return names[0]
+		}
+		return this.list(names, "or", false)
+	}
+	if only {
+		return "can only be bypassed by " + names(accepted)
+	}
+	clauses := []string{}
+	if len(refused) > 0 {
+		clauses = append(clauses, "cannot be bypassed by "+names(refused))
+	}
+	if len(accepted) > 0 {
+		clauses = append(clauses, "can also be bypassed by "+names(accepted))
+	}
+	return strings.Join(clauses, " but ")
+}
+
+func (this EnglishPhrasebook) condition(condition string, clause bool) string {
+	if clause {
+		return "for anyone who " + condition
+	}
+	return "if " + condition
+}
+
+func (this EnglishPhrasebook) list(parts []string, conjunction string, nested bool) string {
+	separator := " "
+	if nested {
+		separator = ", "
+	}
+	return strings.Join(parts[:len(parts)-1], ", ") + separator + conjunction + " " + parts[len(parts)-1]
+}
+
+func (this *LogicalPermissions) GetTypeTemplate(name string) (string, error) {
+	if name == "" {
+		return "", &InvalidArgumentValueError{CustomError{"The name parameter cannot be empty."}}
+	}
+	exists, _ := this.TypeExists(name)
+	if !exists {
+		return "", &PermissionTypeNotRegisteredError{CustomError{fmt.Sprintf("The permission type \"%s\" has not been registered. Please use LogicalPermissions::AddType() or LogicalPermissions::SetTypes() to register permission types.", name)}}
+	}
+	return this.type_templates[name], nil
+}
+
+func (this *LogicalPermissions) SetTypeTemplate(name string, template string) error {
+	if name == "" {
+		return &InvalidArgumentValueError{CustomError{"The name parameter cannot be empty."}}
+	}
+	exists, _ := this.TypeExists(name)
+	if !exists {
+		return &PermissionTypeNotRegisteredError{CustomError{fmt.Sprintf("The permission type \"%s\" has not been registered. Please use LogicalPermissions::AddType() or LogicalPermissions::SetTypes() to register permission types.", name)}}
+	}
+	if this.type_templates == nil {
+		this.type_templates = make(map[string]string)
+	}
+	this.type_templates[name] = template
+	return nil
+}
+
+func (this *LogicalPermissions) GetTypeNegatedTemplate(name string) (string, error) {
+	if name == "" {
+		return "", &InvalidArgumentValueError{CustomError{"The name parameter cannot be empty."}}
+	}
+	exists, _ := this.TypeExists(name)
+	if !exists {
+		return "", &PermissionTypeNotRegisteredError{CustomError{fmt.Sprintf("The permission type \"%s\" has not been registered. Please use LogicalPermissions::AddType() or LogicalPermissions::SetTypes() to register permission types.", name)}}
+	}
+	return this.negated_templates[name], nil
+}
+
+func (this *LogicalPermissions) SetTypeNegatedTemplate(name string, template string) error {
+	if name == "" {
+		return &InvalidArgumentValueError{CustomError{"The name parameter cannot be empty."}}
+	}
+	exists, _ := this.TypeExists(name)
+	if !exists {
+		return &PermissionTypeNotRegisteredError{CustomError{fmt.Sprintf("The permission type \"%s\" has not been registered. Please use LogicalPermissions::AddType() or LogicalPermissions::SetTypes() to register permission types.", name)}}
+	}
+	if this.negated_templates == nil {
+		this.negated_templates = make(map[string]string)
+	}
+	this.negated_templates[name] = template
+	return nil
+}
+
+func (this *LogicalPermissions) GetPhrasebook() Phrasebook {
+	if this.phrasebook == nil {
+		return EnglishPhrasebook{}
+	}
+	return this.phrasebook
+}
+
+func (this *LogicalPermissions) SetPhrasebook(phrasebook Phrasebook) {
+	this.phrasebook = phrasebook
+}
+
+func (this *LogicalPermissions) Describe(permissions interface{}) (string, error) {
+	tree, err := this.parsePermissionTree(permissions)
+	if err != nil {
+		return "", err
+	}
+	if err := tree.err(); err != nil {
+		return "", err
+	}
+	phrasebook := this.GetPhrasebook()
+
+	// Permission trees such as [true] are wrapped in gates with a single child.
+	node := tree.root
+	for len(node.children) == 1 && (node.name == "OR" || node.name == "AND") {
+		node = node.children[0]
+	}
+
+	description := ""
+	if len(tree.root.children) == 0 {
+		description = phrasebook.Constant(true)
+	} else if node.kind == TraceNodeBoolean {
+		description = phrasebook.Constant(node.isBoolean(true))
+	} else {
+		requirement, _ := this.describeNode(tree.root, phrasebook)
+		description = phrasebook.Requirement(requirement, this.isClause(tree.root))
+	}
+
+	if no_bypass := this.describeNoBypass(tree.no_bypass, tree.no_bypass_node, "", false, phrasebook); no_bypass != "" {
+		description += " " + no_bypass
+	}
+	return description, nil
+}
+
+// describeNoBypass describes a NO_BYPASS value, or returns an empty string if
+// it has no effect. The part is empty for the NO_BYPASS key of the root, and
+// otherwise describes the permissions that the NO_BYPASS key applies to.
+func (this *LogicalPermissions) describeNoBypass(no_bypass interface{}, no_bypass_node *permissionNode, part string, nested bool, phrasebook Phrasebook) string {
+	if no_bypass == nil && no_bypass_node == nil {
+		return ""
+	}
+	if no_bypass_node != nil {
+		condition, _ := this.describeNode(no_bypass_node, phrasebook)
+		clause := this.isClause(no_bypass_node)
+		if part == "" {
+			return phrasebook.NoBypass(condition, clause)
+		}
+		return phrasebook.NoBypassScope(part, condition, nested, clause)
+	}
+	if slice_value, ok := this.getNoBypassSlice(no_bypass); ok {
+		refusal, _ := this.parseBypassRefusal(slice_value)
+		if !refusal.all && len(refusal.refused) == 0 && len(refusal.accepted) == 0 {
+			return ""
+		}
+		if len(refusal.refused) > 0 || len(refusal.accepted) > 0 {
+			if part == "" {
+				return phrasebook.NoBypassNames(refusal.refused, refusal.accepted, refusal.all)
+			}
+			return phrasebook.NoBypassScopeNames(part, refusal.refused, refusal.accepted, refusal.all, nested)
+		}
+	} else if allow_bypass, _ := this.checkAllowBypass(no_bypass, nil, nil); allow_bypass {
+		return ""
+	}
+	if part == "" {
+		return phrasebook.NoBypass("", false)
+	}
+	return phrasebook.NoBypassScope(part, "", nested, false)
+}
+
+// isClause reports whether a node is described with verb phrases, which is
+// the case if the type of one of its permissions has a negated template.
+func (this *LogicalPermissions) isClause(node *permissionNode) bool {
+	clause := false
+	node.walk(nil, func(node *permissionNode, parent *permissionNode) {
+		if node.kind == TraceNodeValue && this.negated_templates[node.permtype] != "" {
+			clause = true
+		}
+	})
+	return clause
+}
+
+// describeNode returns the description of a node and whether the node is a gate
+// with more than one child.
+func (this *LogicalPermissions) describeNode(node *permissionNode, phrasebook Phrasebook) (string, bool) {
+	if node.kind == TraceNodeBoolean {
+		return phrasebook.Boolean(node.isBoolean(true)), false
+	}
+	if node.kind == TraceNodeValue {
+		return phrasebook.Permission(node.permtype, node.value, this.type_templates[node.permtype]), false
+	}
+	if node.kind == TraceNodeNoBypass {
+		part, nested := this.describeNode(node.children[0], phrasebook)
+		if no_bypass := this.describeNoBypass(node.no_bypass, node.no_bypass_node, part, nested, phrasebook); no_bypass != "" {
+			return no_bypass, false
+		}
+		return part, nested
+	}
+
+	parts := make([]string, len(node.children))
+	nested := false
+	for i, child := range node.children {
+		part, compound := this.describeNode(child, phrasebook)
+		parts[i] = part
+		nested = nested || compound
+	}
+	if len(parts) == 0 {
+		return phrasebook.Boolean(false), false
+	}
+	gate := node.name
+	if node.kind == TraceNodeType {
+		gate = "OR"
+	}
+	if gate == "NOT" || (len(parts) == 1 && (gate == "NAND" || gate == "NOR")) {
+		if child := node.children[0]; child.kind == TraceNodeValue {
+			return phrasebook.NegatedPermission(child.permtype, child.value, this.type_templates[child.permtype], this.negated_templates[child.permtype]), false
+		}
+		return phrasebook.Not(parts[0], nested), false
+	}
+	if len(parts) == 1 {
+		return parts[0], nested
+	}
+	return phrasebook.Gate(gate, parts, nested), true
+}
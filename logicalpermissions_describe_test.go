@@ -0,0 +1,200 @@
+package logicalpermissions_test
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/ordermind/logical-permissions-go"
+	"github.com/stretchr/testify/assert"
+)
+
+type pirateFlagPhrasebook struct {
+	EnglishPhrasebook
+}
+
+func (this pirateFlagPhrasebook) Requirement(requirement string, clause bool) string {
+	return "Arr, ye need " + requirement + "!"
+}
+
+func (this pirateFlagPhrasebook) Permission(permtype string, value string, template string) string {
+	return strings.ToUpper(this.EnglishPhrasebook.Permission(permtype, value, template))
+}
+
+/*-------------LogicalPermissions::SetTypeTemplate()--------------*/
+
+func TestSetTypeTemplate(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	type_callback := func(string, map[string]interface{}) (bool, error) { return true, nil }
+	lp.AddType("role", type_callback)
+	lp.AddType("flag", type_callback)
+	lp.AddType("group", type_callback)
+	err := lp.SetTypeTemplate("", "has role %s")
+	if assert.Error(t, err) {
+		assert.IsType(t, &InvalidArgumentValueError{}, err)
+	}
+	err = lp.SetTypeTemplate("test", "has role %s")
+	if assert.Error(t, err) {
+		assert.IsType(t, &PermissionTypeNotRegisteredError{}, err)
+	}
+
+	template, err := lp.GetTypeTemplate("role")
+	assert.Nil(t, err)
+	assert.Equal(t, "", template)
+	assert.Nil(t, lp.SetTypeTemplate("role", "has role %s"))
+	template, err = lp.GetTypeTemplate("role")
+	assert.Nil(t, err)
+	assert.Equal(t, "has role %s", template)
+
+	assert.Nil(t, lp.RemoveType("role"))
+	assert.Nil(t, lp.AddType("role", func(string, map[string]interface{}) (bool, error) { return true, nil }))
+	template, err = lp.GetTypeTemplate("role")
+	assert.Nil(t, err)
+	assert.Equal(t, "", template)
+}
+
+/*-------------LogicalPermissions::SetTypeNegatedTemplate()--------------*/
+
+func TestSetTypeNegatedTemplate(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	type_callback := func(string, map[string]interface{}) (bool, error) { return true, nil }
+	lp.AddType("role", type_callback)
+	lp.AddType("flag", type_callback)
+	lp.AddType("group", type_callback)
+	err := lp.SetTypeNegatedTemplate("", "does not have role %s")
+	if assert.Error(t, err) {
+		assert.IsType(t, &InvalidArgumentValueError{}, err)
+	}
+	err = lp.SetTypeNegatedTemplate("test", "does not have role %s")
+	if assert.Error(t, err) {
+		assert.IsType(t, &PermissionTypeNotRegisteredError{}, err)
+	}
+
+	assert.Nil(t, lp.SetTypeNegatedTemplate("role", "does not have role %s"))
+	template, err := lp.GetTypeNegatedTemplate("role")
+	assert.Nil(t, err)
+	assert.Equal(t, "does not have role %s", template)
+
+	assert.Nil(t, lp.RemoveType("role"))
+	assert.Nil(t, lp.AddType("role", func(string, map[string]interface{}) (bool, error) { return true, nil }))
+	template, err = lp.GetTypeNegatedTemplate("role")
+	assert.Nil(t, err)
+	assert.Equal(t, "", template)
+}
+
+/*-------------LogicalPermissions::Describe()--------------*/
+
+func TestDescribe(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	type_callback := func(string, map[string]interface{}) (bool, error) { return true, nil }
+	lp.AddType("role", type_callback)
+	lp.AddType("flag", type_callback)
+	lp.AddType("group", type_callback)
+	assert.Nil(t, lp.SetTypeTemplate("flag", "the %s flag"))
+	assert.Nil(t, lp.SetTypeTemplate("group", "being a %s"))
+
+	description, err := lp.Describe(`{"AND": [{"role": "admin"}, {"OR": [{"flag": "beta"}, {"group": {"NOT": "guest"}}]}]}`)
+	assert.Nil(t, err)
+	assert.Equal(t, "Requires role admin, and either the beta flag or not being a guest.", description)
+
+	description, err = lp.Describe(`{"role": ["editor", "writer", "admin"]}`)
+	assert.Nil(t, err)
+	assert.Equal(t, "Requires one of role editor, role writer or role admin.", description)
+
+	description, err = lp.Describe(`{"NAND": {"role": "sales", "flag": "is_author"}}`)
+	assert.Nil(t, err)
+	assert.Equal(t, "Requires not both the is_author flag and role sales.", description)
+
+	description, err = lp.Describe(`{"role": {"NOR": ["editor", "sales"]}}`)
+	assert.Nil(t, err)
+	assert.Equal(t, "Requires neither role editor nor role sales.", description)
+
+	description, err = lp.Describe(`{"role": {"XOR": ["editor", "sales"]}}`)
+	assert.Nil(t, err)
+	assert.Equal(t, "Requires either role editor or role sales, but not both.", description)
+
+	description, err = lp.Describe(`{"NOT": {"AND": {"role": "a", "flag": "b"}}}`)
+	assert.Nil(t, err)
+	assert.Equal(t, "Requires not (the b flag and role a).", description)
+}
+
+func TestDescribeVerbPhrases(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	type_callback := func(string, map[string]interface{}) (bool, error) { return true, nil }
+	lp.AddType("role", type_callback)
+	lp.AddType("flag", type_callback)
+	lp.AddType("group", type_callback)
+	assert.Nil(t, lp.SetTypeTemplate("role", "has role %s"))
+	assert.Nil(t, lp.SetTypeNegatedTemplate("role", "does not have role %s"))
+	assert.Nil(t, lp.SetTypeTemplate("flag", "is %s"))
+	assert.Nil(t, lp.SetTypeNegatedTemplate("flag", "is not %s"))
+
+	description, err := lp.Describe(`{"role": ["admin", {"NOT": "guest"}]}`)
+	assert.Nil(t, err)
+	assert.Equal(t, "Allowed for anyone who either has role admin or does not have role guest.", description)
+
+	description, err = lp.Describe(`{"NO_BYPASS": {"flag": "locked"}, "role": {"NOR": ["banned"]}}`)
+	assert.Nil(t, err)
+	assert.Equal(t, "Allowed for anyone who does not have role banned. Access cannot be bypassed for anyone who is locked.", description)
+
+	// Types without a negated template are still described with noun phrases.
+	description, err = lp.Describe(`{"group": {"NOT": "guest"}}`)
+	assert.Nil(t, err)
+	assert.Equal(t, "Requires not group guest.", description)
+}
+
+func TestDescribeConstantsAndNoBypass(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	type_callback := func(string, map[string]interface{}) (bool, error) { return true, nil }
+	lp.AddType("role", type_callback)
+	lp.AddType("flag", type_callback)
+	lp.AddType("group", type_callback)
+	description, err := lp.Describe(true)
+	assert.Nil(t, err)
+	assert.Equal(t, "Always allowed.", description)
+
+	description, err = lp.Describe(`{"0": false, "NO_BYPASS": true}`)
+	assert.Nil(t, err)
+	assert.Equal(t, "Never allowed. Access cannot be bypassed.", description)
+
+	description, err = lp.Describe(`{"no_bypass": "FALSE", "role": "admin"}`)
+	assert.Nil(t, err)
+	assert.Equal(t, "Requires role admin.", description)
+
+	description, err = lp.Describe(`{"NO_BYPASS": {"flag": "locked"}, "role": "admin"}`)
+	assert.Nil(t, err)
+	assert.Equal(t, "Requires role admin. Access cannot be bypassed if flag locked.", description)
+}
+
+func TestDescribePhrasebook(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	type_callback := func(string, map[string]interface{}) (bool, error) { return true, nil }
+	lp.AddType("role", type_callback)
+	lp.AddType("flag", type_callback)
+	lp.AddType("group", type_callback)
+	assert.Equal(t, EnglishPhrasebook{}, lp.GetPhrasebook())
+	lp.SetPhrasebook(pirateFlagPhrasebook{})
+	description, err := lp.Describe(`{"AND": {"role": "captain", "flag": "parrot"}}`)
+	assert.Nil(t, err)
+	assert.Equal(t, "Arr, ye need FLAG PARROT and ROLE CAPTAIN!", description)
+	lp.SetPhrasebook(nil)
+	assert.Equal(t, EnglishPhrasebook{}, lp.GetPhrasebook())
+}
+
+func TestDescribeInvalidPermissions(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	type_callback := func(string, map[string]interface{}) (bool, error) { return true, nil }
+	lp.AddType("role", type_callback)
+	lp.AddType("flag", type_callback)
+	lp.AddType("group", type_callback)
+	_, err := lp.Describe(`{"XOR": ["TRUE"]}`)
+	if assert.Error(t, err) {
+		assert.IsType(t, &InvalidValueForLogicGateError{}, err)
+	}
+}
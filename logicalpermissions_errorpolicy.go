@@ -0,0 +1,122 @@
+package logicalpermissions
+
+import (
+	"fmt"
+)
+
+// ErrorPolicy decides how an error returned by the callback of a permission
+// type is handled.
+type ErrorPolicy int
+
+const (
+	// ErrorPolicyAbort aborts the access check and returns the error.
+	ErrorPolicyAbort ErrorPolicy = iota
+	// ErrorPolicyFalse treats the permission as denied.
+	ErrorPolicyFalse
+	// ErrorPolicyTrue treats the permission as granted.
+	ErrorPolicyTrue
+	// ErrorPolicyUnknown treats the result of the permission as unknown, as if
+	// the callback had returned ErrUnknown in three-valued mode.
+	ErrorPolicyUnknown
+)
+
+func (this ErrorPolicy) String() string {
+	switch this {
+	case ErrorPolicyAbort:
+		return "abort"
+	case ErrorPolicyFalse:
+		return "false"
+	case ErrorPolicyTrue:
+		return "true"
+	case ErrorPolicyUnknown:
+		return "unknown"
+	}
+	return fmt.Sprintf("ErrorPolicy(%d)", int(this))
+}
+
+func (this ErrorPolicy) isValid() bool {
+	return this >= ErrorPolicyAbort && this <= ErrorPolicyUnknown
+}
+
+func (this *LogicalPermissions) GetErrorPolicy() ErrorPolicy {
+	return this.error_policy
+}
+
+func (this *LogicalPermissions) SetErrorPolicy(policy ErrorPolicy) error {
+	if !policy.isValid() {
+		return &InvalidArgumentValueError{CustomError{fmt.Sprintf("The policy parameter is not a valid error policy. Current value: %d", int(policy))}}
+	}
+	this.error_policy = policy
+	return nil
+}
+
+func (this *LogicalPermissions) GetTypeErrorPolicy(name string) (ErrorPolicy, error) {
+	if name == "" {
+		return ErrorPolicyAbort, &InvalidArgumentValueError{CustomError{"The name parameter cannot be empty."}}
+	}
+	exists, _ := this.TypeExists(name)
+	if !exists {
+		return ErrorPolicyAbort, &PermissionTypeNotRegisteredError{CustomError{fmt.Sprintf("The permission type \"%s\" has not been registered. Please use LogicalPermissions::AddType() or LogicalPermissions::SetTypes() to register permission types.", name)}}
+	}
+	return this.getErrorPolicy(name), nil
+}
+
+func (this *LogicalPermissions) SetTypeErrorPolicy(name string, policy ErrorPolicy) error {
+	if name == "" {
+		return &InvalidArgumentValueError{CustomError{"The name parameter cannot be empty."}}
+	}
+	exists, _ := this.TypeExists(name)
+	if !exists {
+		return &PermissionTypeNotRegisteredError{CustomError{fmt.Sprintf("The permission type \"%s\" has not been registered. Please use LogicalPermissions::AddType() or LogicalPermissions::SetTypes() to register permission types.", name)}}
+	}
+	if !policy.isValid() {
+		return &InvalidArgumentValueError{CustomError{fmt.Sprintf("The policy parameter is not a valid error policy. Current value: %d", int(policy))}}
+	}
+	if this.type_policies == nil {
+		this.type_policies = make(map[string]ErrorPolicy)
+	}
+	this.type_policies[name] = policy
+	return nil
+}
+
+func (this *LogicalPermissions) GetContinueOnError() bool {
+	return this.continue_on_error
+}
+
+func (this *LogicalPermissions) SetContinueOnError(continue_on_error bool) {
+	this.continue_on_error = continue_on_error
+}
+
+// getErrorPolicy returns the error policy of a permission type, which is the
+// global error policy unless the type has its own.
+func (this *LogicalPermissions) getErrorPolicy(permtype string) ErrorPolicy {
+	if policy, ok := this.type_policies[permtype]; ok {
+		return policy
+	}
+	return this.error_policy
+}
+
+// applyErrorPolicy handles an error returned by the callback of a permission
+// type. If the access check continues on errors, an error that would abort
+// the access check makes the result of the permission unknown instead, and
+// the error is returned by the access check only if the result of the access
+// check is unknown because of it.
+func (this *LogicalPermissions) applyErrorPolicy(permtype string, err_custom CustomErrorInterface, eval *evaluation) (bool, CustomErrorInterface) {
+	policy := this.getErrorPolicy(permtype)
+	if policy != ErrorPolicyAbort || this.continue_on_error {
+		eval.setHandledError()
+	}
+	switch policy {
+	case ErrorPolicyFalse:
+		return false, nil
+	case ErrorPolicyTrue:
+		return true, nil
+	case ErrorPolicyUnknown:
+		return false, newUnknownResult()
+	}
+	if this.continue_on_error {
+		return false, &unknownResult{CustomError: CustomError{ErrUnknown.Error()}, cause: err_custom}
+	}
+	eval.setErrorCategory(MetricsErrorCallback)
+	return false, err_custom
+}
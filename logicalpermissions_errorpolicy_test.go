@@ -0,0 +1,218 @@
+package logicalpermissions_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	. "github.com/ordermind/logical-permissions-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// flagService grants the flag "yes" and fails for the flag "broken".
+func flagService(flag string, context map[string]interface{}) (bool, error) {
+	if flag == "broken" {
+		return false, errors.New("The flag service is down.")
+	}
+	return flag == "yes", nil
+}
+
+// roleService grants the role "admin" and fails for the role "broken".
+func roleService(role string, context map[string]interface{}) (bool, error) {
+	if role == "broken" {
+		return false, errors.New("The role service is down.")
+	}
+	return role == "admin", nil
+}
+
+/*-------------ErrorPolicy--------------*/
+
+func TestErrorPolicyString(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "abort", ErrorPolicyAbort.String())
+	assert.Equal(t, "false", ErrorPolicyFalse.String())
+	assert.Equal(t, "true", ErrorPolicyTrue.String())
+	assert.Equal(t, "unknown", ErrorPolicyUnknown.String())
+	assert.Equal(t, "ErrorPolicy(9)", ErrorPolicy(9).String())
+}
+
+/*-------------LogicalPermissions::SetErrorPolicy()--------------*/
+
+func TestSetErrorPolicyParams(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	lp.AddType("flag", flagService)
+	lp.AddType("role", roleService)
+	lp.SetBypassCallback(func(context map[string]interface{}) (bool, error) { return context["bypass"] == true, nil })
+	err := lp.SetErrorPolicy(ErrorPolicy(9))
+	assert.IsType(t, &InvalidArgumentValueError{}, err)
+	err = lp.SetTypeErrorPolicy("", ErrorPolicyFalse)
+	assert.IsType(t, &InvalidArgumentValueError{}, err)
+	err = lp.SetTypeErrorPolicy("unregistered", ErrorPolicyFalse)
+	assert.IsType(t, &PermissionTypeNotRegisteredError{}, err)
+	err = lp.SetTypeErrorPolicy("flag", ErrorPolicy(-1))
+	assert.IsType(t, &InvalidArgumentValueError{}, err)
+	_, err = lp.GetTypeErrorPolicy("")
+	assert.IsType(t, &InvalidArgumentValueError{}, err)
+	_, err = lp.GetTypeErrorPolicy("unregistered")
+	assert.IsType(t, &PermissionTypeNotRegisteredError{}, err)
+}
+
+func TestSetErrorPolicy(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	lp.AddType("flag", flagService)
+	lp.AddType("role", roleService)
+	lp.SetBypassCallback(func(context map[string]interface{}) (bool, error) { return context["bypass"] == true, nil })
+	assert.Equal(t, ErrorPolicyAbort, lp.GetErrorPolicy())
+	assert.Nil(t, lp.SetErrorPolicy(ErrorPolicyFalse))
+	assert.Equal(t, ErrorPolicyFalse, lp.GetErrorPolicy())
+
+	// The policy of a type defaults to the global policy.
+	policy, err := lp.GetTypeErrorPolicy("flag")
+	assert.Nil(t, err)
+	assert.Equal(t, ErrorPolicyFalse, policy)
+	assert.Nil(t, lp.SetTypeErrorPolicy("flag", ErrorPolicyTrue))
+	policy, _ = lp.GetTypeErrorPolicy("flag")
+	assert.Equal(t, ErrorPolicyTrue, policy)
+	policy, _ = lp.GetTypeErrorPolicy("role")
+	assert.Equal(t, ErrorPolicyFalse, policy)
+
+	lp.RemoveType("flag")
+	lp.AddType("flag", func(string, map[string]interface{}) (bool, error) { return true, nil })
+	policy, _ = lp.GetTypeErrorPolicy("flag")
+	assert.Equal(t, ErrorPolicyFalse, policy)
+}
+
+/*-------------Error policies--------------*/
+
+func TestCheckAccessErrorPolicy(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	lp.AddType("flag", flagService)
+	lp.AddType("role", roleService)
+	lp.SetBypassCallback(func(context map[string]interface{}) (bool, error) { return context["bypass"] == true, nil })
+	broken := map[string]interface{}{"flag": "broken"}
+	not_broken := map[string]interface{}{"NOT": broken}
+
+	access, err := lp.CheckAccess(broken, map[string]interface{}{})
+	assert.EqualError(t, err, "Error checking access: The flag service is down.")
+	assert.False(t, access)
+
+	lp.SetErrorPolicy(ErrorPolicyFalse)
+	access, err = lp.CheckAccess(broken, map[string]interface{}{})
+	assert.Nil(t, err)
+	assert.False(t, access)
+	access, _ = lp.CheckAccess(not_broken, map[string]interface{}{})
+	assert.True(t, access)
+
+	lp.SetErrorPolicy(ErrorPolicyTrue)
+	access, err = lp.CheckAccess(broken, map[string]interface{}{})
+	assert.Nil(t, err)
+	assert.True(t, access)
+	access, _ = lp.CheckAccess(not_broken, map[string]interface{}{})
+	assert.False(t, access)
+
+	// An unknown result denies access, even if three-valued mode is disabled.
+	lp.SetErrorPolicy(ErrorPolicyUnknown)
+	access, err = lp.CheckAccess(not_broken, map[string]interface{}{})
+	assert.Nil(t, err)
+	assert.False(t, access)
+	result, err := lp.CheckAccessTriState(not_broken, map[string]interface{}{})
+	assert.Nil(t, err)
+	assert.Equal(t, TriStateUnknown, result)
+	result, _ = lp.CheckAccessTriState(map[string]interface{}{"OR": []interface{}{broken, "TRUE"}}, map[string]interface{}{})
+	assert.Equal(t, TriStateTrue, result)
+
+	// The policy of a type takes precedence over the global policy.
+	lp.SetErrorPolicy(ErrorPolicyAbort)
+	lp.SetTypeErrorPolicy("flag", ErrorPolicyTrue)
+	access, err = lp.CheckAccess(broken, map[string]interface{}{})
+	assert.Nil(t, err)
+	assert.True(t, access)
+	_, err = lp.CheckAccess(map[string]interface{}{"role": "broken"}, map[string]interface{}{})
+	assert.EqualError(t, err, "Error checking access: The role service is down.")
+}
+
+/*-------------LogicalPermissions::SetContinueOnError()--------------*/
+
+func TestCheckAccessContinueOnError(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	lp.AddType("flag", flagService)
+	lp.AddType("role", roleService)
+	lp.SetBypassCallback(func(context map[string]interface{}) (bool, error) { return context["bypass"] == true, nil })
+	parallel := LogicalPermissions{}
+	parallel.AddType("flag", flagService)
+	parallel.AddType("role", roleService)
+	parallel.SetBypassCallback(func(context map[string]interface{}) (bool, error) { return context["bypass"] == true, nil })
+	parallel.SetParallelism(4)
+	assert.False(t, lp.GetContinueOnError())
+	lp.SetContinueOnError(true)
+	assert.True(t, lp.GetContinueOnError())
+	parallel.SetContinueOnError(true)
+	broken := map[string]interface{}{"flag": "broken"}
+	yes := map[string]interface{}{"flag": "yes"}
+	no := map[string]interface{}{"flag": "no"}
+	tests := []struct {
+		permissions interface{}
+		expected    bool
+		err         string
+	}{
+		{map[string]interface{}{"OR": []interface{}{broken, yes}}, true, ""},
+		{map[string]interface{}{"OR": []interface{}{broken, no}}, false, "Error checking access: The flag service is down."},
+		{map[string]interface{}{"AND": []interface{}{broken, no}}, false, ""},
+		{map[string]interface{}{"AND": []interface{}{broken, yes}}, false, "Error checking access: The flag service is down."},
+		{map[string]interface{}{"NOT": broken}, false, "Error checking access: The flag service is down."},
+		{map[string]interface{}{"NOR": []interface{}{yes, broken}}, false, ""},
+		{map[string]interface{}{"XOR": []interface{}{broken, yes, no}}, true, ""},
+		{map[string]interface{}{"OR": []interface{}{map[string]interface{}{"role": "broken"}, broken}}, false, "Error checking access: The role service is down."},
+		{map[string]interface{}{"NO_BYPASS": broken, "flag": "no"}, false, ""},
+	}
+	for _, test := range tests {
+		message := fmt.Sprintf("Permissions: %v", test.permissions)
+		for _, checker := range []*LogicalPermissions{&lp, &parallel} {
+			access, err := checker.CheckAccess(test.permissions, map[string]interface{}{})
+			assert.Equal(t, test.expected, access, message)
+			if test.err == "" {
+				assert.Nil(t, err, message)
+			} else {
+				assert.EqualError(t, err, test.err, message)
+			}
+		}
+	}
+
+	// An error in the NO_BYPASS permissions is returned if the bypass would
+	// have granted access.
+	permissions := map[string]interface{}{"NO_BYPASS": broken, "flag": "no"}
+	_, err := lp.CheckAccess(permissions, map[string]interface{}{"bypass": true})
+	assert.EqualError(t, err, "Error checking NO_BYPASS permissions: The flag service is down.")
+	permissions = map[string]interface{}{"NO_BYPASS": broken, "flag": "yes"}
+	access, err := lp.CheckAccess(permissions, map[string]interface{}{"bypass": true})
+	assert.Nil(t, err)
+	assert.True(t, access)
+
+	// Error policies other than abort are applied before continuing.
+	lp.SetTypeErrorPolicy("flag", ErrorPolicyFalse)
+	access, err = lp.CheckAccess(map[string]interface{}{"OR": []interface{}{broken, no}}, map[string]interface{}{})
+	assert.Nil(t, err)
+	assert.False(t, access)
+}
+
+func TestCheckAccessContinueOnErrorTrace(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	lp.AddType("flag", flagService)
+	lp.AddType("role", roleService)
+	lp.SetBypassCallback(func(context map[string]interface{}) (bool, error) { return context["bypass"] == true, nil })
+	lp.SetContinueOnError(true)
+	access, trace, err := lp.CheckAccessNoBypassWithTrace(map[string]interface{}{"OR": []interface{}{map[string]interface{}{"flag": "broken"}, map[string]interface{}{"flag": "yes"}}}, map[string]interface{}{})
+	assert.Nil(t, err)
+	assert.True(t, access)
+	assert.Equal(t, "", trace.Error)
+	gate := trace.Root.Children[0]
+	assert.True(t, gate.Result)
+	assert.Equal(t, "The flag service is down.", gate.Children[0].Error)
+	assert.True(t, gate.Children[0].Unknown)
+	assert.True(t, gate.Children[1].Result)
+}
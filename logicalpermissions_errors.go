@@ -30,3 +30,57 @@ type PermissionTypeNotRegisteredError struct {
 type PermissionTypeAlreadyExistsError struct {
 	CustomError
 }
+
+// BypassNotRegisteredError is returned if a named bypass is removed or referred
+// to by a NO_BYPASS slice without having been registered with
+// LogicalPermissions::AddBypass().
+type BypassNotRegisteredError struct {
+	CustomError
+}
+
+// BypassAlreadyExistsError is returned if a named bypass is added with the
+// name of a bypass that has already been registered.
+type BypassAlreadyExistsError struct {
+	CustomError
+}
+
+// RecordedResultMissingError is returned while replaying a decision if it needs
+// the result of a callback that was not recorded in the DecisionRecord.
+type RecordedResultMissingError struct {
+	CustomError
+}
+
+// CallbackTimeoutError is returned if the callback of a permission type does
+// not return within the Timeout of its type options.
+type CallbackTimeoutError struct {
+	CustomError
+}
+
+// CallbackConcurrencyLimitError is returned if the callback of a permission
+// type is called while MaxConcurrent calls of it are in flight.
+type CallbackConcurrencyLimitError struct {
+	CustomError
+}
+
+// CircuitOpenError is returned if the callback of a permission type is not
+// called because its circuit breaker is open.
+type CircuitOpenError struct {
+	CustomError
+}
+
+// LimitExceededError is returned if a permission tree or an access check
+// exceeds the policy limits.
+type LimitExceededError struct {
+	CustomError
+}
+
+// CallbackPanicError is returned if a callback panics while panics are
+// recovered. The Type is empty for the bypass callback, and the Permission is
+// empty for the bypass callback and for batch callbacks.
+type CallbackPanicError struct {
+	CustomError
+	Type       string
+	Permission string
+	Recovered  interface{}
+	Stack      []byte
+}
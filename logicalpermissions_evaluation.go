@@ -0,0 +1,275 @@
+package logicalpermissions
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// evaluation holds the state of a single access check.
+type evaluation struct {
+	check_id uint64
+	trace    *Trace
+	path     []string
+	nodes    []*TraceNode
+	// Callback results are stored in record_results, and both looked up and
+	// stored in reuse_results.
+	record_results *callbackResults
+	reuse_results  *callbackResults
+	// shadow is true for evaluations of candidate permission trees, which are
+	// neither observed nor measured.
+	shadow bool
+	// error_category is set when a callback returns an error, and
+	// handled_error is set when a callback error is handled by an error
+	// policy instead of being returned.
+	error_category string
+	handled_error  bool
+	bypass_granted bool
+	// bypass holds the names of the granted bypasses while bypass access
+	// applies to the evaluated permissions, which is only the case for
+	// permission trees with NO_BYPASS keys below the root. bypass_available
+	// also holds the granted named bypasses that NO_BYPASS slices can still
+	// accept. bypass_used is set once bypass access has granted access to a
+	// subtree.
+	bypass           []string
+	bypass_available []string
+	bypass_used      bool
+	// unknown is true if the result of the access check is unknown in
+	// three-valued mode, and cached is true if the decision was returned by
+	// the cache.
+	unknown bool
+	cached  bool
+	// span is the tracing span of the access check.
+	span Span
+	// tree is the parsed permission tree of the access check if it has batch
+	// callbacks or optimizes the order, and batch holds the results of batch
+	// callbacks.
+	tree  *permissionTree
+	batch *batchResults
+	// slots limits the number of goroutines that evaluate gate children in
+	// parallel, or is nil if gates are evaluated sequentially. Evaluations that
+	// run in other goroutines are forks that stop calling callbacks once
+	// cancel is cancelled.
+	slots  chan struct{}
+	cancel *evaluationCancel
+	// callbacks counts the permission values that are checked with callbacks
+	// if the number is limited. It is shared with forks.
+	callbacks *int32
+	// optimize_order is true if the children of gates are evaluated in the
+	// order of their estimated cost.
+	optimize_order bool
+}
+
+// batchResults holds the results of the permissions that have been resolved
+// with batch callbacks, and the duration of the batch call of each type.
+type batchResults struct {
+	mutex     sync.Mutex
+	results   map[string]callbackResult
+	durations map[string]time.Duration
+}
+
+func newBatchResults() *batchResults {
+	return &batchResults{results: make(map[string]callbackResult), durations: make(map[string]time.Duration)}
+}
+
+// evaluationCancel cancels forked evaluations. A fork is cancelled if its own
+// evaluationCancel or any of its parents is cancelled.
+type evaluationCancel struct {
+	cancelled int32
+	parent    *evaluationCancel
+}
+
+func (this *evaluationCancel) cancelEvaluation() {
+	atomic.StoreInt32(&this.cancelled, 1)
+}
+
+func (this *evaluationCancel) isCancelled() bool {
+	for cancel := this; cancel != nil; cancel = cancel.parent {
+		if atomic.LoadInt32(&cancel.cancelled) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// bypassResultKey is the callbackResults key of the bypass callback, and the
+// prefix of the keys of named bypasses. It cannot collide with the keys of
+// permission types because type names cannot be empty.
+const bypassResultKey = "\x00"
+
+type callbackResult struct {
+	access bool
+	err    error
+}
+
+// callbackResults stores the results of callbacks so that they can be reused
+// by other evaluations.
+type callbackResults struct {
+	mutex   sync.Mutex
+	results map[string]callbackResult
+}
+
+func newCallbackResults() *callbackResults {
+	return &callbackResults{results: make(map[string]callbackResult)}
+}
+
+func getCallbackResultKey(permtype string, permission string) string {
+	return permtype + "\x00" + permission
+}
+
+func (this *callbackResults) get(key string) (callbackResult, bool) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	result, ok := this.results[key]
+	return result, ok
+}
+
+func (this *callbackResults) clear() {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.results = make(map[string]callbackResult)
+}
+
+func (this *callbackResults) set(key string, result callbackResult) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.results[key] = result
+}
+
+func (eval *evaluation) getCheckID() uint64 {
+	if eval == nil {
+		return 0
+	}
+	return eval.check_id
+}
+
+func (eval *evaluation) isShadow() bool {
+	return eval != nil && eval.shadow
+}
+
+// setErrorCategory records the category of the first callback error.
+func (eval *evaluation) setErrorCategory(category string) {
+	if eval == nil || eval.error_category != "" {
+		return
+	}
+	eval.error_category = category
+}
+
+func (eval *evaluation) setHandledError() {
+	if eval == nil {
+		return
+	}
+	eval.handled_error = true
+}
+
+func (eval *evaluation) getCallbackResult(key string) (callbackResult, bool) {
+	if eval == nil || eval.reuse_results == nil {
+		return callbackResult{}, false
+	}
+	return eval.reuse_results.get(key)
+}
+
+func (eval *evaluation) setCallbackResult(key string, result callbackResult) {
+	if eval == nil {
+		return
+	}
+	if eval.record_results != nil {
+		eval.record_results.set(key, result)
+	}
+	if eval.reuse_results != nil {
+		eval.reuse_results.set(key, result)
+	}
+}
+
+func (eval *evaluation) pushPath(segment string) {
+	if eval == nil {
+		return
+	}
+	eval.path = append(eval.path, segment)
+}
+
+func (eval *evaluation) popPath() {
+	if eval == nil {
+		return
+	}
+	eval.path = eval.path[:len(eval.path)-1]
+}
+
+func (eval *evaluation) getPath() string {
+	if eval == nil || len(eval.path) == 0 {
+		return ""
+	}
+	escaper := strings.NewReplacer("~", "~0", "/", "~1")
+	segments := make([]string, len(eval.path))
+	for i, segment := range eval.path {
+		segments[i] = escaper.Replace(segment)
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+func (eval *evaluation) beginNode(kind string, name string, permtype string) *TraceNode {
+	if eval == nil || eval.trace == nil {
+		return nil
+	}
+	node := &TraceNode{Path: eval.getPath(), Kind: kind, Name: name, Type: permtype}
+	if len(eval.nodes) > 0 {
+		parent := eval.nodes[len(eval.nodes)-1]
+		parent.Children = append(parent.Children, node)
+	}
+	eval.nodes = append(eval.nodes, node)
+	return node
+}
+
+func (eval *evaluation) endNode(node *TraceNode, result bool, err error) {
+	if node == nil {
+		return
+	}
+	node.Result = result
+	if isUnknownResult(err) {
+		node.Unknown = true
+		if cause := err.(*unknownResult).cause; cause != nil && node.Kind == TraceNodeValue {
+			node.Error = cause.Error()
+		}
+	} else if err != nil {
+		node.Error = err.Error()
+	}
+	eval.nodes = eval.nodes[:len(eval.nodes)-1]
+}
+
+func (eval *evaluation) isCancelled() bool {
+	return eval != nil && eval.cancel.isCancelled()
+}
+
+// fork copies the evaluation for a gate child that may be evaluated in another
+// goroutine. Trace nodes of the fork are collected below a placeholder node
+// until they are merged.
+func (eval *evaluation) fork(cancel *evaluationCancel) *evaluation {
+	child := *eval
+	child.path = append([]string(nil), eval.path...)
+	child.nodes = nil
+	if eval.trace != nil {
+		child.nodes = []*TraceNode{&TraceNode{}}
+	}
+	child.error_category = ""
+	child.cancel = cancel
+	return &child
+}
+
+// merge adds the trace nodes, the error category, the handled errors and the
+// use of bypass access of a finished fork to the evaluation.
+func (eval *evaluation) merge(child *evaluation) {
+	if len(eval.nodes) > 0 && len(child.nodes) > 0 {
+		parent := eval.nodes[len(eval.nodes)-1]
+		parent.Children = append(parent.Children, child.nodes[0].Children...)
+	}
+	if child.error_category != "" {
+		eval.setErrorCategory(child.error_category)
+	}
+	if child.handled_error {
+		eval.setHandledError()
+	}
+	if child.bypass_used {
+		eval.bypass_used = true
+	}
+}
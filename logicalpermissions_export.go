@@ -0,0 +1,245 @@
+package logicalpermissions
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Overlay states of exported nodes.
+const (
+	exportStateNone    = ""
+	exportStateTrue    = "true"
+	exportStateFalse   = "false"
+	exportStateError   = "error"
+	exportStateUnknown = "unknown"
+	exportStateSkipped = "skipped"
+)
+
+type exportNode struct {
+	id       string
+	kind     string
+	label    string
+	state    string
+	children []*exportNode
+}
+
+func (this *LogicalPermissions) ExportDOT(permissions interface{}, trace *Trace) (string, error) {
+	nodes, err := this.getExportNodes(permissions, trace)
+	if err != nil {
+		return "", err
+	}
+
+	var buffer bytes.Buffer
+	buffer.WriteString("digraph permissions {\n")
+	buffer.WriteString("  node [fontname=\"Helvetica\"];\n")
+	var write func(node *exportNode)
+	write = func(node *exportNode) {
+		attributes := []string{"label=" + escapeDOTLabel(node.label)}
+		styles := []string{}
+		if node.kind == TraceNodeGate {
+			attributes = append(attributes, "shape=ellipse")
+		} else if node.kind == "note" || node.kind == TraceNodeNoBypass {
+			attributes = append(attributes, "shape=note")
+		} else {
+			attributes = append(attributes, "shape=box")
+			if node.kind != TraceNodeType {
+				styles = append(styles, "rounded")
+			}
+		}
+		if color := this.getExportColor(node.state); color != "" {
+			styles = append(styles, "filled")
+			attributes = append(attributes, fmt.Sprintf("fillcolor=%q", color))
+		}
+		if node.state == exportStateSkipped {
+			styles = append(styles, "dashed")
+			attributes = append(attributes, "fontcolor=\"gray50\"", "color=\"gray50\"")
+		}
+		if len(styles) > 0 {
+			attributes = append(attributes, fmt.Sprintf("style=%q", strings.Join(styles, ",")))
+		}
+		fmt.Fprintf(&buffer, "  %s [%s];\n", node.id, strings.Join(attributes, ", "))
+		for _, child := range node.children {
+			write(child)
+			fmt.Fprintf(&buffer, "  %s -> %s;\n", node.id, child.id)
+		}
+	}
+	for _, node := range nodes {
+		write(node)
+	}
+	buffer.WriteString("}\n")
+	return buffer.String(), nil
+}
+
+func (this *LogicalPermissions) ExportMermaid(permissions interface{}, trace *Trace) (string, error) {
+	nodes, err := this.getExportNodes(permissions, trace)
+	if err != nil {
+		return "", err
+	}
+
+	var buffer bytes.Buffer
+	buffer.WriteString("flowchart TD\n")
+	states := make(map[string][]string)
+	var write func(node *exportNode)
+	write = func(node *exportNode) {
+		label := escapeMermaidLabel(node.label)
+		if node.kind == TraceNodeGate {
+			fmt.Fprintf(&buffer, "  %s{{%s}}\n", node.id, label)
+		} else if node.kind == TraceNodeType {
+			fmt.Fprintf(&buffer, "  %s[%s]\n", node.id, label)
+		} else if node.kind == "note" || node.kind == TraceNodeNoBypass {
+			fmt.Fprintf(&buffer, "  %s>%s]\n", node.id, label)
+		} else {
+			fmt.Fprintf(&buffer, "  %s(%s)\n", node.id, label)
+		}
+		if node.state != exportStateNone {
+			states[node.state] = append(states[node.state], node.id)
+		}
+		for _, child := range node.children {
+			write(child)
+			fmt.Fprintf(&buffer, "  %s --> %s\n", node.id, child.id)
+		}
+	}
+	for _, node := range nodes {
+		write(node)
+	}
+	for _, state := range []string{exportStateTrue, exportStateFalse, exportStateError, exportStateUnknown, exportStateSkipped} {
+		if len(states[state]) == 0 {
+			continue
+		}
+		if state == exportStateSkipped {
+			fmt.Fprintf(&buffer, "  classDef %s stroke-dasharray:5 5,color:#7f7f7f\n", state)
+		} else {
+			fmt.Fprintf(&buffer, "  classDef %s fill:%s\n", state, this.getExportColor(state))
+		}
+		fmt.Fprintf(&buffer, "  class %s %s\n", strings.Join(states[state], ","), state)
+	}
+	return buffer.String(), nil
+}
+
+// escapeDOTLabel quotes a label for a DOT graph. Only backslashes, double quotes
+// and line breaks are escaped, because Graphviz does not interpret Go escape
+// sequences and shows other characters as they are.
+func escapeDOTLabel(label string) string {
+	replacer := strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\r\n", "\\n", "\n", "\\n", "\r", "\\n")
+	return "\"" + replacer.Replace(label) + "\""
+}
+
+// escapeMermaidLabel quotes a label for a Mermaid flowchart. Characters that
+// Mermaid interprets as markup, including the # that starts entity codes, are
+// replaced with entity codes, and line breaks are replaced with spaces.
+func escapeMermaidLabel(label string) string {
+	replacer := strings.NewReplacer("#", "#35;", "\"", "#quot;", "<", "#lt;", ">", "#gt;", "&", "#amp;", "\r\n", " ", "\n", " ", "\r", " ")
+	return "\"" + replacer.Replace(label) + "\""
+}
+
+func (this *LogicalPermissions) getExportColor(state string) string {
+	if state == exportStateTrue {
+		return "#c8e6c9"
+	}
+	if state == exportStateFalse {
+		return "#ffcdd2"
+	}
+	if state == exportStateError {
+		return "#ffe0b2"
+	}
+	if state == exportStateUnknown {
+		return "#e0e0e0"
+	}
+	return ""
+}
+
+// getExportNodes converts a permission tree into the nodes to be exported,
+// overlaid with the results from the trace if it is not nil.
+func (this *LogicalPermissions) getExportNodes(permissions interface{}, trace *Trace) ([]*exportNode, error) {
+	tree, err := this.parsePermissionTree(permissions)
+	if err != nil {
+		return nil, err
+	}
+	if err := tree.err(); err != nil {
+		return nil, err
+	}
+
+	trace_nodes := make(map[string]*TraceNode)
+	if trace != nil {
+		var index func(node *TraceNode)
+		index = func(node *TraceNode) {
+			trace_nodes[node.Kind+"|"+node.Path] = node
+			for _, child := range node.Children {
+				index(child)
+			}
+		}
+		if trace.NoBypass != nil {
+			index(trace.NoBypass)
+		}
+		if trace.Root != nil {
+			index(trace.Root)
+		}
+	}
+
+	count := 0
+	newNode := func(kind string, label string) *exportNode {
+		node := &exportNode{id: fmt.Sprintf("n%d", count), kind: kind, label: label}
+		count++
+		return node
+	}
+	var convert func(node *permissionNode) *exportNode
+	convert = func(node *permissionNode) *exportNode {
+		label := node.name
+		if node.kind == TraceNodeValue {
+			label = fmt.Sprintf("%s: %s", node.permtype, node.value)
+		} else if node.kind == TraceNodeNoBypass && node.no_bypass != nil {
+			label = fmt.Sprintf("NO_BYPASS: %v", node.no_bypass)
+		}
+		export_node := newNode(node.kind, label)
+		if trace != nil {
+			export_node.state = exportStateSkipped
+			if _, ok := trace_nodes[TraceNodeBypass+"|"+node.path]; ok {
+				export_node.state = exportStateTrue
+			}
+			if trace_node, ok := trace_nodes[node.kind+"|"+node.path]; ok {
+				export_node.state = exportStateFalse
+				if trace_node.Error != "" {
+					export_node.state = exportStateError
+				} else if trace_node.Unknown {
+					export_node.state = exportStateUnknown
+				} else if trace_node.Result {
+					export_node.state = exportStateTrue
+				}
+			}
+		}
+		if node.no_bypass_node != nil {
+			condition := newNode("note", "if")
+			condition.children = []*exportNode{convert(node.no_bypass_node)}
+			export_node.children = append(export_node.children, condition)
+		}
+		for _, child := range node.children {
+			export_node.children = append(export_node.children, convert(child))
+		}
+		return export_node
+	}
+
+	nodes := []*exportNode{}
+	if tree.no_bypass != nil {
+		nodes = append(nodes, newNode("note", fmt.Sprintf("NO_BYPASS: %v", tree.no_bypass)))
+	}
+	if tree.no_bypass_node != nil {
+		no_bypass := newNode("note", "NO_BYPASS")
+		no_bypass.children = []*exportNode{convert(tree.no_bypass_node)}
+		nodes = append(nodes, no_bypass)
+	}
+	if trace != nil && trace.BypassChecked {
+		bypass := newNode("note", "bypass denied")
+		bypass.state = exportStateFalse
+		if trace.BypassAccess {
+			bypass.label = "bypass granted"
+			if trace.Bypass != "" && trace.Bypass != DefaultBypass {
+				bypass.label = fmt.Sprintf("bypass granted by %s", trace.Bypass)
+			}
+			bypass.state = exportStateTrue
+		}
+		nodes = append(nodes, bypass)
+	}
+	nodes = append(nodes, convert(tree.root))
+	return nodes, nil
+}
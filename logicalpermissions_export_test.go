@@ -0,0 +1,110 @@
+package logicalpermissions_test
+
+import (
+	"testing"
+
+	. "github.com/ordermind/logical-permissions-go"
+	"github.com/stretchr/testify/assert"
+)
+
+/*-------------LogicalPermissions::ExportDOT()--------------*/
+
+func TestExportDOT(t *testing.T) {
+	t.Parallel()
+	lp := newRoleLogicalPermissions(t, nil)
+	permissions := `{"NO_BYPASS": true, "OR": [{"role": "admin"}, {"role": {"NOT": "guest"}}]}`
+	dot, err := lp.ExportDOT(permissions, nil)
+	assert.Nil(t, err)
+	expected := `digraph permissions {
+  node [fontname="Helvetica"];
+  n0 [label="NO_BYPASS: true", shape=note];
+  n1 [label="OR", shape=ellipse];
+  n2 [label="OR", shape=ellipse];
+  n3 [label="role: admin", shape=box, style="rounded"];
+  n2 -> n3;
+  n4 [label="role", shape=box];
+  n5 [label="NOT", shape=ellipse];
+  n6 [label="role: guest", shape=box, style="rounded"];
+  n5 -> n6;
+  n4 -> n5;
+  n2 -> n4;
+  n1 -> n2;
+}
+`
+	assert.Equal(t, expected, dot)
+}
+
+func TestExportDOTTrace(t *testing.T) {
+	t.Parallel()
+	lp := newRoleLogicalPermissions(t, nil)
+	permissions := `{"OR": [{"role": "admin"}, {"role": {"NOT": "guest"}}]}`
+	_, trace, err := lp.CheckAccessNoBypassWithTrace(permissions, map[string]interface{}{"role": "admin"})
+	assert.Nil(t, err)
+	dot, err := lp.ExportDOT(permissions, trace)
+	assert.Nil(t, err)
+	assert.Contains(t, dot, `n2 [label="role: admin", shape=box, fillcolor="#c8e6c9", style="rounded,filled"];`)
+	assert.Contains(t, dot, `n4 [label="NOT", shape=ellipse, fontcolor="gray50", color="gray50", style="dashed"];`)
+}
+
+func TestExportDOTInvalidPermissions(t *testing.T) {
+	t.Parallel()
+	lp := newRoleLogicalPermissions(t, nil)
+	_, err := lp.ExportDOT(`{"flag": "beta"}`, nil)
+	if assert.Error(t, err) {
+		assert.IsType(t, &PermissionTypeNotRegisteredError{}, err)
+	}
+}
+
+func TestExportDOTEscaping(t *testing.T) {
+	t.Parallel()
+	lp := newRoleLogicalPermissions(t, nil)
+	dot, err := lp.ExportDOT(`{"role": ["a<b", "caf\u00e9", "say \"hi\" \\ bye"]}`, nil)
+	assert.Nil(t, err)
+	assert.Contains(t, dot, `[label="role: a<b", `)
+	assert.Contains(t, dot, `[label="role: café", `)
+	assert.Contains(t, dot, `[label="role: say \"hi\" \\ bye", `)
+}
+
+/*-------------LogicalPermissions::ExportMermaid()--------------*/
+
+func TestExportMermaid(t *testing.T) {
+	t.Parallel()
+	lp := newRoleLogicalPermissions(t, nil)
+	permissions := `{"NO_BYPASS": {"role": "guest"}, "role": ["admin", "\"x\""]}`
+	_, trace, err := lp.CheckAccessWithTrace(permissions, map[string]interface{}{"role": "admin"})
+	assert.Nil(t, err)
+	mermaid, err := lp.ExportMermaid(permissions, trace)
+	assert.Nil(t, err)
+	expected := `flowchart TD
+  n0>"NO_BYPASS"]
+  n1{{"OR"}}
+  n2("role: guest")
+  n1 --> n2
+  n0 --> n1
+  n3>"bypass denied"]
+  n4{{"OR"}}
+  n5["role"]
+  n6("role: admin")
+  n5 --> n6
+  n7("role: #quot;x#quot;")
+  n5 --> n7
+  n4 --> n5
+  classDef true fill:#c8e6c9
+  class n4,n5,n6 true
+  classDef false fill:#ffcdd2
+  class n1,n2,n3 false
+  classDef skipped stroke-dasharray:5 5,color:#7f7f7f
+  class n7 skipped
+`
+	assert.Equal(t, expected, mermaid)
+}
+
+func TestExportMermaidEscaping(t *testing.T) {
+	t.Parallel()
+	lp := newRoleLogicalPermissions(t, nil)
+	mermaid, err := lp.ExportMermaid(`{"role": ["a<b>", "x & #y", "caf\u00e9"]}`, nil)
+	assert.Nil(t, err)
+	assert.Contains(t, mermaid, `("role: a#lt;b#gt;")`)
+	assert.Contains(t, mermaid, `("role: x #amp; #35;y")`)
+	assert.Contains(t, mermaid, `("role: café")`)
+}
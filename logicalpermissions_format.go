@@ -0,0 +1,157 @@
+package logicalpermissions
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func (this *LogicalPermissions) Format(permissions interface{}) (string, error) {
+	var value interface{}
+	if str_permissions, ok := permissions.(string); ok {
+		trimmed_permissions := strings.TrimSpace(str_permissions)
+		if strings.ToUpper(trimmed_permissions) == "TRUE" || strings.ToUpper(trimmed_permissions) == "FALSE" {
+			value = trimmed_permissions
+		} else {
+			decoder := json.NewDecoder(strings.NewReader(trimmed_permissions))
+			decoder.UseNumber()
+			if err := decoder.Decode(&value); err != nil {
+				return "", &InvalidArgumentValueError{CustomError{fmt.Sprintf("Error parsing json permissions: %s. Evaluated permissions: %s", err.Error(), trimmed_permissions)}}
+			}
+			var extra interface{}
+			if err := decoder.Decode(&extra); err != io.EOF {
+				return "", &InvalidArgumentValueError{CustomError{fmt.Sprintf("Error parsing json permissions: unexpected data after the permission tree. Evaluated permissions: %s", trimmed_permissions)}}
+			}
+		}
+	} else {
+		tmp, err := json.Marshal(permissions)
+		if err != nil {
+			return "", &InvalidArgumentValueError{CustomError{fmt.Sprintf("Could not convert permissions to json object: %s. Evaluated permissions: %v", err.Error(), permissions)}}
+		}
+		decoder := json.NewDecoder(bytes.NewReader(tmp))
+		decoder.UseNumber()
+		decoder.Decode(&value)
+	}
+
+	var buffer bytes.Buffer
+	if err := this.writeFormatted(&buffer, value, ""); err != nil {
+		return "", err
+	}
+	buffer.WriteString("\n")
+	return buffer.String(), nil
+}
+
+func (this *LogicalPermissions) writeFormatted(buffer *bytes.Buffer, value interface{}, indent string) error {
+	if map_value, ok := value.(map[string]interface{}); ok {
+		if len(map_value) == 0 {
+			buffer.WriteString("{}")
+			return nil
+		}
+		canonical := make(map[string]interface{}, len(map_value))
+		for key, child := range map_value {
+			canonical_key := key
+			if this.stringInSlice(strings.ToUpper(key), this.getCorePermissionKeys()) {
+				canonical_key = strings.ToUpper(key)
+			}
+			if _, ok := canonical[canonical_key]; ok {
+				return &InvalidArgumentValueError{CustomError{fmt.Sprintf("The key \"%s\" occurs more than once with different cases. Evaluated permissions: %v", canonical_key, map_value)}}
+			}
+			canonical[canonical_key] = child
+		}
+		keys := make([]string, 0, len(canonical))
+		for key := range canonical {
+			keys = append(keys, key)
+		}
+		sort.Sort(formattedKeys(keys))
+
+		buffer.WriteString("{\n")
+		for i, key := range keys {
+			buffer.WriteString(indent + "  ")
+			this.writeFormattedScalar(buffer, key)
+			buffer.WriteString(": ")
+			if err := this.writeFormatted(buffer, canonical[key], indent+"  "); err != nil {
+				return err
+			}
+			if i < len(keys)-1 {
+				buffer.WriteString(",")
+			}
+			buffer.WriteString("\n")
+		}
+		buffer.WriteString(indent + "}")
+		return nil
+	}
+	if slice_value, ok := value.([]interface{}); ok {
+		if len(slice_value) == 0 {
+			buffer.WriteString("[]")
+			return nil
+		}
+		buffer.WriteString("[\n")
+		for i, child := range slice_value {
+			buffer.WriteString(indent + "  ")
+			if err := this.writeFormatted(buffer, child, indent+"  "); err != nil {
+				return err
+			}
+			if i < len(slice_value)-1 {
+				buffer.WriteString(",")
+			}
+			buffer.WriteString("\n")
+		}
+		buffer.WriteString(indent + "]")
+		return nil
+	}
+	this.writeFormattedScalar(buffer, value)
+	return nil
+}
+
+func (this *LogicalPermissions) writeFormattedScalar(buffer *bytes.Buffer, value interface{}) {
+	encoded, _ := json.Marshal(value)
+	// json.Marshal() escapes <, > and &, which are restored since the output
+	// is not embedded in HTML.
+	for i := 0; i < len(encoded); i++ {
+		if encoded[i] != '\\' || i+1 == len(encoded) {
+			buffer.WriteByte(encoded[i])
+			continue
+		}
+		if i+5 < len(encoded) {
+			switch string(encoded[i+1 : i+6]) {
+			case "u003c":
+				buffer.WriteByte('<')
+				i += 5
+				continue
+			case "u003e":
+				buffer.WriteByte('>')
+				i += 5
+				continue
+			case "u0026":
+				buffer.WriteByte('&')
+				i += 5
+				continue
+			}
+		}
+		buffer.Write(encoded[i : i+2])
+		i++
+	}
+}
+
+// formattedKeys sorts NO_BYPASS first, numeric keys numerically and other keys alphabetically.
+type formattedKeys []string
+
+func (this formattedKeys) Len() int      { return len(this) }
+func (this formattedKeys) Swap(i, j int) { this[i], this[j] = this[j], this[i] }
+
+func (this formattedKeys) Less(i, j int) bool {
+	a, b := this[i], this[j]
+	if a == "NO_BYPASS" || b == "NO_BYPASS" {
+		return a == "NO_BYPASS" && b != "NO_BYPASS"
+	}
+	int_a, err_a := strconv.Atoi(a)
+	int_b, err_b := strconv.Atoi(b)
+	if err_a == nil && err_b == nil && int_a != int_b {
+		return int_a < int_b
+	}
+	return a < b
+}
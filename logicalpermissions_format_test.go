@@ -0,0 +1,75 @@
+package logicalpermissions_test
+
+import (
+	"testing"
+
+	. "github.com/ordermind/logical-permissions-go"
+	"github.com/stretchr/testify/assert"
+)
+
+/*-------------LogicalPermissions::Format()--------------*/
+
+func TestFormat(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	formatted, err := lp.Format(`{"role": {"or": ["editor", "writer<1>"]}, "no_bypass": {"flag": "x"}, "10": false, "2": {"Not": "TRUE"}}`)
+	assert.Nil(t, err)
+	expected := `{
+  "NO_BYPASS": {
+    "flag": "x"
+  },
+  "2": {
+    "NOT": "TRUE"
+  },
+  "10": false,
+  "role": {
+    "OR": [
+      "editor",
+      "writer<1>"
+    ]
+  }
+}
+`
+	assert.Equal(t, expected, formatted)
+
+	formatted_again, err := lp.Format(formatted)
+	assert.Nil(t, err)
+	assert.Equal(t, formatted, formatted_again)
+}
+
+func TestFormatNonJSONValues(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	formatted, err := lp.Format(" TRUE ")
+	assert.Nil(t, err)
+	assert.Equal(t, "\"TRUE\"\n", formatted)
+
+	formatted, err = lp.Format(false)
+	assert.Nil(t, err)
+	assert.Equal(t, "false\n", formatted)
+
+	formatted, err = lp.Format([]interface{}{map[string]interface{}{}, []interface{}{}})
+	assert.Nil(t, err)
+	assert.Equal(t, "[\n  {},\n  []\n]\n", formatted)
+
+	formatted, err = lp.Format(`"a&b \\u003c \u003e"`)
+	assert.Nil(t, err)
+	assert.Equal(t, "\"a&b \\\\u003c >\"\n", formatted)
+}
+
+func TestFormatErrors(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	_, err := lp.Format(`{"role": "admin"`)
+	if assert.Error(t, err) {
+		assert.IsType(t, &InvalidArgumentValueError{}, err)
+	}
+	_, err = lp.Format(`{"role": "admin"} {}`)
+	if assert.Error(t, err) {
+		assert.IsType(t, &InvalidArgumentValueError{}, err)
+	}
+	_, err = lp.Format(`{"and": ["TRUE"], "AND": ["FALSE"]}`)
+	if assert.Error(t, err) {
+		assert.IsType(t, &InvalidArgumentValueError{}, err)
+	}
+}
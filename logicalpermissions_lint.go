@@ -0,0 +1,150 @@
+package logicalpermissions
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Severities of lint diagnostics.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityInfo    = "info"
+)
+
+// Rules reported by LogicalPermissions::Lint().
+const (
+	LintRuleInvalidPermissions   = "invalid-permissions"
+	LintRuleXORConstantChild     = "xor-constant-child"
+	LintRuleDoubleNegation       = "double-negation"
+	LintRuleDuplicateORChild     = "duplicate-or-child"
+	LintRuleUnreachableAfterTrue = "unreachable-after-true"
+	LintRuleNoBypassFalse        = "no-bypass-false"
+	LintRuleUnregisteredBypass   = "unregistered-bypass"
+	LintRuleTypeCaseConflict     = "type-case-conflict"
+)
+
+// Diagnostic is a problem found in a permission tree. Path is a JSON pointer to
+// the offending value.
+type Diagnostic struct {
+	Path     string `json:"path"`
+	Severity string `json:"severity"`
+	Rule     string `json:"rule"`
+	Message  string `json:"message"`
+}
+
+func (this *LogicalPermissions) Lint(permissions interface{}) []Diagnostic {
+	diagnostics := []Diagnostic{}
+	tree, err := this.parsePermissionTree(permissions)
+	if err != nil {
+		return append(diagnostics, Diagnostic{Path: "", Severity: SeverityError, Rule: LintRuleInvalidPermissions, Message: err.Error()})
+	}
+	for _, tree_error := range tree.errors {
+		rule := LintRuleInvalidPermissions
+		if _, ok := tree_error.err.(*BypassNotRegisteredError); ok {
+			rule = LintRuleUnregisteredBypass
+		}
+		diagnostics = append(diagnostics, Diagnostic{Path: tree_error.path, Severity: SeverityError, Rule: rule, Message: tree_error.err.Error()})
+	}
+
+	if message, ok := this.getNoBypassNoOp(tree.no_bypass); ok {
+		diagnostics = append(diagnostics, Diagnostic{Path: "/NO_BYPASS", Severity: SeverityWarning, Rule: LintRuleNoBypassFalse, Message: message})
+	}
+
+	type_names := this.getSortedTypeNames()
+	for i, name := range type_names {
+		for _, other := range type_names[i+1:] {
+			if strings.EqualFold(name, other) {
+				diagnostics = append(diagnostics, Diagnostic{Path: "", Severity: SeverityWarning, Rule: LintRuleTypeCaseConflict, Message: fmt.Sprintf("The registered permission types \"%s\" and \"%s\" differ only by case.", name, other)})
+			}
+		}
+	}
+	for _, tree_error := range tree.errors {
+		if _, ok := tree_error.err.(*PermissionTypeNotRegisteredError); !ok {
+			continue
+		}
+		segments := strings.Split(tree_error.path, "/")
+		key := strings.NewReplacer("~1", "/", "~0", "~").Replace(segments[len(segments)-1])
+		for _, name := range type_names {
+			if strings.EqualFold(key, name) {
+				diagnostics = append(diagnostics, Diagnostic{Path: tree_error.path, Severity: SeverityWarning, Rule: LintRuleTypeCaseConflict, Message: fmt.Sprintf("The permission type \"%s\" differs only by case from the registered permission type \"%s\".", key, name)})
+			}
+		}
+	}
+
+	tree.walk(func(node *permissionNode, parent *permissionNode) {
+		if node.kind == TraceNodeNoBypass {
+			if message, ok := this.getNoBypassNoOp(node.no_bypass); ok {
+				diagnostics = append(diagnostics, Diagnostic{Path: node.path + "/NO_BYPASS", Severity: SeverityWarning, Rule: LintRuleNoBypassFalse, Message: message})
+			}
+		}
+		if node.kind != TraceNodeGate && node.kind != TraceNodeType {
+			return
+		}
+		if node.name == "XOR" {
+			for _, child := range node.children {
+				if child.kind == TraceNodeBoolean {
+					diagnostics = append(diagnostics, Diagnostic{Path: child.path, Severity: SeverityWarning, Rule: LintRuleXORConstantChild, Message: fmt.Sprintf("The XOR gate has the constant child %s. Consider replacing the XOR gate with a simpler expression.", child.name)})
+				}
+			}
+		}
+		if node.name == "NOT" && len(node.children) == 1 && node.children[0].kind == TraceNodeGate && node.children[0].name == "NOT" {
+			diagnostics = append(diagnostics, Diagnostic{Path: node.path, Severity: SeverityWarning, Rule: LintRuleDoubleNegation, Message: "A NOT gate directly containing another NOT gate cancels out. Use the inner permissions directly instead."})
+		}
+		if node.name == "OR" || node.kind == TraceNodeType {
+			signatures := make(map[string]bool)
+			for _, child := range node.children {
+				signature := child.signature()
+				if signatures[signature] {
+					diagnostics = append(diagnostics, Diagnostic{Path: child.path, Severity: SeverityWarning, Rule: LintRuleDuplicateORChild, Message: "This permission is a duplicate of a preceding sibling in an OR gate."})
+				}
+				signatures[signature] = true
+			}
+			for i, child := range node.children {
+				if !child.isBoolean(true) {
+					continue
+				}
+				for j, sibling := range node.children {
+					if j == i || (node.ordered && j < i) {
+						continue
+					}
+					message := "This permission is redundant because its OR gate contains TRUE."
+					if node.ordered {
+						message = "This permission is unreachable because it follows TRUE in an OR gate."
+					}
+					diagnostics = append(diagnostics, Diagnostic{Path: sibling.path, Severity: SeverityWarning, Rule: LintRuleUnreachableAfterTrue, Message: message})
+				}
+				break
+			}
+		}
+	})
+
+	sort.Stable(diagnosticsByPath(diagnostics))
+	return diagnostics
+}
+
+// diagnosticsByPath sorts diagnostics by their path.
+type diagnosticsByPath []Diagnostic
+
+func (this diagnosticsByPath) Len() int           { return len(this) }
+func (this diagnosticsByPath) Swap(i, j int)      { this[i], this[j] = this[j], this[i] }
+func (this diagnosticsByPath) Less(i, j int) bool { return this[i].Path < this[j].Path }
+
+// getNoBypassNoOp returns a lint message if a NO_BYPASS value has no effect,
+// which is the case if it is FALSE or an empty slice of bypass names.
+func (this *LogicalPermissions) getNoBypassNoOp(no_bypass interface{}) (string, bool) {
+	if no_bypass == nil {
+		return "", false
+	}
+	if slice_value, ok := this.getNoBypassSlice(no_bypass); ok {
+		if len(slice_value) == 0 {
+			return "NO_BYPASS is an empty slice, which has no effect. Remove it instead.", true
+		}
+		return "", false
+	}
+	if allow_bypass, _ := this.checkAllowBypass(no_bypass, nil, nil); allow_bypass {
+		return "NO_BYPASS is set to FALSE, which has no effect. Remove it instead.", true
+	}
+	return "", false
+}
@@ -0,0 +1,149 @@
+package logicalpermissions_test
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/ordermind/logical-permissions-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func getLintRules(diagnostics []Diagnostic) []string {
+	rules := []string{}
+	for _, diagnostic := range diagnostics {
+		rules = append(rules, fmt.Sprintf("%s %s %s", diagnostic.Path, diagnostic.Severity, diagnostic.Rule))
+	}
+	return rules
+}
+
+/*-------------LogicalPermissions::Lint()--------------*/
+
+func TestLintClean(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	type_callback := func(string, map[string]interface{}) (bool, error) { return true, nil }
+	lp.AddType("role", type_callback)
+	lp.AddType("flag", type_callback)
+	diagnostics := lp.Lint(`{"NO_BYPASS": {"role": "admin"}, "AND": {"role": ["editor", "writer"], "flag": {"NOT": "locked"}}}`)
+	assert.Equal(t, []Diagnostic{}, diagnostics)
+}
+
+func TestLintInvalidPermissions(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	type_callback := func(string, map[string]interface{}) (bool, error) { return true, nil }
+	lp.AddType("role", type_callback)
+	lp.AddType("flag", type_callback)
+	assert.Equal(t, []string{" error invalid-permissions"}, getLintRules(lp.Lint(50)))
+
+	diagnostics := lp.Lint(`{"AND": [{"XOR": ["TRUE"]}, {"role": {"flag": "x"}}, {"OR": {"NO_BYPASS": true}}]}`)
+	assert.Equal(t, []string{
+		"/AND/0/XOR error invalid-permissions",
+		"/AND/1/role/flag error invalid-permissions",
+		"/AND/2/OR/NO_BYPASS error invalid-permissions",
+	}, getLintRules(diagnostics))
+}
+
+func TestLintXORConstantChild(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	type_callback := func(string, map[string]interface{}) (bool, error) { return true, nil }
+	lp.AddType("role", type_callback)
+	lp.AddType("flag", type_callback)
+	diagnostics := lp.Lint(`{"XOR": [true, {"role": "admin"}, "FALSE"]}`)
+	assert.Equal(t, []string{"/XOR/0 warning xor-constant-child", "/XOR/2 warning xor-constant-child"}, getLintRules(diagnostics))
+}
+
+func TestLintDoubleNegation(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	type_callback := func(string, map[string]interface{}) (bool, error) { return true, nil }
+	lp.AddType("role", type_callback)
+	lp.AddType("flag", type_callback)
+	diagnostics := lp.Lint(`{"role": {"NOT": {"not": "admin"}}}`)
+	assert.Equal(t, []string{"/role/NOT warning double-negation"}, getLintRules(diagnostics))
+}
+
+func TestLintDuplicateORChild(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	type_callback := func(string, map[string]interface{}) (bool, error) { return true, nil }
+	lp.AddType("role", type_callback)
+	lp.AddType("flag", type_callback)
+	diagnostics := lp.Lint(`{"OR": [{"role": "admin"}, {"flag": "beta"}, {"role": "admin"}], "role": ["editor", "editor"]}`)
+	assert.Equal(t, []string{"/OR/2/role warning duplicate-or-child", "/role/1 warning duplicate-or-child"}, getLintRules(diagnostics))
+
+	diagnostics = lp.Lint(`{"AND": [{"role": "admin"}, {"role": "admin"}]}`)
+	assert.Equal(t, []Diagnostic{}, diagnostics)
+}
+
+func TestLintUnreachableAfterTrue(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	type_callback := func(string, map[string]interface{}) (bool, error) { return true, nil }
+	lp.AddType("role", type_callback)
+	lp.AddType("flag", type_callback)
+	diagnostics := lp.Lint(`{"OR": [{"role": "admin"}, true, {"flag": "beta"}]}`)
+	assert.Equal(t, []string{"/OR/2/flag warning unreachable-after-true"}, getLintRules(diagnostics))
+
+	diagnostics = lp.Lint(`{"OR": {"role": "admin", "0": "TRUE"}}`)
+	assert.Equal(t, []string{"/OR/role warning unreachable-after-true"}, getLintRules(diagnostics))
+}
+
+func TestLintNoBypassFalse(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	type_callback := func(string, map[string]interface{}) (bool, error) { return true, nil }
+	lp.AddType("role", type_callback)
+	lp.AddType("flag", type_callback)
+	diagnostics := lp.Lint(`{"no_bypass": "false", "role": "admin"}`)
+	assert.Equal(t, []string{"/NO_BYPASS warning no-bypass-false"}, getLintRules(diagnostics))
+
+	diagnostics = lp.Lint(`{"NO_BYPASS": true, "role": "admin"}`)
+	assert.Equal(t, []Diagnostic{}, diagnostics)
+
+	err := lp.AddBypass("support", func(map[string]interface{}) (bool, error) { return true, nil })
+	if err != nil {
+		t.Error(fmt.Sprintf("LogicalPermissions::AddBypass() returned an error: %s", err))
+	}
+	diagnostics = lp.Lint(`{"NO_BYPASS": [], "role": "admin", "OR": {"NO_BYPASS": [], "flag": "never"}}`)
+	assert.Equal(t, []string{"/NO_BYPASS warning no-bypass-false", "/OR/NO_BYPASS warning no-bypass-false"}, getLintRules(diagnostics))
+	assert.Equal(t, "NO_BYPASS is an empty slice, which has no effect. Remove it instead.", diagnostics[0].Message)
+
+	diagnostics = lp.Lint(`{"NO_BYPASS": ["support"], "role": "admin"}`)
+	assert.Equal(t, []Diagnostic{}, diagnostics)
+}
+
+func TestLintUnregisteredBypass(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	type_callback := func(string, map[string]interface{}) (bool, error) { return true, nil }
+	lp.AddType("role", type_callback)
+	lp.AddType("flag", type_callback)
+	err := lp.AddBypass("support", func(map[string]interface{}) (bool, error) { return true, nil })
+	if err != nil {
+		t.Error(fmt.Sprintf("LogicalPermissions::AddBypass() returned an error: %s", err))
+	}
+	diagnostics := lp.Lint(`{"NO_BYPASS": ["!suport"], "role": "admin"}`)
+	assert.Equal(t, []string{"/NO_BYPASS error unregistered-bypass"}, getLintRules(diagnostics))
+
+	diagnostics = lp.Lint(`{"NO_BYPASS": [true], "role": "admin"}`)
+	assert.Equal(t, []string{"/NO_BYPASS error invalid-permissions"}, getLintRules(diagnostics))
+}
+
+func TestLintTypeCaseConflict(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	type_callback := func(string, map[string]interface{}) (bool, error) { return true, nil }
+	lp.AddType("role", type_callback)
+	lp.AddType("flag", type_callback)
+	diagnostics := lp.Lint(`{"Role": "admin"}`)
+	assert.Equal(t, []string{"/Role error invalid-permissions", "/Role warning type-case-conflict"}, getLintRules(diagnostics))
+
+	err := lp.AddType("FLAG", func(string, map[string]interface{}) (bool, error) { return true, nil })
+	if err != nil {
+		t.Error(fmt.Sprintf("LogicalPermissions::AddType() returned an error: %s", err))
+	}
+	diagnostics = lp.Lint(`{"role": "admin"}`)
+	assert.Equal(t, []string{" warning type-case-conflict"}, getLintRules(diagnostics))
+}
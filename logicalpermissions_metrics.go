@@ -0,0 +1,317 @@
+package logicalpermissions
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Outcomes of measured access checks, callbacks and gates.
+const (
+	MetricsOutcomeGranted = "granted"
+	MetricsOutcomeDenied  = "denied"
+	MetricsOutcomeError   = "error"
+	MetricsOutcomeUnknown = "unknown"
+)
+
+// Error categories of measured access checks.
+const (
+	MetricsErrorInvalidPermissions = "invalid_permissions"
+	MetricsErrorTypeNotRegistered  = "type_not_registered"
+	MetricsErrorLimitExceeded      = "limit_exceeded"
+	MetricsErrorCallback           = "callback"
+	MetricsErrorBypassCallback     = "bypass_callback"
+	MetricsErrorOther              = "other"
+)
+
+// DefaultMetricsBuckets are the upper bounds in seconds of the latency
+// histogram buckets used by NewMetrics() if no buckets are given.
+var DefaultMetricsBuckets = []float64{0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+type metricsLabels struct {
+	name    string
+	outcome string
+}
+
+// sortedMetricsLabels sorts labels by name and outcome.
+type sortedMetricsLabels []metricsLabels
+
+func (this sortedMetricsLabels) Len() int      { return len(this) }
+func (this sortedMetricsLabels) Swap(i, j int) { this[i], this[j] = this[j], this[i] }
+
+func (this sortedMetricsLabels) Less(i, j int) bool {
+	if this[i].name != this[j].name {
+		return this[i].name < this[j].name
+	}
+	return this[i].outcome < this[j].outcome
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Metrics collects counters and latency histograms of access checks, permission
+// type callbacks and logic gates. Register it with LogicalPermissions::SetMetrics().
+// Metrics implements expvar.Var, so it can be published with expvar.Publish(),
+// and http.Handler, which serves the Prometheus text exposition format.
+// Evaluations of candidate permission trees in shadow mode are not measured.
+type Metrics struct {
+	mutex         sync.Mutex
+	buckets       []float64
+	checks        map[string]*histogram
+	callbacks     map[metricsLabels]*histogram
+	gates         map[metricsLabels]*histogram
+	errors        map[string]uint64
+	bypass_grants uint64
+}
+
+// NewMetrics creates a metrics collector with the given histogram buckets in
+// seconds, or with DefaultMetricsBuckets if buckets is empty.
+func NewMetrics(buckets []float64) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DefaultMetricsBuckets
+	}
+	sorted_buckets := make([]float64, len(buckets))
+	copy(sorted_buckets, buckets)
+	sort.Float64s(sorted_buckets)
+	return &Metrics{
+		buckets:   sorted_buckets,
+		checks:    make(map[string]*histogram),
+		callbacks: make(map[metricsLabels]*histogram),
+		gates:     make(map[metricsLabels]*histogram),
+		errors:    make(map[string]uint64),
+	}
+}
+
+func (this *LogicalPermissions) GetMetrics() *Metrics {
+	return this.metrics
+}
+
+func (this *LogicalPermissions) SetMetrics(metrics *Metrics) {
+	this.metrics = metrics
+}
+
+func getMetricsOutcome(access bool, err error) string {
+	if err == ErrUnknown || isUnknownResult(err) {
+		return MetricsOutcomeUnknown
+	}
+	if err != nil {
+		return MetricsOutcomeError
+	}
+	if access {
+		return MetricsOutcomeGranted
+	}
+	return MetricsOutcomeDenied
+}
+
+func getMetricsErrorCategory(err error, eval *evaluation) string {
+	if eval != nil && eval.error_category != "" {
+		return eval.error_category
+	}
+	switch err.(type) {
+	case *InvalidArgumentValueError, *InvalidValueForLogicGateError:
+		return MetricsErrorInvalidPermissions
+	case *PermissionTypeNotRegisteredError:
+		return MetricsErrorTypeNotRegistered
+	case *LimitExceededError:
+		return MetricsErrorLimitExceeded
+	}
+	return MetricsErrorOther
+}
+
+func (this *Metrics) observeCheck(access bool, err error, eval *evaluation, duration time.Duration) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	outcome := getMetricsOutcome(access, err)
+	if err == nil && eval.unknown {
+		outcome = MetricsOutcomeUnknown
+	}
+	this.observe(this.getHistogram(this.checks, outcome), duration)
+	if err != nil {
+		this.errors[getMetricsErrorCategory(err, eval)]++
+	} else if eval.bypass_granted {
+		this.bypass_grants++
+	}
+}
+
+func (this *Metrics) observeCallback(permtype string, access bool, err error, duration time.Duration) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.observe(this.getLabeledHistogram(this.callbacks, metricsLabels{permtype, getMetricsOutcome(access, err)}), duration)
+}
+
+func (this *Metrics) observeGate(gate string, access bool, err error, duration time.Duration) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.observe(this.getLabeledHistogram(this.gates, metricsLabels{gate, getMetricsOutcome(access, err)}), duration)
+}
+
+func (this *Metrics) getHistogram(histograms map[string]*histogram, key string) *histogram {
+	if histograms[key] == nil {
+		histograms[key] = &histogram{counts: make([]uint64, len(this.buckets))}
+	}
+	return histograms[key]
+}
+
+func (this *Metrics) getLabeledHistogram(histograms map[metricsLabels]*histogram, labels metricsLabels) *histogram {
+	if histograms[labels] == nil {
+		histograms[labels] = &histogram{counts: make([]uint64, len(this.buckets))}
+	}
+	return histograms[labels]
+}
+
+func (this *Metrics) observe(h *histogram, duration time.Duration) {
+	seconds := duration.Seconds()
+	for i, bucket := range this.buckets {
+		if seconds <= bucket {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// Reset clears all collected metrics.
+func (this *Metrics) Reset() {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.checks = make(map[string]*histogram)
+	this.callbacks = make(map[metricsLabels]*histogram)
+	this.gates = make(map[metricsLabels]*histogram)
+	this.errors = make(map[string]uint64)
+	this.bypass_grants = 0
+}
+
+type histogramSnapshot struct {
+	Count   uint64            `json:"count"`
+	Sum     float64           `json:"sum_seconds"`
+	Buckets map[string]uint64 `json:"buckets"`
+}
+
+// String returns the metrics as a JSON object, which implements expvar.Var.
+func (this *Metrics) String() string {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	snapshot := func(h *histogram) *histogramSnapshot {
+		buckets := make(map[string]uint64, len(this.buckets))
+		for i, bucket := range this.buckets {
+			buckets[strconv.FormatFloat(bucket, 'g', -1, 64)] = h.counts[i]
+		}
+		return &histogramSnapshot{Count: h.count, Sum: h.sum, Buckets: buckets}
+	}
+	labeled := func(histograms map[metricsLabels]*histogram) map[string]map[string]*histogramSnapshot {
+		snapshots := make(map[string]map[string]*histogramSnapshot)
+		for labels, h := range histograms {
+			if snapshots[labels.name] == nil {
+				snapshots[labels.name] = make(map[string]*histogramSnapshot)
+			}
+			snapshots[labels.name][labels.outcome] = snapshot(h)
+		}
+		return snapshots
+	}
+	checks := make(map[string]*histogramSnapshot)
+	for outcome, h := range this.checks {
+		checks[outcome] = snapshot(h)
+	}
+	output, _ := json.Marshal(map[string]interface{}{
+		"checks":        checks,
+		"callbacks":     labeled(this.callbacks),
+		"gates":         labeled(this.gates),
+		"errors":        this.errors,
+		"bypass_grants": this.bypass_grants,
+	})
+	return string(output)
+}
+
+// WritePrometheus writes the metrics in the Prometheus text exposition format.
+func (this *Metrics) WritePrometheus(writer io.Writer) error {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	var buffer bytes.Buffer
+
+	check_outcomes := []string{}
+	for outcome := range this.checks {
+		check_outcomes = append(check_outcomes, outcome)
+	}
+	sort.Strings(check_outcomes)
+	buffer.WriteString("# HELP logicalpermissions_checks_total Number of access checks by outcome.\n")
+	buffer.WriteString("# TYPE logicalpermissions_checks_total counter\n")
+	for _, outcome := range check_outcomes {
+		fmt.Fprintf(&buffer, "logicalpermissions_checks_total{outcome=%s} %d\n", quotePrometheusLabel(outcome), this.checks[outcome].count)
+	}
+	buffer.WriteString("# HELP logicalpermissions_check_duration_seconds Duration of access checks by outcome.\n")
+	buffer.WriteString("# TYPE logicalpermissions_check_duration_seconds histogram\n")
+	for _, outcome := range check_outcomes {
+		this.writePrometheusHistogram(&buffer, "logicalpermissions_check_duration_seconds", "outcome="+quotePrometheusLabel(outcome), this.checks[outcome])
+	}
+
+	this.writePrometheusLabeled(&buffer, "callback", "type", "permission type callback calls by permission type and outcome", this.callbacks)
+	this.writePrometheusLabeled(&buffer, "gate", "gate", "logic gate evaluations by gate and outcome", this.gates)
+
+	categories := []string{}
+	for category := range this.errors {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	buffer.WriteString("# HELP logicalpermissions_errors_total Number of access checks that failed, by error category.\n")
+	buffer.WriteString("# TYPE logicalpermissions_errors_total counter\n")
+	for _, category := range categories {
+		fmt.Fprintf(&buffer, "logicalpermissions_errors_total{category=%s} %d\n", quotePrometheusLabel(category), this.errors[category])
+	}
+
+	buffer.WriteString("# HELP logicalpermissions_bypass_grants_total Number of access checks granted by the bypass callback.\n")
+	buffer.WriteString("# TYPE logicalpermissions_bypass_grants_total counter\n")
+	fmt.Fprintf(&buffer, "logicalpermissions_bypass_grants_total %d\n", this.bypass_grants)
+
+	_, err := writer.Write(buffer.Bytes())
+	return err
+}
+
+// ServeHTTP serves the metrics in the Prometheus text exposition format.
+func (this *Metrics) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	response.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	this.WritePrometheus(response)
+}
+
+func (this *Metrics) writePrometheusLabeled(buffer *bytes.Buffer, metric string, label string, description string, histograms map[metricsLabels]*histogram) {
+	labels := []metricsLabels{}
+	for key := range histograms {
+		labels = append(labels, key)
+	}
+	sort.Sort(sortedMetricsLabels(labels))
+
+	name := "logicalpermissions_" + metric + "s_total"
+	fmt.Fprintf(buffer, "# HELP %s Number of %s.\n", name, description)
+	fmt.Fprintf(buffer, "# TYPE %s counter\n", name)
+	for _, key := range labels {
+		fmt.Fprintf(buffer, "%s{%s=%s,outcome=%s} %d\n", name, label, quotePrometheusLabel(key.name), quotePrometheusLabel(key.outcome), histograms[key].count)
+	}
+	name = "logicalpermissions_" + metric + "_duration_seconds"
+	fmt.Fprintf(buffer, "# HELP %s Duration of %s.\n", name, description)
+	fmt.Fprintf(buffer, "# TYPE %s histogram\n", name)
+	for _, key := range labels {
+		this.writePrometheusHistogram(buffer, name, fmt.Sprintf("%s=%s,outcome=%s", label, quotePrometheusLabel(key.name), quotePrometheusLabel(key.outcome)), histograms[key])
+	}
+}
+
+func (this *Metrics) writePrometheusHistogram(buffer *bytes.Buffer, name string, labels string, h *histogram) {
+	for i, bucket := range this.buckets {
+		fmt.Fprintf(buffer, "%s_bucket{%s,le=\"%s\"} %d\n", name, labels, strconv.FormatFloat(bucket, 'g', -1, 64), h.counts[i])
+	}
+	fmt.Fprintf(buffer, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+	fmt.Fprintf(buffer, "%s_sum{%s} %s\n", name, labels, strconv.FormatFloat(h.sum, 'g', -1, 64))
+	fmt.Fprintf(buffer, "%s_count{%s} %d\n", name, labels, h.count)
+}
+
+func quotePrometheusLabel(value string) string {
+	return "\"" + strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n").Replace(value) + "\""
+}
@@ -0,0 +1,132 @@
+package logicalpermissions_test
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/ordermind/logical-permissions-go"
+	"github.com/stretchr/testify/assert"
+)
+
+/*-------------LogicalPermissions::GetMetrics()--------------*/
+
+func TestGetMetrics(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	assert.Nil(t, lp.GetMetrics())
+	metrics := NewMetrics(nil)
+	lp.SetMetrics(metrics)
+	assert.Equal(t, metrics, lp.GetMetrics())
+}
+
+/*-------------Metrics--------------*/
+
+func TestMetricsString(t *testing.T) {
+	t.Parallel()
+	lp := newRoleLogicalPermissions(t, nil)
+	metrics := NewMetrics([]float64{10, 1})
+	lp.SetMetrics(metrics)
+	lp.SetBypassCallback(func(context map[string]interface{}) (bool, error) {
+		if context["role"] == "broken" {
+			return false, errors.New("broken bypass")
+		}
+		return context["role"] == "superuser", nil
+	})
+	permissions := map[string]interface{}{"AND": []interface{}{map[string]interface{}{"role": "admin"}, map[string]interface{}{"NOT": map[string]interface{}{"role": "editor"}}}}
+	lp.CheckAccess(permissions, map[string]interface{}{"role": "admin"})
+	lp.CheckAccess(permissions, map[string]interface{}{"role": "editor"})
+	lp.CheckAccess(permissions, map[string]interface{}{"role": "superuser"})
+	lp.CheckAccess(permissions, map[string]interface{}{"role": "broken"})
+	lp.CheckAccessNoBypass(map[string]interface{}{"role": "broken"}, map[string]interface{}{})
+	lp.CheckAccess(map[string]interface{}{"NOR": []interface{}{}}, map[string]interface{}{})
+	lp.CheckAccess(map[string]interface{}{"flag": "beta"}, map[string]interface{}{})
+
+	var snapshot struct {
+		Checks map[string]struct {
+			Count   uint64            `json:"count"`
+			Buckets map[string]uint64 `json:"buckets"`
+		} `json:"checks"`
+		Callbacks    map[string]map[string]struct{ Count uint64 } `json:"callbacks"`
+		Gates        map[string]map[string]struct{ Count uint64 } `json:"gates"`
+		Errors       map[string]uint64                            `json:"errors"`
+		BypassGrants uint64                                       `json:"bypass_grants"`
+	}
+	assert.Nil(t, json.Unmarshal([]byte(metrics.String()), &snapshot))
+	assert.Equal(t, uint64(2), snapshot.Checks["granted"].Count)
+	assert.Equal(t, map[string]uint64{"1": 2, "10": 2}, snapshot.Checks["granted"].Buckets)
+	assert.Equal(t, uint64(1), snapshot.Checks["denied"].Count)
+	assert.Equal(t, uint64(4), snapshot.Checks["error"].Count)
+	assert.Equal(t, uint64(1), snapshot.Callbacks["role"]["granted"].Count)
+	assert.Equal(t, uint64(2), snapshot.Callbacks["role"]["denied"].Count)
+	assert.Equal(t, uint64(1), snapshot.Callbacks["role"]["error"].Count)
+	assert.Equal(t, uint64(1), snapshot.Gates["AND"]["granted"].Count)
+	assert.Equal(t, uint64(1), snapshot.Gates["AND"]["denied"].Count)
+	assert.Equal(t, uint64(1), snapshot.Gates["NOT"]["granted"].Count)
+	assert.NotContains(t, snapshot.Gates["NOT"], "denied")
+	assert.Equal(t, uint64(1), snapshot.Gates["NOR"]["error"].Count)
+	assert.Equal(t, map[string]uint64{
+		MetricsErrorBypassCallback:     1,
+		MetricsErrorCallback:           1,
+		MetricsErrorInvalidPermissions: 1,
+		MetricsErrorTypeNotRegistered:  1,
+	}, snapshot.Errors)
+	assert.Equal(t, uint64(1), snapshot.BypassGrants)
+
+	metrics.Reset()
+	assert.Equal(t, `{"bypass_grants":0,"callbacks":{},"checks":{},"errors":{},"gates":{}}`, metrics.String())
+
+	// Metrics can be published with expvar.
+	var _ expvar.Var = metrics
+}
+
+func TestMetricsPrometheus(t *testing.T) {
+	t.Parallel()
+	lp := newRoleLogicalPermissions(t, nil)
+	metrics := NewMetrics([]float64{10, 1})
+	lp.SetMetrics(metrics)
+	lp.AddType("quoted\"type", func(string, map[string]interface{}) (bool, error) { return false, nil })
+	lp.CheckAccess(map[string]interface{}{"OR": []interface{}{map[string]interface{}{"quoted\"type": "x"}, map[string]interface{}{"role": "admin"}}}, map[string]interface{}{"role": "admin"})
+	lp.CheckAccess(true, map[string]interface{}{"role": "superuser"})
+
+	recorder := httptest.NewRecorder()
+	request, _ := http.NewRequest("GET", "/metrics", nil)
+	metrics.ServeHTTP(recorder, request)
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", recorder.Header().Get("Content-Type"))
+	body := recorder.Body.String()
+	for _, line := range []string{
+		"# TYPE logicalpermissions_checks_total counter",
+		`logicalpermissions_checks_total{outcome="granted"} 2`,
+		"# TYPE logicalpermissions_check_duration_seconds histogram",
+		`logicalpermissions_check_duration_seconds_bucket{outcome="granted",le="1"} 2`,
+		`logicalpermissions_check_duration_seconds_bucket{outcome="granted",le="+Inf"} 2`,
+		`logicalpermissions_check_duration_seconds_count{outcome="granted"} 2`,
+		`logicalpermissions_callbacks_total{type="quoted\"type",outcome="denied"} 1`,
+		`logicalpermissions_callbacks_total{type="role",outcome="granted"} 1`,
+		`logicalpermissions_callback_duration_seconds_bucket{type="role",outcome="granted",le="10"} 1`,
+		`logicalpermissions_gates_total{gate="OR",outcome="granted"} 1`,
+		"# TYPE logicalpermissions_errors_total counter",
+		"logicalpermissions_bypass_grants_total 1",
+	} {
+		assert.Contains(t, body, line+"\n")
+	}
+	assert.True(t, strings.Index(body, `type="quoted\"type"`) < strings.Index(body, `type="role"`))
+}
+
+func TestMetricsShadow(t *testing.T) {
+	t.Parallel()
+	lp := newRoleLogicalPermissions(t, nil)
+	metrics := NewMetrics([]float64{10, 1})
+	lp.SetMetrics(metrics)
+	observer := &channelShadowObserver{events: make(chan ShadowEvent, 1)}
+	lp.SetShadowObserver(observer)
+	lp.CheckAccessShadow(map[string]interface{}{"role": "admin"}, map[string]interface{}{"AND": []interface{}{map[string]interface{}{"role": "editor"}}}, map[string]interface{}{"role": "admin"})
+	observer.next(t)
+	assert.NotContains(t, metrics.String(), "editor")
+	assert.NotContains(t, metrics.String(), "AND")
+	assert.Contains(t, metrics.String(), `"role":{"granted"`)
+}
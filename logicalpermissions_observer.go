@@ -0,0 +1,164 @@
+package logicalpermissions
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Observer is notified about access checks and the callbacks they invoke.
+// Observers must be safe for concurrent use. Access checks may run
+// concurrently, parallel evaluation calls observers from worker goroutines, and
+// the callbacks of cancelled parallel evaluations may be reported after the
+// OnCheckEnd of their access check.
+type Observer interface {
+	OnCheckStart(event CheckStartEvent)
+	OnBypass(event BypassEvent)
+	OnCallback(event CallbackEvent)
+	OnCheckEnd(event CheckEndEvent)
+}
+
+// BaseObserver implements Observer with methods that do nothing. Embed it in
+// order to implement only some of the methods.
+type BaseObserver struct{}
+
+func (this BaseObserver) OnCheckStart(event CheckStartEvent) {}
+func (this BaseObserver) OnBypass(event BypassEvent)         {}
+func (this BaseObserver) OnCallback(event CallbackEvent)     {}
+func (this BaseObserver) OnCheckEnd(event CheckEndEvent)     {}
+
+// CheckStartEvent is sent before a permission tree is evaluated. The CheckID is
+// shared by all events of the same access check, and it is unique for the
+// access checks of a LogicalPermissions. BypassRegistered is true if a bypass
+// callback is registered, even if it is not checked.
+type CheckStartEvent struct {
+	CheckID          uint64
+	Permissions      interface{}
+	Context          map[string]interface{}
+	AllowBypass      bool
+	BypassRegistered bool
+}
+
+// BypassEvent is sent after the callback of a bypass has been called. The Name
+// is DefaultBypass for the bypass callback. Cached is true if the result was
+// returned by the cache or a Session instead of the callback, in which case
+// the Duration is zero.
+type BypassEvent struct {
+	CheckID  uint64
+	Name     string
+	Context  map[string]interface{}
+	Access   bool
+	Cached   bool
+	Duration time.Duration
+	Err      error
+}
+
+// CallbackEvent is sent after the callback of a permission type has been
+// called. Cached is true if the result was returned by the cache or a Session
+// instead of the callback, in which case the Duration is zero.
+type CallbackEvent struct {
+	CheckID    uint64
+	Type       string
+	Permission string
+	Context    map[string]interface{}
+	Access     bool
+	Cached     bool
+	Duration   time.Duration
+	Err        error
+}
+
+// CheckEndEvent is sent after a permission tree has been evaluated.
+type CheckEndEvent struct {
+	CheckID     uint64
+	Permissions interface{}
+	Context     map[string]interface{}
+	AllowBypass bool
+	Access      bool
+	// Unknown is true if the result is unknown in three-valued mode, in which
+	// case Access is false.
+	Unknown bool
+	// Cached is true if the decision was returned by the cache, in which case
+	// no bypass or callback events were sent.
+	Cached   bool
+	Duration time.Duration
+	Err      error
+}
+
+func (this *LogicalPermissions) AddObserver(observer Observer) {
+	this.observers = append(this.GetObservers(), observer)
+}
+
+func (this *LogicalPermissions) GetObservers() []Observer {
+	observers := make([]Observer, len(this.observers))
+	copy(observers, this.observers)
+	return observers
+}
+
+func (this *LogicalPermissions) SetObservers(observers []Observer) {
+	this.observers = make([]Observer, len(observers))
+	copy(this.observers, observers)
+}
+
+// observeCheck notifies the observers about an access check that is evaluated
+// by check. The end of the access check is also reported if check panics, so
+// that observers can release its state, before the panic continues.
+func (this *LogicalPermissions) observeCheck(permissions interface{}, context map[string]interface{}, allow_bypass bool, eval *evaluation, check func() (bool, error)) (bool, error) {
+	if len(this.observers) == 0 {
+		return check()
+	}
+	eval.check_id = atomic.AddUint64(&this.last_check_id, 1)
+	for _, observer := range this.observers {
+		observer.OnCheckStart(CheckStartEvent{CheckID: eval.check_id, Permissions: permissions, Context: context, AllowBypass: allow_bypass, BypassRegistered: this.GetBypassCallback() != nil})
+	}
+	start := time.Now()
+	// The error is replaced with the result of check unless it panics.
+	var access bool
+	var err error = &CustomError{"Error checking access: The access check panicked."}
+	defer func() {
+		duration := time.Since(start)
+		for _, observer := range this.observers {
+			observer.OnCheckEnd(CheckEndEvent{CheckID: eval.check_id, Permissions: permissions, Context: context, AllowBypass: allow_bypass, Access: access, Unknown: eval.unknown, Cached: eval.cached, Duration: duration, Err: err})
+		}
+	}()
+	access, err = check()
+	return access, err
+}
+
+func (this *LogicalPermissions) observeBypass(name string, context map[string]interface{}, eval *evaluation, access bool, duration time.Duration, err error) {
+	if eval.isShadow() {
+		return
+	}
+	for _, observer := range this.observers {
+		observer.OnBypass(BypassEvent{CheckID: eval.getCheckID(), Name: name, Context: context, Access: access, Duration: duration, Err: err})
+	}
+}
+
+func (this *LogicalPermissions) observeCallback(permission string, permtype string, context map[string]interface{}, eval *evaluation, access bool, duration time.Duration, err error) {
+	if eval.isShadow() {
+		return
+	}
+	for _, observer := range this.observers {
+		observer.OnCallback(CallbackEvent{CheckID: eval.getCheckID(), Type: permtype, Permission: permission, Context: context, Access: access, Duration: duration, Err: err})
+	}
+}
+
+// observeCachedResult notifies the observers about a callback result that was
+// returned by the cache or a session instead of the callback. The permtype is
+// empty for bypasses, and the permission is the name of a named bypass.
+func (this *LogicalPermissions) observeCachedResult(permtype string, permission string, context map[string]interface{}, eval *evaluation, result callbackResult) {
+	if eval.isShadow() {
+		return
+	}
+	if permtype == "" {
+		name := permission
+		if name == "" {
+			name = DefaultBypass
+		}
+		for _, observer := range this.observers {
+			observer.OnBypass(BypassEvent{CheckID: eval.getCheckID(), Name: name, Context: context, Access: result.access, Cached: true, Err: result.err})
+		}
+		return
+	}
+	for _, observer := range this.observers {
+		observer.OnCallback(CallbackEvent{CheckID: eval.getCheckID(), Type: permtype, Permission: permission, Context: context, Access: result.access, Cached: true, Err: result.err})
+	}
+}
@@ -0,0 +1,119 @@
+package logicalpermissions_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	. "github.com/ordermind/logical-permissions-go"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingObserver struct {
+	events []string
+	ids    []uint64
+}
+
+func (this *recordingObserver) OnCheckStart(event CheckStartEvent) {
+	this.events = append(this.events, fmt.Sprintf("start %v %t", event.Permissions, event.AllowBypass))
+	this.ids = append(this.ids, event.CheckID)
+}
+
+func (this *recordingObserver) OnBypass(event BypassEvent) {
+	this.events = append(this.events, fmt.Sprintf("bypass %t %v", event.Access, event.Err))
+	this.ids = append(this.ids, event.CheckID)
+}
+
+func (this *recordingObserver) OnCallback(event CallbackEvent) {
+	this.events = append(this.events, fmt.Sprintf("callback %s %s %t %v", event.Type, event.Permission, event.Access, event.Err))
+	this.ids = append(this.ids, event.CheckID)
+}
+
+func (this *recordingObserver) OnCheckEnd(event CheckEndEvent) {
+	this.events = append(this.events, fmt.Sprintf("end %t %t", event.Access, event.Err != nil))
+	this.ids = append(this.ids, event.CheckID)
+}
+
+type callbackCounter struct {
+	BaseObserver
+	count  int
+	cached int
+}
+
+func (this *callbackCounter) OnCallback(event CallbackEvent) {
+	if event.Cached {
+		this.cached++
+		return
+	}
+	this.count++
+}
+
+/*-------------LogicalPermissions::AddObserver()--------------*/
+
+func TestAddObserver(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	err := lp.AddType("role", func(role string, context map[string]interface{}) (bool, error) {
+		if role == "broken" {
+			return false, errors.New("broken role")
+		}
+		return role == "admin", nil
+	})
+	if err != nil {
+		t.Error(fmt.Sprintf("LogicalPermissions::AddType() returned an error: %s", err))
+	}
+	lp.SetBypassCallback(func(map[string]interface{}) (bool, error) { return false, nil })
+	observer := &recordingObserver{}
+	counter := &callbackCounter{}
+	lp.AddObserver(observer)
+	lp.AddObserver(counter)
+	assert.Equal(t, []Observer{observer, counter}, lp.GetObservers())
+
+	access, err := lp.CheckAccess(`{"role": ["editor", "admin"]}`, map[string]interface{}{})
+	assert.Nil(t, err)
+	assert.True(t, access)
+	assert.Equal(t, []string{
+		`start {"role": ["editor", "admin"]} true`,
+		"bypass false <nil>",
+		"callback role editor false <nil>",
+		"callback role admin true <nil>",
+		"end true false",
+	}, observer.events)
+	assert.Equal(t, 2, counter.count)
+	// Check IDs are counted per LogicalPermissions.
+	assert.Equal(t, uint64(1), observer.ids[0])
+	for _, id := range observer.ids {
+		assert.Equal(t, observer.ids[0], id)
+	}
+
+	observer.events = nil
+	observer.ids = nil
+	access, err = lp.CheckAccessNoBypass(`{"role": "broken"}`, map[string]interface{}{})
+	assert.Error(t, err)
+	assert.False(t, access)
+	assert.Equal(t, []string{
+		`start {"role": "broken"} false`,
+		"callback role broken false broken role",
+		"end false true",
+	}, observer.events)
+	assert.Equal(t, uint64(2), observer.ids[0])
+	assert.Equal(t, 3, counter.count)
+}
+
+/*-------------LogicalPermissions::SetObservers()--------------*/
+
+func TestSetObservers(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	assert.Equal(t, []Observer{}, lp.GetObservers())
+	observer := &recordingObserver{}
+	observers := []Observer{observer}
+	lp.SetObservers(observers)
+	observers[0] = &callbackCounter{}
+	assert.Equal(t, []Observer{observer}, lp.GetObservers())
+
+	access, err := lp.CheckAccess(true, map[string]interface{}{})
+	assert.Nil(t, err)
+	assert.True(t, access)
+	assert.Equal(t, []string{"start true true", "end true false"}, observer.events)
+}
@@ -0,0 +1,61 @@
+package logicalpermissions
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+func (this *LogicalPermissions) GetRecoverPanics() bool {
+	return this.recover_panics
+}
+
+func (this *LogicalPermissions) SetRecoverPanics(recover_panics bool) {
+	this.recover_panics = recover_panics
+}
+
+// newCallbackPanicError creates the error of a callback that panicked. The
+// permtype is empty for the bypass callback, and the permission is empty for
+// the bypass callback and for batch callbacks.
+func newCallbackPanicError(permtype string, permission string, recovered interface{}, stack []byte) *CallbackPanicError {
+	msg := fmt.Sprintf("The bypass callback panicked: %v", recovered)
+	if permtype != "" && permission == "" {
+		msg = fmt.Sprintf("The batch callback of the permission type \"%s\" panicked: %v", permtype, recovered)
+	} else if permtype != "" {
+		msg = fmt.Sprintf("The callback of the permission type \"%s\" panicked for the permission \"%s\": %v", permtype, permission, recovered)
+	}
+	return &CallbackPanicError{CustomError: CustomError{msg}, Type: permtype, Permission: permission, Recovered: recovered, Stack: stack}
+}
+
+// callRecovered calls a callback and converts a panic into a
+// CallbackPanicError if panics are recovered.
+func (this *LogicalPermissions) callRecovered(permtype string, permission string, callback func() error) (err error) {
+	if this.recover_panics {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				err = newCallbackPanicError(permtype, permission, recovered, debug.Stack())
+			}
+		}()
+	}
+	return callback()
+}
+
+// getCallbackError converts an error returned by a callback into an error of
+// the access check. Errors of this package are copied, because the message of
+// the error of the access check is changed later on.
+func getCallbackError(err error) CustomErrorInterface {
+	switch callback_err := err.(type) {
+	case *CallbackPanicError:
+		copied := *callback_err
+		return &copied
+	case *CallbackTimeoutError:
+		copied := *callback_err
+		return &copied
+	case *CallbackConcurrencyLimitError:
+		copied := *callback_err
+		return &copied
+	case *CircuitOpenError:
+		copied := *callback_err
+		return &copied
+	}
+	return &CustomError{err.Error()}
+}
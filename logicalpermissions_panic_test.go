@@ -0,0 +1,85 @@
+package logicalpermissions_test
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/ordermind/logical-permissions-go"
+	"github.com/stretchr/testify/assert"
+)
+
+/*-------------LogicalPermissions::SetRecoverPanics()--------------*/
+
+func TestSetRecoverPanics(t *testing.T) {
+	t.Parallel()
+	lp := newRoleLogicalPermissions(t, nil)
+	assert.False(t, lp.GetRecoverPanics())
+	assert.PanicsWithValue(t, "role panic", func() {
+		lp.CheckAccessNoBypass(map[string]interface{}{"role": "panic"}, map[string]interface{}{})
+	})
+	lp.SetRecoverPanics(true)
+	assert.True(t, lp.GetRecoverPanics())
+}
+
+func TestCheckAccessRecoverPanics(t *testing.T) {
+	t.Parallel()
+	lp := newRoleLogicalPermissions(t, nil)
+	lp.SetBypassCallback(func(context map[string]interface{}) (bool, error) {
+		if context["bypass"] == "panic" {
+			panic("bypass panic")
+		}
+		return false, nil
+	})
+	lp.SetRecoverPanics(true)
+
+	access, err := lp.CheckAccess(map[string]interface{}{"role": "panic"}, map[string]interface{}{})
+	assert.False(t, access)
+	assert.EqualError(t, err, "Error checking access: The callback of the permission type \"role\" panicked for the permission \"panic\": role panic")
+	panic_err, ok := err.(*CallbackPanicError)
+	assert.True(t, ok)
+	assert.Equal(t, "role", panic_err.Type)
+	assert.Equal(t, "panic", panic_err.Permission)
+	assert.Equal(t, "role panic", panic_err.Recovered)
+	assert.True(t, strings.Contains(string(panic_err.Stack), "newRoleLogicalPermissions"))
+
+	access, err = lp.CheckAccess(map[string]interface{}{"role": "admin"}, map[string]interface{}{"bypass": "panic"})
+	assert.False(t, access)
+	assert.EqualError(t, err, "Error checking bypass access: The bypass callback panicked: bypass panic")
+	panic_err, ok = err.(*CallbackPanicError)
+	assert.True(t, ok)
+	assert.Equal(t, "", panic_err.Type)
+	assert.Equal(t, "bypass panic", panic_err.Recovered)
+
+	// Recovered panics are callback errors, so the error policies apply to them.
+	lp.SetContinueOnError(true)
+	access, err = lp.CheckAccessNoBypass(map[string]interface{}{"role": []interface{}{"panic", "admin"}}, map[string]interface{}{"role": "admin"})
+	assert.Nil(t, err)
+	assert.True(t, access)
+	_, err = lp.CheckAccessNoBypass(map[string]interface{}{"role": []interface{}{"panic", "editor"}}, map[string]interface{}{"role": "admin"})
+	assert.IsType(t, &CallbackPanicError{}, err)
+}
+
+func TestCheckAccessRecoverPanicsParallel(t *testing.T) {
+	t.Parallel()
+	lp := newRoleLogicalPermissions(t, nil)
+	lp.SetRecoverPanics(true)
+	lp.SetParallelism(4)
+	access, err := lp.CheckAccessNoBypass(map[string]interface{}{"role": []interface{}{"editor", "panic"}}, map[string]interface{}{})
+	assert.False(t, access)
+	assert.IsType(t, &CallbackPanicError{}, err)
+}
+
+func TestCheckAccessRecoverPanicsBatch(t *testing.T) {
+	t.Parallel()
+	lp := newRoleLogicalPermissions(t, nil)
+	lp.SetRecoverPanics(true)
+	lp.SetTypeBatchCallback("role", func(roles []string, context map[string]interface{}) (map[string]bool, error) {
+		panic("batch panic")
+	})
+	_, err := lp.CheckAccessNoBypass(map[string]interface{}{"role": []interface{}{"editor", "admin"}}, map[string]interface{}{})
+	assert.EqualError(t, err, "Error checking access: The batch callback of the permission type \"role\" panicked: batch panic")
+	panic_err, ok := err.(*CallbackPanicError)
+	assert.True(t, ok)
+	assert.Equal(t, "role", panic_err.Type)
+	assert.Equal(t, "", panic_err.Permission)
+}
@@ -0,0 +1,164 @@
+package logicalpermissions
+
+import (
+	"sort"
+	"strconv"
+)
+
+// evaluationCancelledMessage is the error message of forked evaluations that
+// are cancelled. Their results are discarded, so it is never returned.
+const evaluationCancelledMessage = "The evaluation was cancelled because the result of a parent gate has been decided."
+
+func (this *LogicalPermissions) GetParallelism() int {
+	return this.parallelism
+}
+
+func (this *LogicalPermissions) SetParallelism(parallelism int) error {
+	if parallelism < 0 {
+		return &InvalidArgumentValueError{CustomError{"The parallelism parameter cannot be negative."}}
+	}
+	this.parallelism = parallelism
+	return nil
+}
+
+// gateChild is a child of a gate. The segment is the path segment of a child
+// in a slice, and empty for a child in a map, which adds its own key to the
+// path.
+type gateChild struct {
+	segment     string
+	permissions interface{}
+}
+
+type gateChildResult struct {
+	index     int
+	access    bool
+	err       CustomErrorInterface
+	recovered interface{}
+	eval      *evaluation
+}
+
+// getParallelChildren returns the children of a gate in the order of
+// evaluation if they are to be evaluated in parallel. Children in a map are
+// ordered by key unless the order is optimized. Gates with a single child and
+// invalid gate values are left to the sequential evaluation.
+func (this *LogicalPermissions) getParallelChildren(permissions interface{}, gate string, min_children int, permtype string, eval *evaluation) ([]gateChild, bool) {
+	if eval == nil || eval.slots == nil {
+		return nil, false
+	}
+	children := []gateChild{}
+	if slice_permissions, ok := permissions.([]interface{}); ok {
+		for _, i := range this.getChildOrder(slice_permissions, gate, permtype, eval) {
+			children = append(children, gateChild{segment: strconv.Itoa(i), permissions: slice_permissions[i]})
+		}
+	} else if map_permissions, ok := permissions.(map[string]interface{}); ok {
+		keys := make([]string, 0, len(map_permissions))
+		for key := range map_permissions {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		if eval.optimize_order {
+			keys = this.getKeyOrder(map_permissions, gate, permtype, eval)
+		}
+		for _, key := range keys {
+			children = append(children, gateChild{permissions: map[string]interface{}{key: map_permissions[key]}})
+		}
+	}
+	if len(children) < min_children || len(children) < 2 {
+		return nil, false
+	}
+	return children, true
+}
+
+// dispatchParallel evaluates the children of a gate in goroutines, as long as
+// there are free slots, and in the current goroutine otherwise. The last child
+// always runs in the current goroutine, which would otherwise wait. The
+// results are passed to decide in the order of the children, and decide
+// returns true once the result of the gate is decided. The first error in that
+// order is returned, so that the outcome is the same as that of a sequential
+// evaluation. Children with unknown results are skipped, and the first return
+// value is the merged unknown result of those children, if any. The remaining children are cancelled once
+// the result is decided, which stops them from calling further callbacks.
+func (this *LogicalPermissions) dispatchParallel(children []gateChild, permtype string, context map[string]interface{}, eval *evaluation, decide func(result bool) bool) (*unknownResult, CustomErrorInterface) {
+	cancel := &evaluationCancel{parent: eval.cancel}
+	defer cancel.cancelEvaluation()
+	results := make([]*gateChildResult, len(children))
+	done := make(chan *gateChildResult, len(children))
+	next := 0
+	var unknown *unknownResult
+	consume := func() (bool, CustomErrorInterface) {
+		for next < len(children) && results[next] != nil {
+			result := results[next]
+			next++
+			if result.recovered != nil {
+				panic(result.recovered)
+			}
+			eval.merge(result.eval)
+			if isUnknownResult(result.err) {
+				unknown = mergeUnknown(unknown, result.err)
+				continue
+			}
+			if result.err != nil {
+				return true, result.err
+			}
+			if decide(result.access) {
+				return true, nil
+			}
+		}
+		return next == len(children), nil
+	}
+
+	for i, child := range children {
+		if eval.isCancelled() {
+			return nil, &CustomError{evaluationCancelledMessage}
+		}
+		for drained := false; !drained; {
+			select {
+			case result := <-done:
+				results[result.index] = result
+			default:
+				drained = true
+			}
+		}
+		if decided, err_custom := consume(); decided {
+			return unknown, err_custom
+		}
+		child_eval := eval.fork(cancel)
+		if i == len(children)-1 {
+			results[i] = this.dispatchChild(i, child, permtype, context, child_eval)
+			continue
+		}
+		select {
+		case eval.slots <- struct{}{}:
+			go func(i int, child gateChild) {
+				defer func() { <-eval.slots }()
+				done <- this.dispatchChild(i, child, permtype, context, child_eval)
+			}(i, child)
+		default:
+			results[i] = this.dispatchChild(i, child, permtype, context, child_eval)
+		}
+	}
+	for {
+		if decided, err_custom := consume(); decided {
+			return unknown, err_custom
+		}
+		result := <-done
+		results[result.index] = result
+	}
+}
+
+// dispatchChild evaluates a gate child with a forked evaluation. A panic is
+// recovered so that it can be raised again in the goroutine of the gate.
+func (this *LogicalPermissions) dispatchChild(index int, child gateChild, permtype string, context map[string]interface{}, eval *evaluation) (result *gateChildResult) {
+	result = &gateChildResult{index: index, eval: eval}
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			result.recovered = recovered
+		}
+	}()
+	if child.segment != "" {
+		eval.pushPath(child.segment)
+		defer eval.popPath()
+	}
+	result.access, result.err = this.dispatch(child.permissions, permtype, context, eval)
+	return result
+}
@@ -0,0 +1,152 @@
+package logicalpermissions_test
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/ordermind/logical-permissions-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// getRandomPermissionTree generates a permission tree of slices, so that the
+// order in which sequential evaluation visits the children is fixed.
+func getRandomPermissionTree(random *rand.Rand, depth int) interface{} {
+	if depth == 0 || random.Intn(4) == 0 {
+		if random.Intn(10) == 0 {
+			return []interface{}{"TRUE", "FALSE"}[random.Intn(2)]
+		}
+		return map[string]interface{}{"role": "p" + strconv.Itoa(random.Intn(20))}
+	}
+	gate := []string{"AND", "OR", "XOR", "NAND", "NOR", "NOT"}[random.Intn(6)]
+	if gate == "NOT" {
+		return map[string]interface{}{"NOT": getRandomPermissionTree(random, depth-1)}
+	}
+	children := []interface{}{}
+	for i := 0; i < 2+random.Intn(3); i++ {
+		children = append(children, getRandomPermissionTree(random, depth-1))
+	}
+	return map[string]interface{}{gate: children}
+}
+
+/*-------------LogicalPermissions::SetParallelism()--------------*/
+
+func TestSetParallelism(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	assert.Equal(t, 0, lp.GetParallelism())
+	err := lp.SetParallelism(-1)
+	assert.IsType(t, &InvalidArgumentValueError{}, err)
+	assert.Nil(t, lp.SetParallelism(4))
+	assert.Equal(t, 4, lp.GetParallelism())
+}
+
+/*-------------Parallel evaluation--------------*/
+
+func TestCheckAccessParallelMatchesSequential(t *testing.T) {
+	t.Parallel()
+	outcomes := make(map[string]int)
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		outcomes["p"+strconv.Itoa(i)] = random.Intn(7)
+	}
+	callback := func(role string, context map[string]interface{}) (bool, error) {
+		outcome := outcomes[role]
+		time.Sleep(time.Duration(outcome) * 50 * time.Microsecond)
+		if outcome == 0 {
+			return false, errors.New("broken " + role)
+		}
+		return outcome%2 == 1, nil
+	}
+	sequential := &LogicalPermissions{}
+	sequential.AddType("role", callback)
+	parallel := &LogicalPermissions{}
+	parallel.AddType("role", callback)
+	parallel.SetParallelism(3)
+
+	for i := 0; i < 300; i++ {
+		permissions := getRandomPermissionTree(random, 4)
+		expected_access, expected_trace, expected_err := sequential.CheckAccessNoBypassWithTrace(permissions, map[string]interface{}{})
+		access, trace, err := parallel.CheckAccessNoBypassWithTrace(permissions, map[string]interface{}{})
+		message := fmt.Sprintf("Permissions: %v", permissions)
+		assert.Equal(t, expected_access, access, message)
+		assert.Equal(t, expected_err, err, message)
+		assert.Equal(t, expected_trace, trace, message)
+	}
+}
+
+func TestCheckAccessParallel(t *testing.T) {
+	t.Parallel()
+	counter := &callCounter{calls: make(map[string]int)}
+	mutex := sync.Mutex{}
+	running := 0
+	max_running := 0
+	lp := &LogicalPermissions{}
+	lp.AddType("role", func(role string, context map[string]interface{}) (bool, error) {
+		counter.add(role)
+		mutex.Lock()
+		running++
+		if running > max_running {
+			max_running = running
+		}
+		mutex.Unlock()
+		if role == "admin" {
+			time.Sleep(40 * time.Millisecond)
+		} else {
+			time.Sleep(10 * time.Millisecond)
+		}
+		mutex.Lock()
+		running--
+		mutex.Unlock()
+		return role == "admin", nil
+	})
+	lp.SetParallelism(3)
+
+	permissions := map[string]interface{}{"OR": []interface{}{
+		map[string]interface{}{"role": "editor"},
+		map[string]interface{}{"role": "writer"},
+		map[string]interface{}{"role": "admin"},
+		map[string]interface{}{"role": "guest"},
+		map[string]interface{}{"role": "member"},
+	}}
+	start := time.Now()
+	access, err := lp.CheckAccessNoBypass(permissions, map[string]interface{}{})
+	assert.Nil(t, err)
+	assert.True(t, access)
+	// The first two children run in goroutines while the third runs in the
+	// goroutine of the access check, and the result is decided before the
+	// remaining children are started.
+	assert.True(t, time.Since(start) < 60*time.Millisecond)
+	assert.True(t, max_running <= 3)
+	assert.Equal(t, 1, counter.get("admin"))
+	assert.Equal(t, 0, counter.get("guest"))
+	assert.Equal(t, 0, counter.get("member"))
+
+	// Nested gates share the bound of the access check.
+	max_running = 0
+	nested := map[string]interface{}{"AND": []interface{}{permissions, map[string]interface{}{"OR": []interface{}{"FALSE", map[string]interface{}{"role": []interface{}{"admin", "x", "y", "z"}}}}}}
+	access, err = lp.CheckAccessNoBypass(nested, map[string]interface{}{})
+	assert.Nil(t, err)
+	assert.True(t, access)
+	assert.True(t, max_running <= 3)
+}
+
+func TestCheckAccessParallelPanic(t *testing.T) {
+	t.Parallel()
+	lp := &LogicalPermissions{}
+	lp.AddType("role", func(role string, context map[string]interface{}) (bool, error) {
+		if role == "panic" {
+			panic("callback panic")
+		}
+		return false, nil
+	})
+	lp.SetParallelism(4)
+	// A panic in a goroutine is raised again in the goroutine of the access check.
+	assert.PanicsWithValue(t, "callback panic", func() {
+		lp.CheckAccessNoBypass(map[string]interface{}{"role": []interface{}{"editor", "panic"}}, map[string]interface{}{})
+	})
+}
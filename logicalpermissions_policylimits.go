@@ -0,0 +1,98 @@
+package logicalpermissions
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// PolicyLimits holds the limits of the permission trees that are evaluated,
+// which protect against permission trees from untrusted sources. A zero value
+// disables a limit.
+type PolicyLimits struct {
+	// MaxDepth is the maximum number of nested maps and slices.
+	MaxDepth int
+	// MaxNodes is the maximum number of map keys and slice elements.
+	MaxNodes int
+	// MaxCallbacks is the maximum number of permission values that are
+	// checked with the callbacks of permission types during an access check,
+	// including values whose results are cached.
+	MaxCallbacks int
+	// MaxInputSize is the maximum size in bytes of the permission tree encoded
+	// as JSON.
+	MaxInputSize int
+}
+
+func (this *LogicalPermissions) GetPolicyLimits() PolicyLimits {
+	return this.policy_limits
+}
+
+func (this *LogicalPermissions) SetPolicyLimits(limits PolicyLimits) error {
+	if limits.MaxDepth < 0 || limits.MaxNodes < 0 || limits.MaxCallbacks < 0 || limits.MaxInputSize < 0 {
+		return &InvalidArgumentValueError{CustomError{fmt.Sprintf("The policy limits cannot be negative. Current value: %+v", limits)}}
+	}
+	this.policy_limits = limits
+	return nil
+}
+
+func (this *LogicalPermissions) checkInputSize(json_permissions string) error {
+	limit := this.policy_limits.MaxInputSize
+	if limit > 0 && len(json_permissions) > limit {
+		return &LimitExceededError{CustomError{fmt.Sprintf("The permissions exceed the maximum input size of %d bytes. Current size: %d", limit, len(json_permissions))}}
+	}
+	return nil
+}
+
+// checkTreeLimits checks the depth and the node count of a permission tree. The
+// tree is walked without recursion, so that deeply nested trees cannot exhaust
+// the stack.
+func (this *LogicalPermissions) checkTreeLimits(map_permissions map[string]interface{}) error {
+	max_depth, max_nodes := this.policy_limits.MaxDepth, this.policy_limits.MaxNodes
+	if max_depth == 0 && max_nodes == 0 {
+		return nil
+	}
+	type pending struct {
+		value interface{}
+		depth int
+	}
+	stack := []pending{{map_permissions, 1}}
+	nodes := 0
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if max_depth > 0 && current.depth > max_depth {
+			return &LimitExceededError{CustomError{fmt.Sprintf("The permissions exceed the maximum depth of %d.", max_depth)}}
+		}
+		children := []interface{}{}
+		if map_value, ok := current.value.(map[string]interface{}); ok {
+			for _, child := range map_value {
+				children = append(children, child)
+			}
+		} else if slice_value, ok := current.value.([]interface{}); ok {
+			children = slice_value
+		}
+		nodes += len(children)
+		if max_nodes > 0 && nodes > max_nodes {
+			return &LimitExceededError{CustomError{fmt.Sprintf("The permissions exceed the maximum number of %d nodes.", max_nodes)}}
+		}
+		for _, child := range children {
+			switch child.(type) {
+			case map[string]interface{}, []interface{}:
+				stack = append(stack, pending{child, current.depth + 1})
+			}
+		}
+	}
+	return nil
+}
+
+// countCallback counts a permission value that is checked with a callback, and
+// returns an error once the access check exceeds the maximum number.
+func (this *LogicalPermissions) countCallback(eval *evaluation) CustomErrorInterface {
+	if eval == nil || eval.callbacks == nil {
+		return nil
+	}
+	limit := this.policy_limits.MaxCallbacks
+	if count := atomic.AddInt32(eval.callbacks, 1); limit > 0 && int(count) > limit {
+		return &LimitExceededError{CustomError{fmt.Sprintf("The access check exceeds the maximum number of %d callback calls.", limit)}}
+	}
+	return nil
+}
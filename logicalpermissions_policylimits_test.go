@@ -0,0 +1,105 @@
+package logicalpermissions_test
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/ordermind/logical-permissions-go"
+	"github.com/stretchr/testify/assert"
+)
+
+/*-------------LogicalPermissions::SetPolicyLimits()--------------*/
+
+func TestSetPolicyLimits(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	assert.Equal(t, PolicyLimits{}, lp.GetPolicyLimits())
+	err := lp.SetPolicyLimits(PolicyLimits{MaxDepth: -1})
+	assert.IsType(t, &InvalidArgumentValueError{}, err)
+	limits := PolicyLimits{MaxDepth: 10, MaxNodes: 100, MaxCallbacks: 20, MaxInputSize: 4096}
+	assert.Nil(t, lp.SetPolicyLimits(limits))
+	assert.Equal(t, limits, lp.GetPolicyLimits())
+}
+
+/*-------------Policy limits--------------*/
+
+func TestPolicyLimitsMaxDepth(t *testing.T) {
+	t.Parallel()
+	counter := &callCounter{calls: make(map[string]int)}
+	lp := newRoleLogicalPermissions(t, counter)
+	lp.SetPolicyLimits(PolicyLimits{MaxDepth: 4})
+	var permissions interface{} = map[string]interface{}{"role": "admin"}
+	for i := 0; i < 3; i++ {
+		permissions = map[string]interface{}{"NOT": permissions}
+	}
+	access, err := lp.CheckAccess(permissions, map[string]interface{}{"role": "admin"})
+	assert.Nil(t, err)
+	assert.False(t, access)
+
+	permissions = map[string]interface{}{"NOT": permissions}
+	access, err = lp.CheckAccess(permissions, map[string]interface{}{"role": "admin"})
+	assert.False(t, access)
+	assert.IsType(t, &LimitExceededError{}, err)
+	assert.EqualError(t, err, "The permissions exceed the maximum depth of 4.")
+	assert.Equal(t, 1, counter.get("role:admin"))
+
+	// The limits apply wherever permission trees are parsed.
+	_, err = lp.Describe(permissions)
+	assert.IsType(t, &LimitExceededError{}, err)
+}
+
+func TestPolicyLimitsMaxNodes(t *testing.T) {
+	t.Parallel()
+	counter := &callCounter{calls: make(map[string]int)}
+	lp := newRoleLogicalPermissions(t, counter)
+	lp.SetPolicyLimits(PolicyLimits{MaxNodes: 5})
+	access, err := lp.CheckAccess(map[string]interface{}{"OR": []interface{}{map[string]interface{}{"role": "editor"}, map[string]interface{}{"role": "admin"}}}, map[string]interface{}{"role": "admin"})
+	assert.Nil(t, err)
+	assert.True(t, access)
+	_, err = lp.CheckAccess(map[string]interface{}{"XOR": []interface{}{"TRUE", "FALSE", "TRUE", "FALSE", "TRUE", "FALSE"}}, map[string]interface{}{"role": "admin"})
+	assert.IsType(t, &LimitExceededError{}, err)
+	assert.EqualError(t, err, "The permissions exceed the maximum number of 5 nodes.")
+}
+
+func TestPolicyLimitsMaxCallbacks(t *testing.T) {
+	t.Parallel()
+	counter := &callCounter{calls: make(map[string]int)}
+	lp := newRoleLogicalPermissions(t, counter)
+	lp.SetPolicyLimits(PolicyLimits{MaxCallbacks: 3})
+	access, err := lp.CheckAccess(map[string]interface{}{"role": []interface{}{"a", "b", "admin"}}, map[string]interface{}{"role": "admin"})
+	assert.Nil(t, err)
+	assert.True(t, access)
+	access, err = lp.CheckAccess(map[string]interface{}{"role": []interface{}{"a", "b", "c", "admin"}}, map[string]interface{}{"role": "admin"})
+	assert.False(t, access)
+	assert.IsType(t, &LimitExceededError{}, err)
+	assert.EqualError(t, err, "Error checking access: The access check exceeds the maximum number of 3 callback calls.")
+	assert.Equal(t, 1, counter.get("role:admin"))
+	assert.Equal(t, 1, counter.get("role:c"))
+
+	// The error policies do not apply to the limit.
+	lp.SetErrorPolicy(ErrorPolicyFalse)
+	lp.SetContinueOnError(true)
+	_, err = lp.CheckAccess(map[string]interface{}{"role": []interface{}{"a", "b", "c", "admin"}}, map[string]interface{}{"role": "admin"})
+	assert.IsType(t, &LimitExceededError{}, err)
+
+	parallel := newRoleLogicalPermissions(t, counter)
+	parallel.SetParallelism(4)
+	parallel.SetPolicyLimits(PolicyLimits{MaxCallbacks: 3})
+	_, err = parallel.CheckAccess(map[string]interface{}{"AND": []interface{}{"TRUE", map[string]interface{}{"role": []interface{}{"a", "b", "c", "d"}}}}, map[string]interface{}{"role": "admin"})
+	assert.IsType(t, &LimitExceededError{}, err)
+}
+
+func TestPolicyLimitsMaxInputSize(t *testing.T) {
+	t.Parallel()
+	counter := &callCounter{calls: make(map[string]int)}
+	lp := newRoleLogicalPermissions(t, counter)
+	lp.SetPolicyLimits(PolicyLimits{MaxInputSize: 64})
+	access, err := lp.CheckAccess(`{"role": "admin"}`, map[string]interface{}{"role": "admin"})
+	assert.Nil(t, err)
+	assert.True(t, access)
+	_, err = lp.CheckAccess(`{"role": "`+strings.Repeat("x", 64)+`"}`, map[string]interface{}{"role": "admin"})
+	assert.IsType(t, &LimitExceededError{}, err)
+	assert.EqualError(t, err, "The permissions exceed the maximum input size of 64 bytes. Current size: 76")
+	_, err = lp.CheckAccess(map[string]interface{}{"role": strings.Repeat("x", 64)}, map[string]interface{}{"role": "admin"})
+	assert.IsType(t, &LimitExceededError{}, err)
+}
@@ -0,0 +1,231 @@
+package logicalpermissions
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Kinds of changes in a ReplayReport.
+const (
+	ReplayGrantToDeny  = "grant-to-deny"
+	ReplayDenyToGrant  = "deny-to-grant"
+	ReplayInconclusive = "inconclusive"
+)
+
+// ReplayChange is a recorded decision whose result changes with the candidate
+// permission tree, or whose result cannot be determined because the candidate
+// needs information that was not recorded.
+type ReplayChange struct {
+	Record *DecisionRecord `json:"record"`
+	Kind   string          `json:"kind"`
+	Access bool            `json:"access"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// ReplayReport summarizes a replay of recorded decisions. Inconclusive counts
+// the decisions that could not be replayed because the candidate needs
+// information that was not recorded.
+type ReplayReport struct {
+	Replayed     int            `json:"replayed"`
+	Skipped      int            `json:"skipped"`
+	Unchanged    int            `json:"unchanged"`
+	GrantToDeny  int            `json:"grant_to_deny"`
+	DenyToGrant  int            `json:"deny_to_grant"`
+	Inconclusive int            `json:"inconclusive"`
+	Changes      []ReplayChange `json:"changes"`
+}
+
+// ReadDecisionRecords reads json lines as written by WriterSink and RotatingFileSink.
+func ReadDecisionRecords(reader io.Reader) ([]*DecisionRecord, error) {
+	records := []*DecisionRecord{}
+	buffered := bufio.NewReader(reader)
+	line_number := 0
+	for {
+		// Records can be longer than the maximum token size of bufio.Scanner.
+		line, err := buffered.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		line_number++
+		if trimmed := strings.TrimSpace(line); trimmed != "" {
+			record := &DecisionRecord{}
+			if err := json.Unmarshal([]byte(trimmed), record); err != nil {
+				return nil, &InvalidArgumentValueError{CustomError{fmt.Sprintf("Error parsing decision record on line %d: %s", line_number, err)}}
+			}
+			records = append(records, record)
+		}
+		if err == io.EOF {
+			return records, nil
+		}
+	}
+}
+
+// ReplayDecisions evaluates the candidate permission trees against recorded
+// decisions with the default settings. Use LogicalPermissions::ReplayDecisions()
+// in order to replay them with the settings of the LogicalPermissions that made
+// the decisions.
+func ReplayDecisions(records []*DecisionRecord, candidates map[string]interface{}) *ReplayReport {
+	lp := &LogicalPermissions{}
+	return lp.ReplayDecisions(records, candidates)
+}
+
+// ReplayDecisions evaluates the candidate permission trees against recorded
+// decisions. The candidates are keyed by policy ID, and records without a
+// matching candidate are skipped. Permission type callbacks and the callbacks
+// of bypasses are answered from the record instead of being called. If a
+// candidate needs a callback result that was not recorded, its evaluation fails
+// with a RecordedResultMissingError. Only the permission types and named
+// bypasses that occur in the records are registered, and the evaluation of a
+// candidate that needs others fails with a PermissionTypeNotRegisteredError or
+// a BypassNotRegisteredError. Such decisions are inconclusive and are neither
+// counted as unchanged nor as changed. The candidates are evaluated with the
+// error policies, continuing on errors, strict mode, three-valued mode and the
+// policy limits of this LogicalPermissions.
+func (this *LogicalPermissions) ReplayDecisions(records []*DecisionRecord, candidates map[string]interface{}) *ReplayReport {
+	report := &ReplayReport{Changes: []ReplayChange{}}
+	types := []string{}
+	seen_types := make(map[string]bool)
+	for _, record := range records {
+		for _, leaf := range record.Leaves {
+			if !seen_types[leaf.Type] {
+				seen_types[leaf.Type] = true
+				types = append(types, leaf.Type)
+			}
+		}
+	}
+	for _, record := range records {
+		permissions, ok := candidates[record.PolicyID]
+		if !ok || record.PolicyID == "" {
+			report.Skipped++
+			continue
+		}
+		report.Replayed++
+
+		access, err := this.replayDecision(record, permissions, types)
+		if isReplayInconclusive(err) {
+			report.Inconclusive++
+			report.Changes = append(report.Changes, ReplayChange{Record: record, Kind: ReplayInconclusive, Error: err.Error()})
+			continue
+		}
+		recorded_access := record.Access && record.Error == ""
+		candidate_access := access && err == nil
+		if recorded_access == candidate_access {
+			report.Unchanged++
+			continue
+		}
+		change := ReplayChange{Record: record, Kind: ReplayDenyToGrant, Access: access}
+		if recorded_access {
+			change.Kind = ReplayGrantToDeny
+			report.GrantToDeny++
+		} else {
+			report.DenyToGrant++
+		}
+		if err != nil {
+			change.Error = err.Error()
+		}
+		report.Changes = append(report.Changes, change)
+	}
+	return report
+}
+
+// isReplayInconclusive returns whether an error returned by replayDecision is
+// caused by information that was not recorded.
+func isReplayInconclusive(err error) bool {
+	switch err.(type) {
+	case *RecordedResultMissingError, *PermissionTypeNotRegisteredError, *BypassNotRegisteredError:
+		return true
+	}
+	return false
+}
+
+// getRecordedError restores the error of a DecisionLeaf with its error type.
+func getRecordedError(leaf DecisionLeaf) error {
+	custom_error := CustomError{leaf.Error}
+	switch leaf.ErrorType {
+	case leafErrorUnknown:
+		return ErrUnknown
+	case leafErrorPanic:
+		return &CallbackPanicError{CustomError: custom_error, Type: leaf.Type, Permission: leaf.Permission}
+	case leafErrorTimeout:
+		return &CallbackTimeoutError{custom_error}
+	case leafErrorConcurrencyLimit:
+		return &CallbackConcurrencyLimitError{custom_error}
+	case leafErrorCircuitOpen:
+		return &CircuitOpenError{custom_error}
+	}
+	return &custom_error
+}
+
+// replayDecision evaluates a candidate permission tree with the callback
+// results of a record and the settings of this LogicalPermissions. A
+// RecordedResultMissingError is returned if the evaluation fails because a
+// callback result was not recorded. The evaluation is also three-valued if the
+// recorded result is unknown or a recorded callback returned ErrUnknown.
+func (this *LogicalPermissions) replayDecision(record *DecisionRecord, permissions interface{}, types []string) (bool, error) {
+	leaves := make(map[string]DecisionLeaf)
+	three_valued := record.Unknown
+	for _, leaf := range record.Leaves {
+		leaves[leaf.Type+"\x00"+leaf.Permission] = leaf
+		if leaf.ErrorType == leafErrorUnknown {
+			three_valued = true
+		}
+	}
+	var missing error
+	lp := LogicalPermissions{
+		error_policy:      this.error_policy,
+		continue_on_error: this.continue_on_error,
+		strict:            this.strict,
+		policy_limits:     this.policy_limits,
+	}
+	lp.SetThreeValued(this.three_valued || three_valued)
+	for _, name := range types {
+		permtype := name
+		lp.AddType(permtype, func(permission string, context map[string]interface{}) (bool, error) {
+			leaf, ok := leaves[permtype+"\x00"+permission]
+			if !ok {
+				missing = &RecordedResultMissingError{CustomError{fmt.Sprintf("No result was recorded for the permission \"%s\" of the permission type \"%s\".", permission, permtype)}}
+				return false, missing
+			}
+			if leaf.Error != "" {
+				return false, getRecordedError(leaf)
+			}
+			return leaf.Access, nil
+		})
+		if policy, ok := this.type_policies[permtype]; ok {
+			lp.SetTypeErrorPolicy(permtype, policy)
+		}
+	}
+	if record.BypassChecked {
+		lp.SetBypassCallback(func(context map[string]interface{}) (bool, error) {
+			return record.BypassUsed, nil
+		})
+	} else if record.BypassRegistered {
+		message := "No result was recorded for the bypass callback, because it was not checked."
+		if record.Cached {
+			message = "No result was recorded for the bypass callback, because the decision was cached."
+		}
+		lp.SetBypassCallback(func(context map[string]interface{}) (bool, error) {
+			missing = &RecordedResultMissingError{CustomError{message}}
+			return false, missing
+		})
+	}
+	for name, used := range record.Bypasses {
+		bypass_used := used
+		lp.AddBypass(name, func(context map[string]interface{}) (bool, error) {
+			return bypass_used, nil
+		})
+	}
+
+	check := lp.CheckAccessNoBypass
+	if record.AllowBypass {
+		check = lp.CheckAccess
+	}
+	access, err := check(permissions, record.Context)
+	if err != nil && missing != nil {
+		return false, missing
+	}
+	return access, err
+}
@@ -0,0 +1,208 @@
+package logicalpermissions_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/ordermind/logical-permissions-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadDecisionRecords(t *testing.T) {
+	t.Parallel()
+	records, err := ReadDecisionRecords(strings.NewReader("{\"check_id\": 1}\n\n{\"check_id\": 2}\n"))
+	assert.Nil(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, uint64(2), records[1].CheckID)
+
+	_, err = ReadDecisionRecords(strings.NewReader("{\"check_id\": 1}\nnot json\n"))
+	assert.IsType(t, &InvalidArgumentValueError{}, err)
+	assert.Contains(t, err.Error(), "line 2")
+}
+
+func TestReplayDecisions(t *testing.T) {
+	t.Parallel()
+	// Record live decisions.
+	lp := LogicalPermissions{}
+	lp.AddType("role", func(role string, context map[string]interface{}) (bool, error) {
+		if role == "broken" {
+			return false, errors.New("broken role")
+		}
+		return context["role"] == role, nil
+	})
+	lp.AddType("flag", func(flag string, context map[string]interface{}) (bool, error) {
+		return flag == "beta", nil
+	})
+	lp.SetBypassCallback(func(context map[string]interface{}) (bool, error) {
+		return context["role"] == "superuser", nil
+	})
+	var buffer bytes.Buffer
+	logger := NewDecisionLogger(NewWriterSink(&buffer))
+	logger.PolicyID = func(permissions interface{}, context map[string]interface{}) string {
+		return context["policy"].(string)
+	}
+	lp.AddObserver(logger)
+	live := map[string]interface{}{
+		"edit":   map[string]interface{}{"role": []interface{}{"admin", "editor"}},
+		"delete": map[string]interface{}{"role": "admin"},
+		"beta":   map[string]interface{}{"flag": "beta"},
+		"broken": map[string]interface{}{"role": "broken"},
+	}
+	contexts := []map[string]interface{}{
+		{"policy": "edit", "role": "admin"},
+		{"policy": "edit", "role": "editor"},
+		{"policy": "edit", "role": "viewer"},
+		{"policy": "delete", "role": "admin"},
+		{"policy": "delete", "role": "superuser"},
+		{"policy": "beta", "role": "viewer"},
+		{"policy": "broken", "role": "viewer"},
+	}
+	for _, context := range contexts {
+		if context["policy"] == "delete" && context["role"] != "superuser" {
+			lp.CheckAccessNoBypass(live[context["policy"].(string)], context)
+		} else {
+			lp.CheckAccess(live[context["policy"].(string)], context)
+		}
+	}
+	records, err := ReadDecisionRecords(&buffer)
+	assert.Nil(t, err)
+	assert.Len(t, records, len(contexts))
+
+	// Replaying the live permission trees changes nothing.
+	report := ReplayDecisions(records, live)
+	assert.Equal(t, &ReplayReport{Replayed: 7, Unchanged: 7, Changes: []ReplayChange{}}, report)
+
+	candidates := map[string]interface{}{
+		"edit":   map[string]interface{}{"OR": []interface{}{map[string]interface{}{"role": "admin"}, map[string]interface{}{"NOT": map[string]interface{}{"role": "editor"}}}},
+		"delete": map[string]interface{}{"NO_BYPASS": true, "role": "owner"},
+		"broken": map[string]interface{}{"OR": []interface{}{true, map[string]interface{}{"role": "broken"}}},
+	}
+	report = ReplayDecisions(records, candidates)
+	assert.Equal(t, 6, report.Replayed)
+	assert.Equal(t, 1, report.Skipped)
+	assert.Equal(t, 1, report.Unchanged)
+	assert.Equal(t, 1, report.GrantToDeny)
+	assert.Equal(t, 2, report.DenyToGrant)
+	assert.Equal(t, 2, report.Inconclusive)
+	kinds := []string{}
+	for _, change := range report.Changes {
+		kinds = append(kinds, change.Kind+" "+change.Record.PolicyID+" "+change.Record.Context["role"].(string))
+	}
+	assert.Equal(t, []string{
+		"grant-to-deny edit editor",
+		"deny-to-grant edit viewer",
+		"inconclusive delete admin",
+		"inconclusive delete superuser",
+		"deny-to-grant broken viewer",
+	}, kinds)
+
+	// Callback results that were not recorded make the decision inconclusive.
+	assert.Equal(t, "", report.Changes[0].Error)
+	assert.Contains(t, report.Changes[2].Error, "No result was recorded for the permission \"owner\"")
+
+	// The recorded bypass access is disallowed by the candidate.
+	assert.True(t, report.Changes[3].Record.BypassUsed)
+	assert.Contains(t, report.Changes[3].Error, "No result was recorded")
+
+	// The candidate short-circuits before the recorded error.
+	assert.True(t, report.Changes[4].Access)
+	assert.Equal(t, "", report.Changes[4].Error)
+}
+
+func TestReplayDecisionsInconclusive(t *testing.T) {
+	t.Parallel()
+	records := []*DecisionRecord{
+		{PolicyID: "edit", Access: false, Leaves: []DecisionLeaf{{Type: "role", Permission: "admin", Access: false}}},
+	}
+
+	// A recorded denial is not unchanged if the candidate cannot be evaluated.
+	report := ReplayDecisions(records, map[string]interface{}{"edit": map[string]interface{}{"role": "owner"}})
+	assert.Equal(t, 0, report.Unchanged)
+	assert.Equal(t, 1, report.Inconclusive)
+	assert.Equal(t, ReplayInconclusive, report.Changes[0].Kind)
+	assert.Contains(t, report.Changes[0].Error, "No result was recorded for the permission \"owner\"")
+
+	report = ReplayDecisions(records, map[string]interface{}{"edit": map[string]interface{}{"flag": "beta"}})
+	assert.Equal(t, 1, report.Inconclusive)
+	assert.Contains(t, report.Changes[0].Error, "The permission type \"flag\" has not been registered")
+
+	// Other errors deny access like they would with live callbacks.
+	report = ReplayDecisions(records, map[string]interface{}{"edit": map[string]interface{}{"AND": "admin"}})
+	assert.Equal(t, 1, report.Unchanged)
+	assert.Equal(t, 0, report.Inconclusive)
+}
+
+func TestReplayDecisionsErrorTypes(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	lp.SetThreeValued(true)
+	lp.AddType("flag", func(flag string, context map[string]interface{}) (bool, error) {
+		if flag == "maybe" {
+			return false, ErrUnknown
+		}
+		return false, errors.New("broken flag")
+	})
+	var buffer bytes.Buffer
+	logger := NewDecisionLogger(NewWriterSink(&buffer))
+	logger.PolicyID = func(permissions interface{}, context map[string]interface{}) string {
+		return "flag"
+	}
+	lp.AddObserver(logger)
+	lp.CheckAccess(map[string]interface{}{"flag": []interface{}{"maybe", "broken"}}, map[string]interface{}{})
+	records, err := ReadDecisionRecords(&buffer)
+	assert.Nil(t, err)
+	assert.Equal(t, "unknown", records[0].Leaves[0].ErrorType)
+	assert.Equal(t, "", records[0].Leaves[1].ErrorType)
+
+	// The recorded ErrUnknown is still unknown, so the candidate grants access.
+	report := ReplayDecisions(records, map[string]interface{}{
+		"flag": map[string]interface{}{"OR": []interface{}{map[string]interface{}{"flag": "maybe"}, true}},
+	})
+	assert.Equal(t, 1, report.DenyToGrant)
+	assert.Equal(t, "", report.Changes[0].Error)
+
+	// The recorded error is still an error.
+	report = ReplayDecisions(records, map[string]interface{}{
+		"flag": map[string]interface{}{"OR": []interface{}{map[string]interface{}{"flag": "broken"}, true}},
+	})
+	assert.Equal(t, 1, report.Unchanged)
+}
+
+func TestReplayDecisionsSettings(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	lp.AddType("role", func(role string, context map[string]interface{}) (bool, error) {
+		return false, errors.New("broken role")
+	})
+	lp.SetTypeErrorPolicy("role", ErrorPolicyTrue)
+	lp.SetBypassCallback(func(context map[string]interface{}) (bool, error) {
+		return true, nil
+	})
+	var buffer bytes.Buffer
+	logger := NewDecisionLogger(NewWriterSink(&buffer))
+	logger.PolicyID = func(permissions interface{}, context map[string]interface{}) string {
+		return "edit"
+	}
+	lp.AddObserver(logger)
+	live := map[string]interface{}{"edit": map[string]interface{}{"NO_BYPASS": true, "role": "admin"}}
+	access, err := lp.CheckAccess(live["edit"], map[string]interface{}{})
+	assert.Nil(t, err)
+	assert.True(t, access)
+	records, err := ReadDecisionRecords(&buffer)
+	assert.Nil(t, err)
+	assert.True(t, records[0].BypassRegistered)
+	assert.False(t, records[0].BypassChecked)
+
+	// The error policies of the LogicalPermissions apply to the replay.
+	assert.Equal(t, 1, ReplayDecisions(records, live).GrantToDeny)
+	report := lp.ReplayDecisions(records, live)
+	assert.Equal(t, 1, report.Unchanged)
+
+	// The bypass callback was registered but not checked, so a candidate that
+	// allows bypass access is inconclusive.
+	report = lp.ReplayDecisions(records, map[string]interface{}{"edit": map[string]interface{}{"role": "admin"}})
+	assert.Equal(t, 1, report.Inconclusive)
+	assert.Contains(t, report.Changes[0].Error, "No result was recorded for the bypass callback, because it was not checked.")
+}
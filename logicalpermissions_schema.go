@@ -0,0 +1,194 @@
+package logicalpermissions
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+func (this *LogicalPermissions) GetJSONSchema() map[string]interface{} {
+	type_names := this.getSortedTypeNames()
+	type_names_enum := make([]interface{}, len(type_names))
+	for i, name := range type_names {
+		type_names_enum[i] = name
+	}
+
+	definitions := map[string]interface{}{
+		"booleanPermission": map[string]interface{}{
+			"description": "A boolean permission. Both real booleans and boolean strings are accepted.",
+			"anyOf": []interface{}{
+				map[string]interface{}{"type": "boolean"},
+				map[string]interface{}{"type": "string", "pattern": fmt.Sprintf("^(%s|%s)$", this.getCaseInsensitivePattern("TRUE"), this.getCaseInsensitivePattern("FALSE"))},
+			},
+		},
+		"typeName": map[string]interface{}{
+			"description": "The names of the registered permission types.",
+			"type":        "string",
+			"enum":        type_names_enum,
+		},
+		"noBypass": map[string]interface{}{
+			"description": "Disallows access bypass for the other permissions of its map, either unconditionally, if the nested permissions grant access, or for the listed bypasses. In a list, \"*\" refuses all bypasses and a name prefixed with \"!\" accepts a named bypass, which only applies where it is accepted.",
+			"anyOf": []interface{}{
+				map[string]interface{}{"$ref": "#/definitions/booleanPermission"},
+				map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string", "pattern": "^(\\*|!?[^!*].*)$"}},
+				map[string]interface{}{"$ref": "#/definitions/permissionMap"},
+			},
+		},
+		"permission": map[string]interface{}{
+			"anyOf": []interface{}{
+				map[string]interface{}{"$ref": "#/definitions/booleanPermission"},
+				map[string]interface{}{"type": "array", "items": map[string]interface{}{"$ref": "#/definitions/permission"}},
+				map[string]interface{}{"$ref": "#/definitions/permissionMap"},
+			},
+		},
+		"typedPermission": map[string]interface{}{
+			"description": "A permission that is placed as a descendant to a permission type.",
+			"anyOf": []interface{}{
+				map[string]interface{}{"type": "string", "minLength": 1, "not": map[string]interface{}{"$ref": "#/definitions/booleanPermission"}},
+				map[string]interface{}{"type": "array", "items": map[string]interface{}{"$ref": "#/definitions/typedPermission"}},
+				map[string]interface{}{"$ref": "#/definitions/typedPermissionMap"},
+			},
+		},
+		"permissionMap":      this.getSchemaPermissionMap("permission", type_names),
+		"typedPermissionMap": this.getSchemaPermissionMap("typedPermission", nil),
+	}
+	for _, prefix := range []string{"", "typed"} {
+		item := "permission"
+		if prefix != "" {
+			item = "typedPermission"
+		}
+		for _, gate := range this.getGateKeys() {
+			definitions[this.getSchemaGateDefinitionName(prefix, gate)] = this.getSchemaGate(gate, item)
+		}
+	}
+
+	return map[string]interface{}{
+		"$schema":     "http://json-schema.org/draft-07/schema#",
+		"title":       "Logical permissions",
+		"description": "A permission tree that can be evaluated by LogicalPermissions::CheckAccess().",
+		"anyOf": []interface{}{
+			map[string]interface{}{"$ref": "#/definitions/booleanPermission"},
+			map[string]interface{}{"type": "array", "minItems": 1, "items": map[string]interface{}{"$ref": "#/definitions/permission"}},
+			this.getSchemaPermissionMap("permission", type_names),
+		},
+		"definitions": definitions,
+	}
+}
+
+func (this *LogicalPermissions) getSortedTypeNames() []string {
+	types := this.GetTypes()
+	type_names := make([]string, 0, len(types))
+	for name := range types {
+		type_names = append(type_names, name)
+	}
+	sort.Strings(type_names)
+	return type_names
+}
+
+func (this *LogicalPermissions) getGateKeys() []string {
+	return []string{"AND", "NAND", "OR", "NOR", "XOR", "NOT"}
+}
+
+func (this *LogicalPermissions) getSchemaGateDefinitionName(prefix string, gate string) string {
+	name := strings.ToLower(gate) + "Gate"
+	if prefix != "" {
+		name = prefix + strings.ToUpper(name[:1]) + name[1:]
+	}
+	return name
+}
+
+func (this *LogicalPermissions) getSchemaPermissionMap(item string, type_names []string) map[string]interface{} {
+	prefix := ""
+	if item == "typedPermission" {
+		prefix = "typed"
+	}
+	properties := make(map[string]interface{})
+	pattern_properties := map[string]interface{}{
+		"^[0-9]+$": map[string]interface{}{"$ref": "#/definitions/" + item},
+	}
+	for _, gate := range this.getGateKeys() {
+		ref := map[string]interface{}{"$ref": "#/definitions/" + this.getSchemaGateDefinitionName(prefix, gate)}
+		properties[gate] = ref
+		pattern_properties[fmt.Sprintf("^%s$", this.getCaseInsensitivePattern(gate))] = ref
+	}
+	for _, name := range type_names {
+		properties[name] = map[string]interface{}{"$ref": "#/definitions/typedPermission"}
+	}
+	schema := map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"patternProperties":    pattern_properties,
+		"additionalProperties": false,
+	}
+	if item == "permission" {
+		no_bypass := map[string]interface{}{"$ref": "#/definitions/noBypass"}
+		properties["NO_BYPASS"] = no_bypass
+		pattern_properties[fmt.Sprintf("^%s$", this.getCaseInsensitivePattern("NO_BYPASS"))] = no_bypass
+
+		// The property names repeat the allowed keys so that editors can
+		// suggest the names of the permission types.
+		patterns := make([]string, 0, len(pattern_properties))
+		for pattern := range pattern_properties {
+			patterns = append(patterns, pattern)
+		}
+		sort.Strings(patterns)
+		property_names := []interface{}{map[string]interface{}{"$ref": "#/definitions/typeName"}}
+		for _, pattern := range patterns {
+			property_names = append(property_names, map[string]interface{}{"pattern": pattern})
+		}
+		schema["propertyNames"] = map[string]interface{}{"anyOf": property_names}
+	}
+	return schema
+}
+
+func (this *LogicalPermissions) getSchemaGate(gate string, item string) map[string]interface{} {
+	map_ref := "#/definitions/permissionMap"
+	if item == "typedPermission" {
+		map_ref = "#/definitions/typedPermissionMap"
+	}
+	if gate == "NOT" {
+		string_value := map[string]interface{}{"type": "string", "minLength": 1}
+		if item == "permission" {
+			string_value = map[string]interface{}{"$ref": "#/definitions/booleanPermission"}
+		}
+		return map[string]interface{}{
+			"description": "A NOT gate must have either a non-empty string or a map with exactly one element as its value.",
+			"anyOf": []interface{}{
+				string_value,
+				map[string]interface{}{"allOf": []interface{}{map[string]interface{}{"$ref": map_ref}}, "minProperties": 1, "maxProperties": 1},
+			},
+		}
+	}
+
+	min_children := 1
+	if gate == "XOR" {
+		min_children = 2
+	}
+	return map[string]interface{}{
+		"description": fmt.Sprintf("The value of %s %s gate must be a slice or map with a minimum of %d element(s).", this.getIndefiniteArticle(gate), gate, min_children),
+		"anyOf": []interface{}{
+			map[string]interface{}{"type": "array", "minItems": min_children, "items": map[string]interface{}{"$ref": "#/definitions/" + item}},
+			map[string]interface{}{"allOf": []interface{}{map[string]interface{}{"$ref": map_ref}}, "minProperties": min_children},
+		},
+	}
+}
+
+func (this *LogicalPermissions) getCaseInsensitivePattern(key string) string {
+	pattern := ""
+	for _, char := range key {
+		if unicode.IsLetter(char) {
+			pattern += fmt.Sprintf("[%c%c]", unicode.ToUpper(char), unicode.ToLower(char))
+		} else {
+			pattern += string(char)
+		}
+	}
+	return pattern
+}
+
+func (this *LogicalPermissions) getIndefiniteArticle(gate string) string {
+	if this.stringInSlice(gate, []string{"AND", "OR", "XOR"}) {
+		return "an"
+	}
+	return "a"
+}
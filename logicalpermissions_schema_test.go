@@ -0,0 +1,94 @@
+package logicalpermissions_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	. "github.com/ordermind/logical-permissions-go"
+	"github.com/stretchr/testify/assert"
+)
+
+/*-------------LogicalPermissions::GetJSONSchema()--------------*/
+
+func TestGetJSONSchemaTypeNames(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	type_callback := func(string, map[string]interface{}) (bool, error) { return true, nil }
+	err := lp.AddType("role", type_callback)
+	if err != nil {
+		t.Error(fmt.Sprintf("LogicalPermissions::AddType() returned an error: %s", err))
+	}
+	err = lp.AddType("flag", type_callback)
+	if err != nil {
+		t.Error(fmt.Sprintf("LogicalPermissions::AddType() returned an error: %s", err))
+	}
+
+	schema := lp.GetJSONSchema()
+	definitions := schema["definitions"].(map[string]interface{})
+	type_name := definitions["typeName"].(map[string]interface{})
+	assert.Equal(t, []interface{}{"flag", "role"}, type_name["enum"])
+
+	permission_map := definitions["permissionMap"].(map[string]interface{})
+	properties := permission_map["properties"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"$ref": "#/definitions/typedPermission"}, properties["role"])
+	assert.Equal(t, map[string]interface{}{"$ref": "#/definitions/andGate"}, properties["AND"])
+	assert.Equal(t, map[string]interface{}{"$ref": "#/definitions/noBypass"}, properties["NO_BYPASS"])
+	property_names := permission_map["propertyNames"].(map[string]interface{})["anyOf"].([]interface{})
+	assert.Equal(t, map[string]interface{}{"$ref": "#/definitions/typeName"}, property_names[0])
+	assert.Contains(t, property_names, map[string]interface{}{"pattern": "^[0-9]+$"})
+	assert.Contains(t, property_names, map[string]interface{}{"pattern": "^[Aa][Nn][Dd]$"})
+
+	typed_permission_map := definitions["typedPermissionMap"].(map[string]interface{})
+	typed_properties := typed_permission_map["properties"].(map[string]interface{})
+	_, ok := typed_properties["role"]
+	assert.False(t, ok)
+	_, ok = typed_properties["NO_BYPASS"]
+	assert.False(t, ok)
+	_, ok = typed_permission_map["propertyNames"]
+	assert.False(t, ok)
+	assert.Equal(t, map[string]interface{}{"$ref": "#/definitions/typedAndGate"}, typed_properties["AND"])
+
+	lp.RemoveType("flag")
+	schema = lp.GetJSONSchema()
+	definitions = schema["definitions"].(map[string]interface{})
+	type_name = definitions["typeName"].(map[string]interface{})
+	assert.Equal(t, []interface{}{"role"}, type_name["enum"])
+}
+
+func TestGetJSONSchemaGateMinimumChildren(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	schema := lp.GetJSONSchema()
+	definitions := schema["definitions"].(map[string]interface{})
+
+	and_gate := definitions["andGate"].(map[string]interface{})["anyOf"].([]interface{})
+	assert.Equal(t, 1, and_gate[0].(map[string]interface{})["minItems"])
+	assert.Equal(t, 1, and_gate[1].(map[string]interface{})["minProperties"])
+
+	xor_gate := definitions["xorGate"].(map[string]interface{})["anyOf"].([]interface{})
+	assert.Equal(t, 2, xor_gate[0].(map[string]interface{})["minItems"])
+	assert.Equal(t, 2, xor_gate[1].(map[string]interface{})["minProperties"])
+
+	not_gate := definitions["notGate"].(map[string]interface{})["anyOf"].([]interface{})
+	assert.Equal(t, 1, not_gate[1].(map[string]interface{})["minProperties"])
+	assert.Equal(t, 1, not_gate[1].(map[string]interface{})["maxProperties"])
+}
+
+func TestGetJSONSchemaNoBypass(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	schema := lp.GetJSONSchema()
+	root_map := schema["anyOf"].([]interface{})[2].(map[string]interface{})
+	root_properties := root_map["properties"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"$ref": "#/definitions/noBypass"}, root_properties["NO_BYPASS"])
+	root_pattern_properties := root_map["patternProperties"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"$ref": "#/definitions/noBypass"}, root_pattern_properties["^[Nn][Oo]_[Bb][Yy][Pp][Aa][Ss][Ss]$"])
+}
+
+func TestGetJSONSchemaMarshal(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	_, err := json.Marshal(lp.GetJSONSchema())
+	assert.Nil(t, err)
+}
@@ -0,0 +1,129 @@
+package logicalpermissions
+
+import (
+	"fmt"
+	"strings"
+)
+
+// getNoBypassKey returns the NO_BYPASS key of a permission map, in whatever
+// case it is written.
+func (this *LogicalPermissions) getNoBypassKey(map_permissions map[string]interface{}) (string, bool) {
+	for key := range map_permissions {
+		if strings.ToUpper(key) == "NO_BYPASS" {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// containsNoBypass reports whether permissions have a NO_BYPASS key at any
+// level.
+func (this *LogicalPermissions) containsNoBypass(permissions interface{}) bool {
+	if slice_permissions, ok := permissions.([]interface{}); ok {
+		for _, permission := range slice_permissions {
+			if this.containsNoBypass(permission) {
+				return true
+			}
+		}
+	} else if map_permissions, ok := permissions.(map[string]interface{}); ok {
+		for key, value := range map_permissions {
+			if strings.ToUpper(key) == "NO_BYPASS" || this.containsNoBypass(value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// getScopedPermissions returns the permissions that a NO_BYPASS key applies to,
+// which are the other keys of its map.
+func (this *LogicalPermissions) getScopedPermissions(map_permissions map[string]interface{}, no_bypass_key string) (map[string]interface{}, CustomErrorInterface) {
+	scoped := make(map[string]interface{}, len(map_permissions))
+	for key, value := range map_permissions {
+		if key != no_bypass_key {
+			scoped[key] = value
+		}
+	}
+	if len(scoped) == 0 {
+		return nil, &InvalidArgumentValueError{CustomError{fmt.Sprintf("The NO_BYPASS key must be placed in a permission map together with the permissions that it applies to. Evaluated permissions: %v", map_permissions)}}
+	}
+	return scoped, nil
+}
+
+// checkScopedValue evaluates the value of a gate, a permission type or a
+// numeric key with evaluate, which receives the value without its NO_BYPASS
+// key if it has one.
+func (this *LogicalPermissions) checkScopedValue(value interface{}, permtype string, context map[string]interface{}, eval *evaluation, evaluate func(permissions interface{}) (bool, CustomErrorInterface)) (bool, CustomErrorInterface) {
+	if map_value, ok := value.(map[string]interface{}); ok {
+		if key, ok := this.getNoBypassKey(map_value); ok {
+			return this.checkNoBypassScope(map_value, key, permtype, context, eval, evaluate)
+		}
+	}
+	return evaluate(value)
+}
+
+// checkNoBypassScope evaluates a permission map with a NO_BYPASS key below the
+// root of the permission tree. The NO_BYPASS key applies to the other keys of
+// the map, which are evaluated with evaluate. While bypass access applies, the
+// NO_BYPASS value is evaluated without it, and only the bypasses that the
+// NO_BYPASS value allows or accepts apply to the other keys. If the NO_BYPASS
+// value is unknown, none of them apply and the result is unknown unless the
+// other keys grant access without them, like for the NO_BYPASS key of the
+// root.
+func (this *LogicalPermissions) checkNoBypassScope(map_permissions map[string]interface{}, no_bypass_key string, permtype string, context map[string]interface{}, eval *evaluation, evaluate func(permissions interface{}) (bool, CustomErrorInterface)) (bool, CustomErrorInterface) {
+	if permtype != "" {
+		return false, &InvalidArgumentValueError{CustomError{fmt.Sprintf("The NO_BYPASS key cannot be placed below a permission type. Existing type: %s. Evaluated permissions: %v", permtype, map_permissions)}}
+	}
+	scoped, err_custom := this.getScopedPermissions(map_permissions, no_bypass_key)
+	if err_custom != nil {
+		return false, err_custom
+	}
+
+	node := eval.beginNode(TraceNodeNoBypass, "NO_BYPASS", "")
+	var no_bypass_unknown *unknownResult
+	if eval != nil && len(eval.bypass_available) > 0 {
+		bypass, bypass_available := eval.bypass, eval.bypass_available
+		eval.bypass, eval.bypass_available = nil, nil
+		defer func() {
+			eval.bypass, eval.bypass_available = bypass, bypass_available
+		}()
+		allowed, allowed_available, err_custom := this.getAllowedBypasses(map_permissions[no_bypass_key], bypass, bypass_available, context, eval)
+		if isUnknownResult(err_custom) && (len(bypass) > 0 || this.containsNoBypass(scoped)) {
+			no_bypass_unknown = err_custom.(*unknownResult)
+		} else if err_custom != nil && !isUnknownResult(err_custom) {
+			eval.endNode(node, false, err_custom)
+			return false, err_custom
+		}
+		eval.bypass, eval.bypass_available = allowed, allowed_available
+	}
+	access, err_custom := evaluate(scoped)
+	if no_bypass_unknown != nil && isUnknownResult(err_custom) {
+		err_custom = mergeUnknown(err_custom.(*unknownResult), no_bypass_unknown)
+	} else if no_bypass_unknown != nil && err_custom == nil && !access {
+		err_custom = no_bypass_unknown
+	}
+	eval.endNode(node, access, err_custom)
+	if err_custom != nil {
+		return false, err_custom
+	}
+	return access, nil
+}
+
+// bypassPermissions grants access to permissions that bypass access applies to
+// without evaluating them. The trace node holds the name of the first granted
+// bypass that applies.
+func (this *LogicalPermissions) bypassPermissions(permissions interface{}, eval *evaluation) bool {
+	eval.bypass_used = true
+	if map_permissions, ok := permissions.(map[string]interface{}); ok && len(map_permissions) == 1 {
+		for key := range map_permissions {
+			eval.pushPath(key)
+		}
+		defer eval.popPath()
+	}
+	node := eval.beginNode(TraceNodeBypass, "BYPASS", "")
+	if node != nil {
+		node.Value = eval.bypass[0]
+	}
+	eval.endNode(node, true, nil)
+	return true
+}
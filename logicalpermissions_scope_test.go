@@ -0,0 +1,240 @@
+package logicalpermissions_test
+
+import (
+	"testing"
+
+	. "github.com/ordermind/logical-permissions-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// contextValue is a permission callback that counts the permission and grants
+// it if context[permission] is true.
+func (this *callCounter) contextValue(permission string, context map[string]interface{}) (bool, error) {
+	this.add(permission)
+	value, _ := context[permission].(bool)
+	return value, nil
+}
+
+func superuserBypass(context map[string]interface{}) (bool, error) {
+	superuser, _ := context["superuser"].(bool)
+	return superuser, nil
+}
+
+/*-------------Scoped NO_BYPASS--------------*/
+
+func TestScopedNoBypass(t *testing.T) {
+	t.Parallel()
+	counter := &callCounter{calls: make(map[string]int)}
+	lp := LogicalPermissions{}
+	lp.AddType("role", counter.contextValue)
+	lp.AddType("flag", counter.contextValue)
+	lp.SetBypassCallback(superuserBypass)
+	permissions := `{"AND": [{"NO_BYPASS": true, "flag": "beta"}, {"role": "admin"}]}`
+
+	// The bypass applies to the admin role, but not to the beta flag.
+	access, err := lp.CheckAccess(permissions, map[string]interface{}{"superuser": true})
+	assert.Nil(t, err)
+	assert.False(t, access)
+	assert.Equal(t, 1, counter.get("beta"))
+	assert.Equal(t, 0, counter.get("admin"))
+	access, err = lp.CheckAccess(permissions, map[string]interface{}{"superuser": true, "beta": true})
+	assert.Nil(t, err)
+	assert.True(t, access)
+	assert.Equal(t, 0, counter.get("admin"))
+
+	// Without bypass access the whole permission tree is evaluated.
+	access, err = lp.CheckAccess(permissions, map[string]interface{}{"beta": true})
+	assert.Nil(t, err)
+	assert.False(t, access)
+	assert.Equal(t, 1, counter.get("admin"))
+	access, err = lp.CheckAccessNoBypass(permissions, map[string]interface{}{"superuser": true, "beta": true})
+	assert.Nil(t, err)
+	assert.False(t, access)
+	assert.Equal(t, 2, counter.get("admin"))
+
+	// A branch of an OR gate without NO_BYPASS is enough for the bypass.
+	access, err = lp.CheckAccess(`{"OR": [{"NO_BYPASS": true, "flag": "beta"}, {"role": "admin"}]}`, map[string]interface{}{"superuser": true})
+	assert.Nil(t, err)
+	assert.True(t, access)
+	assert.Equal(t, 2, counter.get("admin"))
+}
+
+func TestScopedNoBypassCondition(t *testing.T) {
+	t.Parallel()
+	counter := &callCounter{calls: make(map[string]int)}
+	lp := LogicalPermissions{}
+	lp.AddType("role", counter.contextValue)
+	lp.AddType("flag", counter.contextValue)
+	lp.SetBypassCallback(superuserBypass)
+	permissions := map[string]interface{}{"AND": map[string]interface{}{
+		"NO_BYPASS": map[string]interface{}{"flag": "locked"},
+		"role":      "editor",
+		"flag":      "beta",
+	}}
+
+	access, err := lp.CheckAccess(permissions, map[string]interface{}{"superuser": true})
+	assert.Nil(t, err)
+	assert.True(t, access)
+	assert.Equal(t, 1, counter.get("locked"))
+	assert.Equal(t, 0, counter.get("editor"))
+
+	access, err = lp.CheckAccess(permissions, map[string]interface{}{"superuser": true, "locked": true, "editor": true, "beta": true})
+	assert.Nil(t, err)
+	assert.True(t, access)
+	assert.Equal(t, 1, counter.get("editor"))
+	assert.Equal(t, 1, counter.get("beta"))
+
+	// A NO_BYPASS key that allows the bypass keeps it for nested permissions,
+	// except where they disallow it.
+	access, err = lp.CheckAccess(`{"OR": [{"NO_BYPASS": false, "AND": [{"role": "editor"}, {"NO_BYPASS": true, "flag": "beta"}]}]}`, map[string]interface{}{"superuser": true, "beta": true})
+	assert.Nil(t, err)
+	assert.True(t, access)
+	assert.Equal(t, 1, counter.get("editor"))
+	assert.Equal(t, 2, counter.get("beta"))
+}
+
+func TestScopedNoBypassNegation(t *testing.T) {
+	t.Parallel()
+	counter := &callCounter{calls: make(map[string]int)}
+	lp := LogicalPermissions{}
+	lp.AddType("role", counter.contextValue)
+	lp.AddType("flag", counter.contextValue)
+	lp.SetBypassCallback(superuserBypass)
+
+	// The bypass does not apply below a NOT gate that contains NO_BYPASS,
+	// where it would deny access.
+	access, err := lp.CheckAccess(`{"NOT": {"AND": [{"NO_BYPASS": false, "role": "guest"}, {"flag": "beta"}]}}`, map[string]interface{}{"superuser": true, "beta": true})
+	assert.Nil(t, err)
+	assert.True(t, access)
+	assert.Equal(t, 1, counter.get("guest"))
+
+	// A NOT gate without NO_BYPASS is bypassed as a whole.
+	access, err = lp.CheckAccess(`{"AND": [{"NOT": {"role": "banned"}}, {"NO_BYPASS": true, "flag": "beta"}]}`, map[string]interface{}{"superuser": true, "beta": true, "banned": true})
+	assert.Nil(t, err)
+	assert.True(t, access)
+	assert.Equal(t, 0, counter.get("banned"))
+}
+
+func TestScopedNoBypassUnknown(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	lp.AddType("flag", unknownFlag)
+	lp.SetBypassCallback(unknownBypass)
+	lp.SetThreeValued(true)
+
+	// An unknown scoped NO_BYPASS makes a granted bypass unknown, like the
+	// NO_BYPASS key of the root.
+	result, err := lp.CheckAccessTriState(`{"AND": [{"NO_BYPASS": {"flag": "maybe"}, "flag": "no"}, {"flag": "no"}]}`, map[string]interface{}{"bypass": "yes"})
+	assert.Nil(t, err)
+	assert.Equal(t, TriStateUnknown, result)
+	result, _ = lp.CheckAccessTriState(`{"AND": [{"NO_BYPASS": {"flag": "maybe"}, "flag": "yes"}, {"flag": "no"}]}`, map[string]interface{}{"bypass": "yes"})
+	assert.Equal(t, TriStateTrue, result)
+	result, _ = lp.CheckAccessTriState(`{"OR": [{"NO_BYPASS": {"flag": "maybe"}, "flag": "no"}, {"flag": "yes"}]}`, map[string]interface{}{"bypass": "yes"})
+	assert.Equal(t, TriStateTrue, result)
+	result, _ = lp.CheckAccessTriState(`{"AND": [{"NO_BYPASS": {"flag": "maybe"}, "flag": "no"}, {"flag": "yes"}]}`, map[string]interface{}{"bypass": "no"})
+	assert.Equal(t, TriStateFalse, result)
+
+	access, trace, err := lp.CheckAccessWithTrace(`{"AND": [{"NO_BYPASS": {"flag": "maybe"}, "flag": "no"}, {"flag": "no"}]}`, map[string]interface{}{"bypass": "yes"})
+	assert.Nil(t, err)
+	assert.False(t, access)
+	assert.True(t, trace.Unknown)
+	assert.True(t, trace.Root.Children[0].Children[0].Unknown)
+}
+
+func TestScopedNoBypassParallel(t *testing.T) {
+	t.Parallel()
+	counter := &callCounter{calls: make(map[string]int)}
+	lp := LogicalPermissions{}
+	lp.AddType("role", counter.contextValue)
+	lp.AddType("flag", counter.contextValue)
+	lp.SetBypassCallback(superuserBypass)
+	lp.SetParallelism(4)
+	metrics := NewMetrics(nil)
+	lp.SetMetrics(metrics)
+	access, err := lp.CheckAccess(`{"AND": [{"NO_BYPASS": true, "flag": "beta"}, {"role": "admin"}, {"role": "editor"}]}`, map[string]interface{}{"superuser": true, "beta": true})
+	assert.Nil(t, err)
+	assert.True(t, access)
+	assert.Equal(t, 0, counter.get("admin"))
+	assert.Equal(t, 0, counter.get("editor"))
+	assert.Contains(t, metrics.String(), `"bypass_grants":1`)
+}
+
+func TestScopedNoBypassErrors(t *testing.T) {
+	t.Parallel()
+	counter := &callCounter{calls: make(map[string]int)}
+	lp := LogicalPermissions{}
+	lp.AddType("role", counter.contextValue)
+	lp.AddType("flag", counter.contextValue)
+	lp.SetBypassCallback(superuserBypass)
+	tests := []struct {
+		permissions string
+		message     string
+	}{
+		{`{"role": {"NO_BYPASS": true, "0": "admin"}}`, "Error checking access: The NO_BYPASS key cannot be placed below a permission type. Existing type: role. Evaluated permissions: map[0:admin NO_BYPASS:true]"},
+		{`{"OR": [{"NO_BYPASS": true}, {"role": "admin"}]}`, "Error checking access: The NO_BYPASS key must be placed in a permission map together with the permissions that it applies to. Evaluated permissions: map[NO_BYPASS:true]"},
+		{`{"NO_BYPASS": {"NO_BYPASS": true, "role": "admin"}, "role": "editor"}`, "The NO_BYPASS permissions cannot have a NO_BYPASS key. Current value: map[NO_BYPASS:true role:admin]"},
+		{`{"OR": [{"NO_BYPASS": "maybe", "role": "admin"}]}`, "Error checking access: The NO_BYPASS value must be a boolean, a boolean string or a map. Current value: maybe"},
+	}
+	for _, test := range tests {
+		access, err := lp.CheckAccess(test.permissions, map[string]interface{}{"superuser": true})
+		assert.False(t, access)
+		assert.IsType(t, &InvalidArgumentValueError{}, err)
+		assert.EqualError(t, err, test.message)
+		assert.NotEmpty(t, lp.Lint(test.permissions))
+	}
+}
+
+func TestScopedNoBypassTrace(t *testing.T) {
+	t.Parallel()
+	counter := &callCounter{calls: make(map[string]int)}
+	lp := LogicalPermissions{}
+	lp.AddType("role", counter.contextValue)
+	lp.AddType("flag", counter.contextValue)
+	lp.SetBypassCallback(superuserBypass)
+	permissions := `{"AND": [{"NO_BYPASS": {"flag": "locked"}, "flag": "beta"}, {"role": "admin"}]}`
+	access, trace, err := lp.CheckAccessWithTrace(permissions, map[string]interface{}{"superuser": true, "locked": true, "beta": true})
+	assert.Nil(t, err)
+	assert.True(t, access)
+	assert.True(t, trace.BypassAccess)
+	assert.Nil(t, trace.NoBypass)
+	assert.Equal(t, `Result: true
+Bypass: granted
+Permissions:
+  OR []: true
+    AND [/AND]: true
+      NO_BYPASS [/AND/0]: true
+        OR [/AND/0/NO_BYPASS]: true
+          flag: "locked" [/AND/0/NO_BYPASS/flag]: true
+        flag: "beta" [/AND/0/flag]: true
+      BYPASS [/AND/1/role]: true
+`, trace.String())
+
+	mermaid, err := lp.ExportMermaid(permissions, trace)
+	assert.Nil(t, err)
+	assert.Contains(t, mermaid, "n3>\"NO_BYPASS\"]")
+	assert.Contains(t, mermaid, "class n0,n1,n2,n3,n5,n6,n7,n8 true")
+}
+
+func TestScopedNoBypassDescribe(t *testing.T) {
+	t.Parallel()
+	counter := &callCounter{calls: make(map[string]int)}
+	lp := LogicalPermissions{}
+	lp.AddType("role", counter.contextValue)
+	lp.AddType("flag", counter.contextValue)
+	lp.SetBypassCallback(superuserBypass)
+	description, err := lp.Describe(`{"OR": [{"NO_BYPASS": true, "role": "admin"}, {"role": "editor"}]}`)
+	assert.Nil(t, err)
+	assert.Equal(t, "Requires either role admin (which cannot be bypassed) or role editor.", description)
+	description, err = lp.Describe(`{"AND": [{"NO_BYPASS": {"flag": "locked"}, "OR": ["TRUE", {"role": "admin"}]}, {"flag": "beta"}]}`)
+	assert.Nil(t, err)
+	assert.Equal(t, "Requires (either always or role admin, which cannot be bypassed if flag locked) and flag beta.", description)
+	description, err = lp.Describe(`{"AND": [{"NO_BYPASS": false, "role": "admin"}, {"flag": "beta"}]}`)
+	assert.Nil(t, err)
+	assert.Equal(t, "Requires role admin and flag beta.", description)
+
+	diagnostics := lp.Lint(`{"AND": [{"NO_BYPASS": false, "role": "admin"}, {"flag": "beta"}]}`)
+	if assert.Len(t, diagnostics, 1) {
+		assert.Equal(t, "/AND/0/NO_BYPASS", diagnostics[0].Path)
+		assert.Equal(t, LintRuleNoBypassFalse, diagnostics[0].Rule)
+	}
+}
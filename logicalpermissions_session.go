@@ -0,0 +1,43 @@
+package logicalpermissions
+
+// Session memoizes the results of permission type callbacks and the bypass
+// callback across the access checks of a single request. Each callback is
+// called once per permission, and repeated permissions in the same permission
+// tree are also answered from the session. All access checks of a session must
+// therefore use context maps that describe the same request. Observers are
+// notified about memoized results as cached results, and metrics and tracers
+// are only notified about callbacks that are actually called.
+//
+// A session is safe for concurrent use, although concurrent access checks may
+// call a callback more than once for the same permission.
+type Session struct {
+	lp      *LogicalPermissions
+	results *callbackResults
+}
+
+// NewSession creates an evaluation session, typically at the start of a
+// request.
+func (this *LogicalPermissions) NewSession() *Session {
+	return &Session{lp: this, results: newCallbackResults()}
+}
+
+func (this *Session) CheckAccess(permissions interface{}, context map[string]interface{}) (bool, error) {
+	return this.lp.check(permissions, context, true, &evaluation{reuse_results: this.results})
+}
+
+func (this *Session) CheckAccessNoBypass(permissions interface{}, context map[string]interface{}) (bool, error) {
+	return this.lp.check(permissions, context, false, &evaluation{reuse_results: this.results})
+}
+
+func (this *Session) CheckAccessWithTrace(permissions interface{}, context map[string]interface{}) (bool, *Trace, error) {
+	return this.lp.checkAccessWithTrace(permissions, context, true, &evaluation{reuse_results: this.results})
+}
+
+func (this *Session) CheckAccessNoBypassWithTrace(permissions interface{}, context map[string]interface{}) (bool, *Trace, error) {
+	return this.lp.checkAccessWithTrace(permissions, context, false, &evaluation{reuse_results: this.results})
+}
+
+// Reset forgets all memoized callback results.
+func (this *Session) Reset() {
+	this.results.clear()
+}
@@ -0,0 +1,64 @@
+package logicalpermissions_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*-------------LogicalPermissions::NewSession()--------------*/
+
+func TestSessionCheckAccess(t *testing.T) {
+	t.Parallel()
+	counter := &callCounter{calls: make(map[string]int)}
+	lp := newRoleLogicalPermissions(t, counter)
+	observed := &callbackCounter{}
+	lp.AddObserver(observed)
+	session := lp.NewSession()
+	context := map[string]interface{}{"role": "editor"}
+
+	// Repeated permissions in the same permission tree are memoized.
+	permissions := map[string]interface{}{"OR": []interface{}{
+		map[string]interface{}{"AND": []interface{}{map[string]interface{}{"role": "admin"}, true}},
+		map[string]interface{}{"NOT": map[string]interface{}{"role": "admin"}},
+	}}
+	access, err := session.CheckAccess(permissions, context)
+	assert.Nil(t, err)
+	assert.True(t, access)
+	assert.Equal(t, 1, counter.get("role:admin"))
+
+	// Results are shared across access checks.
+	access, err = session.CheckAccessNoBypass(map[string]interface{}{"role": []interface{}{"admin", "editor"}}, context)
+	assert.Nil(t, err)
+	assert.True(t, access)
+	access, trace, err := session.CheckAccessWithTrace(map[string]interface{}{"role": "editor"}, context)
+	assert.Nil(t, err)
+	assert.True(t, access)
+	assert.True(t, trace.Root.Result)
+	assert.True(t, trace.BypassChecked)
+	_, trace, err = session.CheckAccessNoBypassWithTrace(map[string]interface{}{"role": "admin"}, context)
+	assert.Nil(t, err)
+	assert.False(t, trace.Result)
+	assert.Equal(t, 1, counter.get("role:admin"))
+	assert.Equal(t, 1, counter.get("role:editor"))
+	assert.Equal(t, 1, counter.get("bypass"))
+	assert.Equal(t, 2, observed.count)
+	assert.Equal(t, 4, observed.cached)
+
+	// Errors are memoized as well.
+	_, err = session.CheckAccessNoBypass(map[string]interface{}{"role": "broken"}, context)
+	assert.NotNil(t, err)
+	_, err = session.CheckAccessNoBypass(map[string]interface{}{"role": "broken"}, context)
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, counter.get("role:broken"))
+
+	// Other sessions and plain access checks are not affected.
+	lp.NewSession().CheckAccess(map[string]interface{}{"role": "admin"}, context)
+	lp.CheckAccess(map[string]interface{}{"role": "admin"}, context)
+	assert.Equal(t, 3, counter.get("role:admin"))
+
+	session.Reset()
+	session.CheckAccess(map[string]interface{}{"role": "admin"}, context)
+	assert.Equal(t, 4, counter.get("role:admin"))
+	assert.Equal(t, 4, counter.get("bypass"))
+}
@@ -0,0 +1,180 @@
+package logicalpermissions
+
+import (
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultMaxShadowChecks is the number of candidate evaluations that can run at
+// the same time unless another limit is set with
+// LogicalPermissions::SetMaxShadowChecks().
+const DefaultMaxShadowChecks = 64
+
+// ShadowObserver is notified about every evaluation of a candidate permission
+// tree in shadow mode. It is called from the goroutine that evaluates the
+// candidate, so it must be safe for concurrent use.
+type ShadowObserver interface {
+	OnShadowCheck(event ShadowEvent)
+}
+
+// ShadowEvent compares the live and the candidate result of an access check.
+// Mismatch is true if the results differ or if only one of them is an error.
+type ShadowEvent struct {
+	Live            interface{}
+	Candidate       interface{}
+	Context         map[string]interface{}
+	AllowBypass     bool
+	LiveAccess      bool
+	LiveErr         error
+	CandidateAccess bool
+	CandidateErr    error
+	Duration        time.Duration
+	Mismatch        bool
+}
+
+func (this *LogicalPermissions) GetShadowObserver() ShadowObserver {
+	return this.shadow_observer
+}
+
+func (this *LogicalPermissions) SetShadowObserver(observer ShadowObserver) {
+	this.shadow_observer = observer
+	this.shadow_slots = make(chan struct{}, this.GetMaxShadowChecks())
+}
+
+func (this *LogicalPermissions) GetMaxShadowChecks() int {
+	if this.max_shadow_checks == 0 {
+		return DefaultMaxShadowChecks
+	}
+	return this.max_shadow_checks
+}
+
+func (this *LogicalPermissions) SetMaxShadowChecks(max_shadow_checks int) error {
+	if max_shadow_checks < 0 {
+		return &InvalidArgumentValueError{CustomError{"The max_shadow_checks parameter cannot be negative."}}
+	}
+	this.max_shadow_checks = max_shadow_checks
+	this.shadow_slots = make(chan struct{}, this.GetMaxShadowChecks())
+	return nil
+}
+
+func (this *LogicalPermissions) GetDroppedShadowChecks() uint64 {
+	return atomic.LoadUint64(&this.shadow_dropped)
+}
+
+func (this *LogicalPermissions) CheckAccessShadow(live interface{}, candidate interface{}, context map[string]interface{}) (bool, error) {
+	return this.checkAccessShadow(live, candidate, context, true)
+}
+
+func (this *LogicalPermissions) CheckAccessNoBypassShadow(live interface{}, candidate interface{}, context map[string]interface{}) (bool, error) {
+	return this.checkAccessShadow(live, candidate, context, false)
+}
+
+// checkAccessShadow returns the result of the live permission tree and then
+// evaluates the candidate in a new goroutine, reusing the callback results of
+// the live evaluation. The candidate can neither change the live result nor
+// crash the program, because panics in its callbacks are recovered. The
+// goroutine works on copies of the permission trees and the context, so that
+// the caller may change them as soon as the live result is returned. If the
+// maximum number of candidate evaluations is already running, the candidate
+// is dropped and counted instead of being evaluated.
+func (this *LogicalPermissions) checkAccessShadow(live interface{}, candidate interface{}, context map[string]interface{}, allow_bypass bool) (bool, error) {
+	results := newCallbackResults()
+	access, err := this.check(live, context, allow_bypass, &evaluation{record_results: results})
+
+	observer := this.GetShadowObserver()
+	if observer != nil {
+		slots := this.shadow_slots
+		select {
+		case slots <- struct{}{}:
+		default:
+			atomic.AddUint64(&this.shadow_dropped, 1)
+			return access, err
+		}
+		live, candidate = copyValue(live), copyValue(candidate)
+		context, _ = copyValue(context).(map[string]interface{})
+		go func() {
+			defer func() { <-slots }()
+			event := ShadowEvent{Live: live, Candidate: candidate, Context: context, AllowBypass: allow_bypass, LiveAccess: access, LiveErr: err}
+			start := time.Now()
+			event.CandidateAccess, event.CandidateErr = this.checkShadowCandidate(candidate, context, allow_bypass, results)
+			event.Duration = time.Since(start)
+			event.Mismatch = event.LiveAccess != event.CandidateAccess || (event.LiveErr == nil) != (event.CandidateErr == nil)
+			observer.OnShadowCheck(event)
+		}()
+	}
+	return access, err
+}
+
+func (this *LogicalPermissions) checkShadowCandidate(candidate interface{}, context map[string]interface{}, allow_bypass bool, results *callbackResults) (access bool, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			access = false
+			err = &CustomError{fmt.Sprintf("Error checking access: The candidate evaluation panicked: %v", recovered)}
+		}
+	}()
+	return this.checkAccess(candidate, context, allow_bypass, &evaluation{reuse_results: results, shadow: true})
+}
+
+// copyValue copies the maps and slices of a permission tree or a context
+// recursively. Other values, such as pointers and structs, are not copied.
+func copyValue(value interface{}) interface{} {
+	switch typed_value := value.(type) {
+	case map[string]interface{}:
+		if typed_value == nil {
+			return typed_value
+		}
+		copied := make(map[string]interface{}, len(typed_value))
+		for key, element := range typed_value {
+			copied[key] = copyValue(element)
+		}
+		return copied
+	case []interface{}:
+		if typed_value == nil {
+			return typed_value
+		}
+		copied := make([]interface{}, len(typed_value))
+		for i, element := range typed_value {
+			copied[i] = copyValue(element)
+		}
+		return copied
+	}
+	reflect_value := reflect.ValueOf(value)
+	switch reflect_value.Kind() {
+	case reflect.Map:
+		if reflect_value.IsNil() {
+			return value
+		}
+		copied := reflect.MakeMap(reflect_value.Type())
+		for _, key := range reflect_value.MapKeys() {
+			copied.SetMapIndex(key, copyReflectValue(reflect_value.MapIndex(key)))
+		}
+		return copied.Interface()
+	case reflect.Slice:
+		if reflect_value.IsNil() {
+			return value
+		}
+		copied := reflect.MakeSlice(reflect_value.Type(), reflect_value.Len(), reflect_value.Len())
+		for i := 0; i < reflect_value.Len(); i++ {
+			copied.Index(i).Set(copyReflectValue(reflect_value.Index(i)))
+		}
+		return copied.Interface()
+	}
+	return value
+}
+
+// copyReflectValue copies an element of a map or a slice with copyValue(),
+// keeping the type of the element.
+func copyReflectValue(element reflect.Value) reflect.Value {
+	if element.Kind() == reflect.Interface && element.IsNil() {
+		return element
+	}
+	copied := reflect.ValueOf(copyValue(element.Interface()))
+	if element.Kind() == reflect.Interface {
+		typed := reflect.New(element.Type()).Elem()
+		typed.Set(copied)
+		return typed
+	}
+	return copied
+}
@@ -0,0 +1,224 @@
+package logicalpermissions_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/ordermind/logical-permissions-go"
+	"github.com/stretchr/testify/assert"
+)
+
+type channelShadowObserver struct {
+	events chan ShadowEvent
+}
+
+func (this *channelShadowObserver) OnShadowCheck(event ShadowEvent) {
+	this.events <- event
+}
+
+func (this *channelShadowObserver) next(t *testing.T) ShadowEvent {
+	select {
+	case event := <-this.events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("The shadow observer was not called.")
+	}
+	return ShadowEvent{}
+}
+
+type callCounter struct {
+	mutex sync.Mutex
+	calls map[string]int
+}
+
+func (this *callCounter) add(call string) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.calls[call]++
+}
+
+func (this *callCounter) get(call string) int {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.calls[call]
+}
+
+/*-------------LogicalPermissions::GetShadowObserver()--------------*/
+
+func TestGetShadowObserver(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	assert.Nil(t, lp.GetShadowObserver())
+	observer := &channelShadowObserver{}
+	lp.SetShadowObserver(observer)
+	assert.Equal(t, observer, lp.GetShadowObserver())
+	lp.SetShadowObserver(nil)
+	assert.Nil(t, lp.GetShadowObserver())
+}
+
+/*-------------LogicalPermissions::SetMaxShadowChecks()--------------*/
+
+func TestSetMaxShadowChecks(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	assert.Equal(t, DefaultMaxShadowChecks, lp.GetMaxShadowChecks())
+	err := lp.SetMaxShadowChecks(-1)
+	assert.IsType(t, &InvalidArgumentValueError{}, err)
+	assert.Nil(t, lp.SetMaxShadowChecks(2))
+	assert.Equal(t, 2, lp.GetMaxShadowChecks())
+	assert.Nil(t, lp.SetMaxShadowChecks(0))
+	assert.Equal(t, DefaultMaxShadowChecks, lp.GetMaxShadowChecks())
+}
+
+/*-------------LogicalPermissions::CheckAccessShadow()--------------*/
+
+func TestCheckAccessShadowWithoutObserver(t *testing.T) {
+	t.Parallel()
+	counter := &callCounter{calls: make(map[string]int)}
+	lp := newRoleLogicalPermissions(t, counter)
+	access, err := lp.CheckAccessShadow(map[string]interface{}{"role": "admin"}, map[string]interface{}{"role": "editor"}, map[string]interface{}{"role": "admin"})
+	assert.Nil(t, err)
+	assert.True(t, access)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, 0, counter.get("role:editor"))
+}
+
+func TestCheckAccessShadow(t *testing.T) {
+	t.Parallel()
+	counter := &callCounter{calls: make(map[string]int)}
+	lp := newRoleLogicalPermissions(t, counter)
+	observer := &channelShadowObserver{events: make(chan ShadowEvent, 1)}
+	lp.SetShadowObserver(observer)
+	observed := &callbackCounter{}
+	lp.AddObserver(observed)
+
+	live := map[string]interface{}{"role": []interface{}{"admin", "editor"}}
+	candidate := map[string]interface{}{"AND": []interface{}{map[string]interface{}{"role": "editor"}, map[string]interface{}{"NOT": map[string]interface{}{"role": "viewer"}}}}
+	context := map[string]interface{}{"role": "editor"}
+	access, err := lp.CheckAccessShadow(live, candidate, context)
+	assert.Nil(t, err)
+	assert.True(t, access)
+	event := observer.next(t)
+	assert.Equal(t, live, event.Live)
+	assert.Equal(t, candidate, event.Candidate)
+	assert.True(t, event.AllowBypass)
+	assert.True(t, event.LiveAccess)
+	assert.True(t, event.CandidateAccess)
+	assert.Nil(t, event.CandidateErr)
+	assert.False(t, event.Mismatch)
+
+	// The candidate reuses the results of the live evaluation, and only its
+	// own callbacks are called.
+	assert.Equal(t, 1, counter.get("bypass"))
+	assert.Equal(t, 1, counter.get("role:admin"))
+	assert.Equal(t, 1, counter.get("role:editor"))
+	assert.Equal(t, 1, counter.get("role:viewer"))
+	// Only the live evaluation is observed.
+	assert.Equal(t, 2, observed.count)
+
+	access, err = lp.CheckAccessShadow(live, candidate, map[string]interface{}{"role": "admin"})
+	assert.Nil(t, err)
+	assert.True(t, access)
+	event = observer.next(t)
+	assert.False(t, event.CandidateAccess)
+	assert.True(t, event.Mismatch)
+
+	access, err = lp.CheckAccessNoBypassShadow(map[string]interface{}{"role": "editor"}, map[string]interface{}{"role": "admin"}, map[string]interface{}{"role": "superuser"})
+	assert.Nil(t, err)
+	assert.False(t, access)
+	event = observer.next(t)
+	assert.False(t, event.AllowBypass)
+	assert.False(t, event.Mismatch)
+	assert.Equal(t, 2, counter.get("bypass"))
+}
+
+func TestCheckAccessShadowMutation(t *testing.T) {
+	t.Parallel()
+	counter := &callCounter{calls: make(map[string]int)}
+	lp := newRoleLogicalPermissions(t, counter)
+	observer := &channelShadowObserver{events: make(chan ShadowEvent, 1)}
+	lp.SetShadowObserver(observer)
+
+	// The caller may change the context and the permission trees as soon as
+	// the live result is returned.
+	live := map[string]interface{}{"role": []interface{}{"editor"}}
+	candidate := map[string]interface{}{"AND": []interface{}{map[string]interface{}{"role": "editor"}, map[string]interface{}{"NOT": map[string]interface{}{"role": "viewer"}}}}
+	context := map[string]interface{}{"role": "editor"}
+	access, err := lp.CheckAccessNoBypassShadow(live, candidate, context)
+	context["role"] = "viewer"
+	live["role"] = "viewer"
+	candidate["AND"].([]interface{})[0] = map[string]interface{}{"role": "admin"}
+	delete(candidate, "AND")
+	assert.Nil(t, err)
+	assert.True(t, access)
+	event := observer.next(t)
+	assert.Equal(t, "editor", event.Context["role"])
+	assert.Equal(t, map[string]interface{}{"role": []interface{}{"editor"}}, event.Live)
+	assert.True(t, event.CandidateAccess)
+	assert.False(t, event.Mismatch)
+}
+
+func TestCheckAccessShadowCandidateErrors(t *testing.T) {
+	t.Parallel()
+	counter := &callCounter{calls: make(map[string]int)}
+	lp := newRoleLogicalPermissions(t, counter)
+	observer := &channelShadowObserver{events: make(chan ShadowEvent, 1)}
+	lp.SetShadowObserver(observer)
+	context := map[string]interface{}{"role": "admin"}
+
+	access, err := lp.CheckAccessShadow(map[string]interface{}{"role": "admin"}, map[string]interface{}{"role": "broken"}, context)
+	assert.Nil(t, err)
+	assert.True(t, access)
+	event := observer.next(t)
+	assert.NotNil(t, event.CandidateErr)
+	assert.True(t, event.Mismatch)
+
+	access, err = lp.CheckAccessShadow(map[string]interface{}{"role": "admin"}, map[string]interface{}{"role": "panic"}, context)
+	assert.Nil(t, err)
+	assert.True(t, access)
+	event = observer.next(t)
+	assert.Contains(t, event.CandidateErr.Error(), "role panic")
+	assert.True(t, event.Mismatch)
+
+	access, err = lp.CheckAccessShadow(map[string]interface{}{"role": "admin"}, map[string]interface{}{"invalid": "admin"}, context)
+	assert.Nil(t, err)
+	assert.True(t, access)
+	event = observer.next(t)
+	assert.IsType(t, &PermissionTypeNotRegisteredError{}, event.CandidateErr)
+
+	// Errors in the live evaluation are returned as usual.
+	access, err = lp.CheckAccessShadow(map[string]interface{}{"role": "broken"}, map[string]interface{}{"role": "broken"}, context)
+	assert.NotNil(t, err)
+	assert.False(t, access)
+	event = observer.next(t)
+	assert.NotNil(t, event.LiveErr)
+	assert.False(t, event.Mismatch)
+	// The first candidate called the callback once and the last one reused the live error.
+	assert.Equal(t, 2, counter.get("role:broken"))
+}
+
+func TestCheckAccessShadowDropped(t *testing.T) {
+	t.Parallel()
+	counter := &callCounter{calls: make(map[string]int)}
+	lp := newRoleLogicalPermissions(t, counter)
+	// The observer blocks until its event is received, which keeps the
+	// candidate evaluation running.
+	observer := &channelShadowObserver{events: make(chan ShadowEvent)}
+	lp.SetShadowObserver(observer)
+	lp.SetMaxShadowChecks(1)
+	assert.Equal(t, uint64(0), lp.GetDroppedShadowChecks())
+
+	context := map[string]interface{}{"role": "admin"}
+	access, err := lp.CheckAccessShadow(map[string]interface{}{"role": "admin"}, map[string]interface{}{"role": "editor"}, context)
+	assert.Nil(t, err)
+	assert.True(t, access)
+	// The live result is still returned while candidates are dropped.
+	access, err = lp.CheckAccessShadow(map[string]interface{}{"role": "admin"}, map[string]interface{}{"role": "viewer"}, context)
+	assert.Nil(t, err)
+	assert.True(t, access)
+	assert.Equal(t, uint64(1), lp.GetDroppedShadowChecks())
+	event := observer.next(t)
+	assert.Equal(t, map[string]interface{}{"role": "editor"}, event.Candidate)
+	assert.Equal(t, 0, counter.get("role:viewer"))
+}
@@ -0,0 +1,194 @@
+package logicalpermissions
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func (this *LogicalPermissions) GetStrict() bool {
+	return this.strict
+}
+
+func (this *LogicalPermissions) SetStrict(strict bool) {
+	this.strict = strict
+}
+
+func getStrictPath(segments []string) string {
+	if len(segments) == 0 {
+		return "/"
+	}
+	escaper := strings.NewReplacer("~", "~0", "/", "~1")
+	escaped := make([]string, len(segments))
+	for i, segment := range segments {
+		escaped[i] = escaper.Replace(segment)
+	}
+	return "/" + strings.Join(escaped, "/")
+}
+
+// checkStrict checks a permission tree in strict mode. Core keys must be
+// uppercase, keys cannot be numeric, and a map with more than one key must be
+// the value of a gate rather than an implicit OR. A map can have a NO_BYPASS
+// key in addition to one other key. The tree is walked without
+// recursion, and keys are visited in sorted order so that the same error is
+// returned every time.
+func (this *LogicalPermissions) checkStrict(map_permissions map[string]interface{}) error {
+	type pending struct {
+		value interface{}
+		path  []string
+		// collection is true for the value of a gate, whose keys are the
+		// children of the gate.
+		collection bool
+	}
+	stack := []pending{{value: map_permissions}}
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if slice_value, ok := current.value.([]interface{}); ok {
+			for i := len(slice_value) - 1; i >= 0; i-- {
+				stack = append(stack, pending{value: slice_value[i], path: append(current.path[:len(current.path):len(current.path)], strconv.Itoa(i))})
+			}
+			continue
+		}
+		map_value, ok := current.value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		keys := make([]string, 0, len(map_value))
+		for key := range map_value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			path := getStrictPath(append(current.path[:len(current.path):len(current.path)], key))
+			if _, err := strconv.Atoi(key); err == nil {
+				return &InvalidArgumentValueError{CustomError{fmt.Sprintf("In strict mode keys cannot be numeric. Use a slice instead. Path: %s", path)}}
+			}
+			key_upper := strings.ToUpper(key)
+			if key != key_upper && this.stringInSlice(key_upper, this.getCorePermissionKeys()) {
+				return &InvalidArgumentValueError{CustomError{fmt.Sprintf("In strict mode the key \"%s\" must be written as \"%s\". Path: %s", key, key_upper, path)}}
+			}
+		}
+		implicit_keys := len(keys)
+		if _, ok := map_value["NO_BYPASS"]; ok {
+			implicit_keys--
+		}
+		if !current.collection && implicit_keys > 1 {
+			return &InvalidArgumentValueError{CustomError{fmt.Sprintf("In strict mode a map with more than one key must be the value of a gate. Use an explicit OR gate instead. Path: %s", getStrictPath(current.path))}}
+		}
+		for i := len(keys) - 1; i >= 0; i-- {
+			key := keys[i]
+			key_upper := strings.ToUpper(key)
+			collection := key_upper != "NOT" && this.stringInSlice(key_upper, this.getGateKeys())
+			stack = append(stack, pending{value: map_value[key], path: append(current.path[:len(current.path):len(current.path)], key), collection: collection})
+		}
+	}
+	return nil
+}
+
+// checkDuplicateKeys returns an error if an object in a JSON document has the
+// same key more than once, which encoding/json accepts by keeping the last
+// value. Syntax errors, including the end of the document, are left to
+// json.Unmarshal().
+func (this *LogicalPermissions) checkDuplicateKeys(json_permissions string) error {
+	type frame struct {
+		object     bool
+		keys       map[string]bool
+		expect_key bool
+		index      int
+	}
+	document := json_permissions
+	frames := []*frame{}
+	path := []string{}
+	// endValue updates the enclosing object or slice once a value has been read.
+	endValue := func() {
+		if len(frames) == 0 {
+			return
+		}
+		parent := frames[len(frames)-1]
+		path = path[:len(path)-1]
+		if parent.object {
+			parent.expect_key = true
+		} else {
+			parent.index++
+		}
+	}
+	for {
+		kind, token, rest, ok := nextJSONToken(document)
+		if !ok {
+			return nil
+		}
+		document = rest
+		if kind == '}' || kind == ']' {
+			if len(frames) == 0 {
+				return nil
+			}
+			frames = frames[:len(frames)-1]
+			endValue()
+			continue
+		}
+		var parent *frame
+		if len(frames) > 0 {
+			parent = frames[len(frames)-1]
+		}
+		if parent != nil && parent.object && parent.expect_key {
+			if kind != '"' {
+				return nil
+			}
+			key := token
+			if parent.keys[key] {
+				return &InvalidArgumentValueError{CustomError{fmt.Sprintf("In strict mode JSON objects cannot have duplicate keys. Path: %s", getStrictPath(append(path, key)))}}
+			}
+			parent.keys[key] = true
+			parent.expect_key = false
+			path = append(path, key)
+			continue
+		}
+		if parent != nil && !parent.object {
+			path = append(path, strconv.Itoa(parent.index))
+		}
+		if kind == '{' || kind == '[' {
+			frames = append(frames, &frame{object: kind == '{', keys: make(map[string]bool), expect_key: true})
+			continue
+		}
+		endValue()
+	}
+}
+
+// nextJSONToken returns the kind of the next token of a JSON document, its
+// value and the rest of the document. The kind is the delimiter for
+// delimiters, '"' for strings, whose value is unquoted, and 'v' for other
+// values. Commas and colons are skipped without validating them, and ok is
+// false at the end of the document or if a string is invalid.
+func nextJSONToken(document string) (byte, string, string, bool) {
+	document = strings.TrimLeft(document, " \t\r\n,:")
+	if document == "" {
+		return 0, "", "", false
+	}
+	switch document[0] {
+	case '{', '}', '[', ']':
+		return document[0], "", document[1:], true
+	case '"':
+		for i := 1; i < len(document); i++ {
+			if document[i] == '\\' {
+				i++
+				continue
+			}
+			if document[i] == '"' {
+				var value string
+				if err := json.Unmarshal([]byte(document[:i+1]), &value); err != nil {
+					return 0, "", "", false
+				}
+				return '"', value, document[i+1:], true
+			}
+		}
+		return 0, "", "", false
+	}
+	end := strings.IndexAny(document, " \t\r\n,:]}")
+	if end == -1 {
+		end = len(document)
+	}
+	return 'v', document[:end], document[end:], true
+}
@@ -0,0 +1,82 @@
+package logicalpermissions_test
+
+import (
+	"testing"
+
+	. "github.com/ordermind/logical-permissions-go"
+	"github.com/stretchr/testify/assert"
+)
+
+/*-------------LogicalPermissions::SetStrict()--------------*/
+
+func TestSetStrict(t *testing.T) {
+	t.Parallel()
+	lp := newRoleLogicalPermissions(t, nil)
+	lp.AddType("flag", func(flag string, context map[string]interface{}) (bool, error) {
+		return flag == "yes", nil
+	})
+	lp.SetStrict(true)
+	assert.True(t, lp.GetStrict())
+	lp.SetStrict(false)
+	assert.False(t, lp.GetStrict())
+
+	// Without strict mode the forms are accepted for backward compatibility.
+	access, err := lp.CheckAccess(`{"or": {"role": "editor", "flag": "yes"}, "no_bypass": false}`, map[string]interface{}{})
+	assert.Nil(t, err)
+	assert.True(t, access)
+}
+
+/*-------------Strict mode--------------*/
+
+func TestCheckAccessStrict(t *testing.T) {
+	t.Parallel()
+	lp := newRoleLogicalPermissions(t, nil)
+	lp.AddType("flag", func(flag string, context map[string]interface{}) (bool, error) {
+		return flag == "yes", nil
+	})
+	lp.SetStrict(true)
+	valid := []interface{}{
+		`{"NO_BYPASS": {"flag": "no"}, "OR": {"role": "editor", "flag": "yes"}}`,
+		`[{"role": "admin"}, {"flag": "yes"}]`,
+		`{"AND": [{"NO_BYPASS": true, "role": "admin"}, {"flag": "yes"}]}`,
+		map[string]interface{}{"AND": map[string]interface{}{"role": []interface{}{"admin"}, "NOT": map[string]interface{}{"flag": "no"}}},
+		"TRUE",
+		true,
+	}
+	for _, permissions := range valid {
+		access, err := lp.CheckAccess(permissions, map[string]interface{}{"role": "admin"})
+		assert.Nil(t, err, "Permissions: %v", permissions)
+		assert.True(t, access, "Permissions: %v", permissions)
+	}
+
+	tests := []struct {
+		permissions interface{}
+		err         string
+	}{
+		{`{"or": [{"role": "admin"}]}`, "In strict mode the key \"or\" must be written as \"OR\". Path: /or"},
+		{`{"no_bypass": true, "role": "admin"}`, "In strict mode the key \"no_bypass\" must be written as \"NO_BYPASS\". Path: /no_bypass"},
+		{`{"role": {"Not": "editor"}}`, "In strict mode the key \"Not\" must be written as \"NOT\". Path: /role/Not"},
+		{`{"OR": [{"flag": "yes"}, {"True": "x"}]}`, "In strict mode the key \"True\" must be written as \"TRUE\". Path: /OR/1/True"},
+		{`{"role": {"0": "admin", "1": "editor"}}`, "In strict mode keys cannot be numeric. Use a slice instead. Path: /role/0"},
+		{`{"role": {"NOT": "editor", "OR": ["admin"]}}`, "In strict mode a map with more than one key must be the value of a gate. Use an explicit OR gate instead. Path: /role"},
+		{`{"AND": {"0": {"role": "admin"}}}`, "In strict mode keys cannot be numeric. Use a slice instead. Path: /AND/0"},
+		{`{"role": "admin", "flag": "yes"}`, "In strict mode a map with more than one key must be the value of a gate. Use an explicit OR gate instead. Path: /"},
+		{`{"NO_BYPASS": {"role": "admin", "flag": "yes"}, "role": "admin"}`, "In strict mode a map with more than one key must be the value of a gate. Use an explicit OR gate instead. Path: /NO_BYPASS"},
+		{`[{"role": "admin", "flag": "yes"}]`, "In strict mode a map with more than one key must be the value of a gate. Use an explicit OR gate instead. Path: /OR/0"},
+		{`{"role": "admin", "role": "editor"}`, "In strict mode JSON objects cannot have duplicate keys. Path: /role"},
+		{`{"AND": [{"flag": "yes"}, {"OR": {"role": "admin", "flag": "no", "role": "x"}}]}`, "In strict mode JSON objects cannot have duplicate keys. Path: /AND/1/OR/role"},
+		{`[{"role": "admin"}, {"role": ["a", {"x": 1, "x": 2}]}]`, "In strict mode JSON objects cannot have duplicate keys. Path: /OR/1/role/1/x"},
+		{`{"role": "a}\"", "r\u006fle": "b"}`, "In strict mode JSON objects cannot have duplicate keys. Path: /role"},
+	}
+	for _, test := range tests {
+		access, err := lp.CheckAccess(test.permissions, map[string]interface{}{"role": "admin"})
+		assert.False(t, access, "Permissions: %v", test.permissions)
+		assert.IsType(t, &InvalidArgumentValueError{}, err, "Permissions: %v", test.permissions)
+		assert.EqualError(t, err, test.err, "Permissions: %v", test.permissions)
+	}
+
+	// Ambiguous documents are also caught when they are linted.
+	diagnostics := lp.Lint(`{"role": "admin", "role": "editor"}`)
+	assert.Len(t, diagnostics, 1)
+	assert.Equal(t, LintRuleInvalidPermissions, diagnostics[0].Rule)
+}
@@ -1,6 +1,7 @@
 package logicalpermissions_test
 
 import (
+	"errors"
 	"fmt"
 	"sort"
 	"testing"
@@ -18,6 +19,37 @@ func stringInSlice(a string, list []string) bool {
 	return false
 }
 
+// newRoleLogicalPermissions creates the LogicalPermissions that the tests of
+// optional features share. The permission type "role" grants the role in
+// context["role"], returns an error for the role "broken" and panics for the
+// role "panic". The bypass callback grants access to the role "superuser".
+// Calls are counted as "role:<role>" and "bypass" if a counter is passed.
+func newRoleLogicalPermissions(t *testing.T, counter *callCounter) *LogicalPermissions {
+	lp := &LogicalPermissions{}
+	err := lp.AddType("role", func(role string, context map[string]interface{}) (bool, error) {
+		if counter != nil {
+			counter.add("role:" + role)
+		}
+		if role == "broken" {
+			return false, errors.New("broken role")
+		}
+		if role == "panic" {
+			panic("role panic")
+		}
+		return context["role"] == role, nil
+	})
+	if err != nil {
+		t.Error(fmt.Sprintf("LogicalPermissions::AddType() returned an error: %s", err))
+	}
+	lp.SetBypassCallback(func(context map[string]interface{}) (bool, error) {
+		if counter != nil {
+			counter.add("bypass")
+		}
+		return context["role"] == "superuser", nil
+	})
+	return lp
+}
+
 func TestCreation(t *testing.T) {
 	t.Parallel()
 	lp := LogicalPermissions{}
@@ -575,6 +607,11 @@ func TestCheckAccessNoBypassWrongType(t *testing.T) {
 	lp.SetBypassCallback(bypass_callback)
 	access, err := lp.CheckAccess(map[string]interface{}{"no_bypass": []string{"test"}}, make(map[string]interface{}))
 	assert.False(t, access)
+	if assert.Error(t, err) {
+		assert.IsType(t, &BypassNotRegisteredError{}, err)
+	}
+	access, err = lp.CheckAccess(map[string]interface{}{"no_bypass": []interface{}{1}}, make(map[string]interface{}))
+	assert.False(t, access)
 	if assert.Error(t, err) {
 		assert.IsType(t, &InvalidArgumentValueError{}, err)
 	}
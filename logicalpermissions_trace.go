@@ -0,0 +1,103 @@
+package logicalpermissions
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Kinds of nodes in a Trace.
+const (
+	TraceNodeGate     = "gate"
+	TraceNodeType     = "type"
+	TraceNodeBoolean  = "boolean"
+	TraceNodeValue    = "value"
+	TraceNodeNoBypass = "no_bypass"
+	TraceNodeBypass   = "bypass"
+)
+
+// Trace explains how an access decision was reached. Only evaluated nodes are
+// recorded, so branches skipped by short-circuiting are absent. Bypass is the
+// name of the first bypass that granted bypass access.
+type Trace struct {
+	BypassChecked bool       `json:"bypass_checked"`
+	BypassAccess  bool       `json:"bypass_access"`
+	Bypass        string     `json:"bypass,omitempty"`
+	NoBypass      *TraceNode `json:"no_bypass,omitempty"`
+	Root          *TraceNode `json:"root,omitempty"`
+	Result        bool       `json:"result"`
+	Unknown       bool       `json:"unknown,omitempty"`
+	Error         string     `json:"error,omitempty"`
+}
+
+// TraceNode is one evaluated node of a permission tree. Path is a JSON pointer
+// to the evaluated value in the permission tree after the normalization done by
+// CheckAccess(), which means that a NOT gate and its string value share a path.
+type TraceNode struct {
+	Path     string       `json:"path"`
+	Kind     string       `json:"kind"`
+	Name     string       `json:"name,omitempty"`
+	Type     string       `json:"type,omitempty"`
+	Value    string       `json:"value,omitempty"`
+	Result   bool         `json:"result"`
+	Unknown  bool         `json:"unknown,omitempty"`
+	Error    string       `json:"error,omitempty"`
+	Children []*TraceNode `json:"children,omitempty"`
+}
+
+func (this *Trace) String() string {
+	var buffer bytes.Buffer
+	if this.Unknown {
+		buffer.WriteString("Result: unknown\n")
+	} else {
+		fmt.Fprintf(&buffer, "Result: %t\n", this.Result)
+	}
+	if this.Error != "" {
+		fmt.Fprintf(&buffer, "Error: %s\n", this.Error)
+	}
+	bypass := "not checked"
+	if this.BypassChecked {
+		bypass = "denied"
+		if this.BypassAccess {
+			bypass = "granted"
+		}
+		if this.BypassAccess && this.Bypass != "" && this.Bypass != DefaultBypass {
+			bypass = fmt.Sprintf("granted by %s", this.Bypass)
+		}
+	}
+	fmt.Fprintf(&buffer, "Bypass: %s\n", bypass)
+	if this.NoBypass != nil {
+		buffer.WriteString("NO_BYPASS:\n")
+		this.NoBypass.write(&buffer, 1)
+	}
+	if this.Root != nil {
+		buffer.WriteString("Permissions:\n")
+		this.Root.write(&buffer, 1)
+	}
+	return buffer.String()
+}
+
+func (this *TraceNode) Label() string {
+	if this.Kind == TraceNodeValue {
+		return fmt.Sprintf("%s: %q", this.Type, this.Value)
+	}
+	if this.Kind == TraceNodeBypass && this.Value != "" && this.Value != DefaultBypass {
+		return fmt.Sprintf("%s (%s)", this.Name, this.Value)
+	}
+	return this.Name
+}
+
+func (this *TraceNode) write(buffer *bytes.Buffer, depth int) {
+	if this.Unknown {
+		fmt.Fprintf(buffer, "%s%s [%s]: unknown", strings.Repeat("  ", depth), this.Label(), this.Path)
+	} else {
+		fmt.Fprintf(buffer, "%s%s [%s]: %t", strings.Repeat("  ", depth), this.Label(), this.Path, this.Result)
+	}
+	if this.Error != "" {
+		fmt.Fprintf(buffer, " (error: %s)", this.Error)
+	}
+	buffer.WriteString("\n")
+	for _, child := range this.Children {
+		child.write(buffer, depth+1)
+	}
+}
@@ -0,0 +1,141 @@
+package logicalpermissions_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	. "github.com/ordermind/logical-permissions-go"
+	"github.com/stretchr/testify/assert"
+)
+
+/*-------------LogicalPermissions::CheckAccessWithTrace()--------------*/
+
+func TestCheckAccessWithTrace(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	err := lp.AddType("role", func(role string, context map[string]interface{}) (bool, error) {
+		return role == "editor", nil
+	})
+	if err != nil {
+		t.Error(fmt.Sprintf("LogicalPermissions::AddType() returned an error: %s", err))
+	}
+	lp.SetBypassCallback(func(map[string]interface{}) (bool, error) { return false, nil })
+
+	permissions := `{
+		"AND": [
+			{"role": ["admin", "editor"]},
+			{"NOT": {"role": "admin"}},
+			true
+		]
+	}`
+	access, trace, err := lp.CheckAccessWithTrace(permissions, map[string]interface{}{})
+	assert.Nil(t, err)
+	assert.True(t, access)
+	assert.True(t, trace.Result)
+	assert.True(t, trace.BypassChecked)
+	assert.False(t, trace.BypassAccess)
+	assert.Nil(t, trace.NoBypass)
+
+	root := trace.Root
+	assert.Equal(t, "", root.Path)
+	assert.Equal(t, 1, len(root.Children))
+	and := root.Children[0]
+	assert.Equal(t, "/AND", and.Path)
+	assert.Equal(t, TraceNodeGate, and.Kind)
+	assert.Equal(t, "AND", and.Name)
+	assert.True(t, and.Result)
+	assert.Equal(t, 3, len(and.Children))
+
+	role := and.Children[0]
+	assert.Equal(t, "/AND/0/role", role.Path)
+	assert.Equal(t, TraceNodeType, role.Kind)
+	assert.Equal(t, 2, len(role.Children))
+	assert.Equal(t, &TraceNode{Path: "/AND/0/role/0", Kind: TraceNodeValue, Type: "role", Value: "admin", Result: false}, role.Children[0])
+	assert.Equal(t, &TraceNode{Path: "/AND/0/role/1", Kind: TraceNodeValue, Type: "role", Value: "editor", Result: true}, role.Children[1])
+
+	not := and.Children[1]
+	assert.Equal(t, "/AND/1/NOT", not.Path)
+	assert.True(t, not.Result)
+	assert.Equal(t, &TraceNode{Path: "/AND/1/NOT/role", Kind: TraceNodeValue, Type: "role", Value: "admin", Result: false}, not.Children[0])
+
+	assert.Equal(t, &TraceNode{Path: "/AND/2", Kind: TraceNodeBoolean, Name: "TRUE", Result: true}, and.Children[2])
+}
+
+func TestCheckAccessWithTraceShortCircuit(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	err := lp.AddType("flag", func(flag string, context map[string]interface{}) (bool, error) {
+		return flag == "beta", nil
+	})
+	if err != nil {
+		t.Error(fmt.Sprintf("LogicalPermissions::AddType() returned an error: %s", err))
+	}
+	access, trace, err := lp.CheckAccessWithTrace(map[string]interface{}{"flag": []interface{}{"beta", "alpha"}}, map[string]interface{}{})
+	assert.Nil(t, err)
+	assert.True(t, access)
+	assert.False(t, trace.BypassChecked)
+	flag := trace.Root.Children[0]
+	assert.Equal(t, 1, len(flag.Children))
+	assert.Equal(t, "beta", flag.Children[0].Value)
+}
+
+func TestCheckAccessWithTraceBypass(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	lp.SetBypassCallback(func(map[string]interface{}) (bool, error) { return true, nil })
+	access, trace, err := lp.CheckAccessWithTrace(`{"NO_BYPASS": {"NOT": "TRUE"}, "0": false}`, map[string]interface{}{})
+	assert.Nil(t, err)
+	assert.True(t, access)
+	assert.True(t, trace.BypassChecked)
+	assert.True(t, trace.BypassAccess)
+	assert.Nil(t, trace.Root)
+	assert.Equal(t, "/NO_BYPASS", trace.NoBypass.Path)
+	assert.False(t, trace.NoBypass.Result)
+	assert.Equal(t, "/NO_BYPASS/NOT", trace.NoBypass.Children[0].Path)
+
+	access, trace, err = lp.CheckAccessNoBypassWithTrace(`{"NO_BYPASS": {"NOT": "TRUE"}, "0": false}`, map[string]interface{}{})
+	assert.Nil(t, err)
+	assert.False(t, access)
+	assert.False(t, trace.BypassChecked)
+	assert.Nil(t, trace.NoBypass)
+	assert.Equal(t, &TraceNode{Path: "/0", Kind: TraceNodeBoolean, Name: "FALSE", Result: false}, trace.Root.Children[0])
+}
+
+func TestCheckAccessWithTraceError(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	err := lp.AddType("role", func(string, map[string]interface{}) (bool, error) {
+		return false, errors.New("unavailable")
+	})
+	if err != nil {
+		t.Error(fmt.Sprintf("LogicalPermissions::AddType() returned an error: %s", err))
+	}
+	access, trace, err := lp.CheckAccessWithTrace(map[string]interface{}{"role": "admin"}, map[string]interface{}{})
+	assert.Error(t, err)
+	assert.False(t, access)
+	assert.Equal(t, err.Error(), trace.Error)
+	assert.Equal(t, "unavailable", trace.Root.Children[0].Error)
+}
+
+/*-------------Trace::String()--------------*/
+
+func TestTraceString(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	err := lp.AddType("role", func(role string, context map[string]interface{}) (bool, error) {
+		return role == "admin", nil
+	})
+	if err != nil {
+		t.Error(fmt.Sprintf("LogicalPermissions::AddType() returned an error: %s", err))
+	}
+	_, trace, err := lp.CheckAccessWithTrace(`{"OR": {"role": "admin"}}`, map[string]interface{}{})
+	assert.Nil(t, err)
+	expected := "Result: true\n" +
+		"Bypass: not checked\n" +
+		"Permissions:\n" +
+		"  OR []: true\n" +
+		"    OR [/OR]: true\n" +
+		"      role: \"admin\" [/OR/role]: true\n"
+	assert.Equal(t, expected, trace.String())
+}
@@ -0,0 +1,154 @@
+package logicalpermissions
+
+import (
+	"sync"
+	"time"
+)
+
+// Names of the spans started by LogicalPermissions.
+const (
+	SpanNameCheck    = "logicalpermissions.check"
+	SpanNameBypass   = "logicalpermissions.bypass"
+	SpanNameCallback = "logicalpermissions.callback"
+	SpanNameBatch    = "logicalpermissions.batch"
+)
+
+// Tracer starts spans for access checks and the callbacks they invoke. Register
+// it with LogicalPermissions::SetTracer().
+type Tracer interface {
+	// StartSpan starts a span. The parent is nil for the span of an access check
+	// and the span of the access check for callback spans. The context is the
+	// context map passed to CheckAccess().
+	StartSpan(parent Span, name string, context map[string]interface{}, attributes map[string]interface{}) Span
+}
+
+// Span is a span started by a Tracer.
+type Span interface {
+	SetAttribute(key string, value interface{})
+	// End ends the span. The error is the one returned by the access check or
+	// the callback, or nil.
+	End(err error)
+}
+
+func (this *LogicalPermissions) GetTracer() Tracer {
+	return this.tracer
+}
+
+func (this *LogicalPermissions) SetTracer(tracer Tracer) {
+	this.tracer = tracer
+}
+
+func (this *LogicalPermissions) startCheckSpan(context map[string]interface{}, allow_bypass bool, eval *evaluation) Span {
+	tracer := this.GetTracer()
+	if tracer == nil || eval.isShadow() {
+		return nil
+	}
+	attributes := map[string]interface{}{"logicalpermissions.allow_bypass": allow_bypass}
+	if eval.getCheckID() != 0 {
+		attributes["logicalpermissions.check_id"] = eval.getCheckID()
+	}
+	eval.span = tracer.StartSpan(nil, SpanNameCheck, context, attributes)
+	return eval.span
+}
+
+func (this *LogicalPermissions) endCheckSpan(span Span, access bool, err error, eval *evaluation) {
+	if span == nil {
+		return
+	}
+	span.SetAttribute("logicalpermissions.bypass_granted", eval.bypass_granted)
+	if eval.unknown {
+		span.SetAttribute("logicalpermissions.unknown", true)
+	}
+	this.endSpan(span, access, err)
+}
+
+func (this *LogicalPermissions) startCallbackSpan(name string, context map[string]interface{}, eval *evaluation, attributes map[string]interface{}) Span {
+	tracer := this.GetTracer()
+	if tracer == nil || eval.isShadow() {
+		return nil
+	}
+	return tracer.StartSpan(eval.span, name, context, attributes)
+}
+
+func (this *LogicalPermissions) endSpan(span Span, access bool, err error) {
+	if span == nil {
+		return
+	}
+	span.SetAttribute("logicalpermissions.access", access)
+	span.End(err)
+}
+
+// RecordedSpan is a span recorded by a SpanRecorder. The ParentID is 0 for
+// spans without a parent.
+type RecordedSpan struct {
+	ID         int
+	ParentID   int
+	Name       string
+	Attributes map[string]interface{}
+	Err        error
+	Start      time.Time
+	End        time.Time
+	Ended      bool
+}
+
+// SpanRecorder is a Tracer that keeps all spans in memory, which is useful in
+// tests.
+type SpanRecorder struct {
+	mutex sync.Mutex
+	spans []*RecordedSpan
+}
+
+type recorderSpan struct {
+	recorder *SpanRecorder
+	span     *RecordedSpan
+}
+
+func (this *SpanRecorder) StartSpan(parent Span, name string, context map[string]interface{}, attributes map[string]interface{}) Span {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	span := &RecordedSpan{ID: len(this.spans) + 1, Name: name, Attributes: make(map[string]interface{}), Start: time.Now()}
+	if parent_span, ok := parent.(*recorderSpan); ok {
+		span.ParentID = parent_span.span.ID
+	}
+	for key, value := range attributes {
+		span.Attributes[key] = value
+	}
+	this.spans = append(this.spans, span)
+	return &recorderSpan{recorder: this, span: span}
+}
+
+// Spans returns copies of the recorded spans in the order they were started.
+func (this *SpanRecorder) Spans() []RecordedSpan {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	spans := make([]RecordedSpan, len(this.spans))
+	for i, span := range this.spans {
+		spans[i] = *span
+		spans[i].Attributes = make(map[string]interface{}, len(span.Attributes))
+		for key, value := range span.Attributes {
+			spans[i].Attributes[key] = value
+		}
+	}
+	return spans
+}
+
+// Reset removes all recorded spans.
+func (this *SpanRecorder) Reset() {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.spans = nil
+}
+
+func (this *recorderSpan) SetAttribute(key string, value interface{}) {
+	this.recorder.mutex.Lock()
+	defer this.recorder.mutex.Unlock()
+	this.span.Attributes[key] = value
+}
+
+func (this *recorderSpan) End(err error) {
+	this.recorder.mutex.Lock()
+	defer this.recorder.mutex.Unlock()
+	this.span.Err = err
+	this.span.End = time.Now()
+	this.span.Ended = true
+}
@@ -0,0 +1,76 @@
+package logicalpermissions_test
+
+import (
+	"testing"
+
+	. "github.com/ordermind/logical-permissions-go"
+	"github.com/stretchr/testify/assert"
+)
+
+/*-------------LogicalPermissions::GetTracer()--------------*/
+
+func TestGetTracer(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	assert.Nil(t, lp.GetTracer())
+	recorder := &SpanRecorder{}
+	lp.SetTracer(recorder)
+	assert.Equal(t, recorder, lp.GetTracer())
+}
+
+/*-------------SpanRecorder--------------*/
+
+func TestSpanRecorder(t *testing.T) {
+	t.Parallel()
+	lp := newRoleLogicalPermissions(t, nil)
+	recorder := &SpanRecorder{}
+	lp.SetTracer(recorder)
+
+	access, err := lp.CheckAccess(map[string]interface{}{"role": []interface{}{"editor", "admin"}}, map[string]interface{}{"role": "admin"})
+	assert.Nil(t, err)
+	assert.True(t, access)
+	spans := recorder.Spans()
+	assert.Len(t, spans, 4)
+	for _, span := range spans {
+		assert.True(t, span.Ended)
+		assert.Nil(t, span.Err)
+		assert.False(t, span.End.Before(span.Start))
+	}
+	assert.Equal(t, SpanNameCheck, spans[0].Name)
+	assert.Equal(t, 0, spans[0].ParentID)
+	assert.Equal(t, map[string]interface{}{
+		"logicalpermissions.allow_bypass":   true,
+		"logicalpermissions.bypass_granted": false,
+		"logicalpermissions.access":         true,
+	}, spans[0].Attributes)
+	assert.Equal(t, SpanNameBypass, spans[1].Name)
+	assert.Equal(t, spans[0].ID, spans[1].ParentID)
+	assert.Equal(t, false, spans[1].Attributes["logicalpermissions.access"])
+	assert.Equal(t, SpanNameCallback, spans[2].Name)
+	assert.Equal(t, spans[0].ID, spans[2].ParentID)
+	assert.Equal(t, map[string]interface{}{
+		"logicalpermissions.type":       "role",
+		"logicalpermissions.permission": "editor",
+		"logicalpermissions.access":     false,
+	}, spans[2].Attributes)
+	assert.Equal(t, "admin", spans[3].Attributes["logicalpermissions.permission"])
+	assert.Equal(t, true, spans[3].Attributes["logicalpermissions.access"])
+
+	recorder.Reset()
+	lp.AddObserver(BaseObserver{})
+	_, err = lp.CheckAccessNoBypass(map[string]interface{}{"role": "broken"}, map[string]interface{}{})
+	assert.NotNil(t, err)
+	spans = recorder.Spans()
+	assert.Len(t, spans, 2)
+	assert.Equal(t, false, spans[0].Attributes["logicalpermissions.allow_bypass"])
+	assert.NotNil(t, spans[0].Attributes["logicalpermissions.check_id"])
+	assert.Equal(t, err, spans[0].Err)
+	assert.EqualError(t, spans[1].Err, "broken role")
+
+	recorder.Reset()
+	access, err = lp.CheckAccess(map[string]interface{}{"role": "admin"}, map[string]interface{}{"role": "superuser"})
+	assert.True(t, access)
+	spans = recorder.Spans()
+	assert.Len(t, spans, 2)
+	assert.Equal(t, true, spans[0].Attributes["logicalpermissions.bypass_granted"])
+}
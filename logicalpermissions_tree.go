@@ -0,0 +1,345 @@
+package logicalpermissions
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// permissionTree is a parsed permission tree. It mirrors the way dispatch()
+// interprets permissions, so that permission trees can be inspected without
+// being evaluated.
+type permissionTree struct {
+	// no_bypass holds the NO_BYPASS value if it is a boolean, a boolean string
+	// or a slice of bypass names.
+	no_bypass interface{}
+	// no_bypass_node holds the NO_BYPASS permissions if they are a map.
+	no_bypass_node *permissionNode
+	root           *permissionNode
+	errors         []*permissionTreeError
+}
+
+type permissionTreeError struct {
+	path string
+	err  CustomErrorInterface
+}
+
+// permissionNode is a node of a parsed permission tree. The kind and path of a
+// node are the same as those of the corresponding TraceNode.
+type permissionNode struct {
+	path     string
+	kind     string
+	name     string
+	key      string
+	permtype string
+	value    string
+	implicit bool
+	ordered  bool
+	children []*permissionNode
+	// no_bypass and no_bypass_node hold the NO_BYPASS value of a NO_BYPASS
+	// node below the root, like the fields of permissionTree. The single child
+	// of the node holds the permissions that the NO_BYPASS key applies to.
+	no_bypass      interface{}
+	no_bypass_node *permissionNode
+}
+
+func (this *LogicalPermissions) parsePermissionTree(permissions interface{}) (*permissionTree, error) {
+	map_permissions, err := this.preparePermissions(permissions)
+	if err != nil {
+		return nil, err
+	}
+	return this.parsePreparedTree(map_permissions), nil
+}
+
+// parsePreparedTree parses permissions that have already been prepared with
+// preparePermissions(). The map of the permissions is not modified.
+func (this *LogicalPermissions) parsePreparedTree(prepared_permissions map[string]interface{}) *permissionTree {
+	tree := &permissionTree{}
+	map_permissions := make(map[string]interface{}, len(prepared_permissions))
+	for key, value := range prepared_permissions {
+		map_permissions[key] = value
+	}
+
+	if no_bypass, ok := map_permissions["no_bypass"]; ok {
+		map_permissions["NO_BYPASS"] = no_bypass
+		delete(map_permissions, "no_bypass")
+	}
+	if no_bypass, ok := map_permissions["NO_BYPASS"]; ok {
+		tree.no_bypass, tree.no_bypass_node = this.parseNoBypass(tree, no_bypass, "/NO_BYPASS")
+		delete(map_permissions, "NO_BYPASS")
+	}
+
+	tree.root = &permissionNode{path: "", kind: TraceNodeGate, name: "OR", implicit: true}
+	tree.root.children = this.parseChildren(tree, map_permissions, "", "")
+	return tree
+}
+
+// err returns the first error found while parsing the permission tree.
+func (this *permissionTree) err() error {
+	if len(this.errors) > 0 {
+		return this.errors[0].err
+	}
+	return nil
+}
+
+func (this *permissionTree) addError(path string, err CustomErrorInterface) {
+	this.errors = append(this.errors, &permissionTreeError{path: path, err: err})
+}
+
+// walk calls fn for every node of the tree in depth-first order, starting with
+// the NO_BYPASS permissions.
+func (this *permissionTree) walk(fn func(node *permissionNode, parent *permissionNode)) {
+	if this.no_bypass_node != nil {
+		this.no_bypass_node.walk(nil, fn)
+	}
+	this.root.walk(nil, fn)
+}
+
+func (this *permissionNode) walk(parent *permissionNode, fn func(node *permissionNode, parent *permissionNode)) {
+	fn(this, parent)
+	if this.no_bypass_node != nil {
+		this.no_bypass_node.walk(this, fn)
+	}
+	for _, child := range this.children {
+		child.walk(this, fn)
+	}
+}
+
+// isBoolean reports whether the node is a boolean permission with the given value.
+func (this *permissionNode) isBoolean(value bool) bool {
+	return this.kind == TraceNodeBoolean && this.name == strings.ToUpper(strconv.FormatBool(value))
+}
+
+// signature returns a string that is equal for nodes that are evaluated the same way.
+func (this *permissionNode) signature() string {
+	signature := fmt.Sprintf("%s(%s,%s,%q", this.kind, this.name, this.permtype, this.value)
+	if this.kind == TraceNodeNoBypass {
+		signature += fmt.Sprintf(",%v", this.no_bypass)
+		if this.no_bypass_node != nil {
+			signature += "," + this.no_bypass_node.signature()
+		}
+	}
+	for _, child := range this.children {
+		signature += "," + child.signature()
+	}
+	return signature + ")"
+}
+
+func (this *LogicalPermissions) parseChildren(tree *permissionTree, permissions interface{}, permtype string, path string) []*permissionNode {
+	children := []*permissionNode{}
+	if slice_permissions, ok := permissions.([]interface{}); ok {
+		for i, permission := range slice_permissions {
+			if child := this.parseNode(tree, permission, permtype, path+"/"+strconv.Itoa(i)); child != nil {
+				children = append(children, child)
+			}
+		}
+	} else if map_permissions, ok := permissions.(map[string]interface{}); ok {
+		keys := make([]string, 0, len(map_permissions))
+		for key := range map_permissions {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if child := this.parseNode(tree, map[string]interface{}{key: map_permissions[key]}, permtype, path); child != nil {
+				children = append(children, child)
+			}
+		}
+	}
+	return children
+}
+
+func (this *LogicalPermissions) parseNode(tree *permissionTree, permissions interface{}, permtype string, path string) *permissionNode {
+	if bool_permissions, ok := permissions.(bool); ok {
+		if permtype != "" {
+			tree.addError(path, &InvalidArgumentValueError{CustomError{fmt.Sprintf("You cannot put a boolean permission as a descendant to a permission type. Existing type: %s. Evaluated permissions: %v", permtype, bool_permissions)}})
+			return nil
+		}
+		return &permissionNode{path: path, kind: TraceNodeBoolean, name: strings.ToUpper(strconv.FormatBool(bool_permissions))}
+	}
+	if str_permissions, ok := permissions.(string); ok {
+		str_upper := strings.ToUpper(str_permissions)
+		if str_upper == "TRUE" || str_upper == "FALSE" {
+			if permtype != "" {
+				tree.addError(path, &InvalidArgumentValueError{CustomError{fmt.Sprintf("You cannot put a boolean permission as a descendant to a permission type. Existing type: %s. Evaluated permissions: %v", permtype, str_permissions)}})
+				return nil
+			}
+			return &permissionNode{path: path, kind: TraceNodeBoolean, name: str_upper}
+		}
+		if permtype == "" {
+			tree.addError(path, &InvalidArgumentValueError{CustomError{fmt.Sprintf("A permission string must be a descendant to a permission type. Evaluated permissions: %v", str_permissions)}})
+			return nil
+		}
+		return &permissionNode{path: path, kind: TraceNodeValue, permtype: permtype, value: str_permissions}
+	}
+	if slice_permissions, ok := permissions.([]interface{}); ok {
+		return &permissionNode{path: path, kind: TraceNodeGate, name: "OR", permtype: permtype, implicit: true, ordered: true, children: this.parseChildren(tree, slice_permissions, permtype, path)}
+	}
+	if map_permissions, ok := permissions.(map[string]interface{}); ok {
+		if key, ok := this.getNoBypassKey(map_permissions); ok {
+			return this.parseNoBypassScope(tree, map_permissions, key, permtype, path, func(permissions interface{}) *permissionNode {
+				return this.parseNode(tree, permissions, permtype, path)
+			})
+		}
+		if len(map_permissions) == 1 {
+			key := ""
+			for k := range map_permissions {
+				key = k
+			}
+			value := map_permissions[key]
+			key_path := path + "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+			node := &permissionNode{path: key_path, kind: TraceNodeGate, name: "OR", key: key, permtype: permtype, implicit: true}
+			if _, err := strconv.Atoi(key); err != nil {
+				key_upper := strings.ToUpper(key)
+				if this.stringInSlice(key_upper, this.getGateKeys()) {
+					return this.parseScopedValue(tree, value, permtype, key_path, func(value interface{}) *permissionNode {
+						node.name = key_upper
+						node.implicit = false
+						node.children = this.parseGate(tree, key_upper, value, permtype, key_path)
+						_, node.ordered = value.([]interface{})
+						return node
+					})
+				}
+				if key_upper == "TRUE" || key_upper == "FALSE" {
+					tree.addError(key_path, &InvalidArgumentValueError{CustomError{fmt.Sprintf("A boolean permission cannot have children. Evaluated permissions: %v", map_permissions)}})
+					return nil
+				}
+				if permtype != "" {
+					tree.addError(key_path, &InvalidArgumentValueError{CustomError{fmt.Sprintf("You cannot put a permission type as a descendant to another permission type. Existing type: %s. Evaluated permissions: %v", permtype, map_permissions)}})
+					return nil
+				}
+				if exists, _ := this.TypeExists(key); !exists {
+					tree.addError(key_path, &PermissionTypeNotRegisteredError{CustomError{fmt.Sprintf("The permission type \"%s\" has not been registered. Please use LogicalPermissions::AddType() or LogicalPermissions::SetTypes() to register permission types.", key)}})
+					return nil
+				}
+				permtype = key
+				node.kind = TraceNodeType
+				node.name = key
+				node.permtype = key
+				node.implicit = false
+			}
+			_, is_slice := value.([]interface{})
+			_, is_map := value.(map[string]interface{})
+			if is_slice || is_map {
+				return this.parseScopedValue(tree, value, permtype, key_path, func(value interface{}) *permissionNode {
+					node.children = this.parseGate(tree, "OR", value, permtype, key_path)
+					node.ordered = is_slice
+					return node
+				})
+			}
+			return this.parseNode(tree, value, permtype, key_path)
+		}
+		return &permissionNode{path: path, kind: TraceNodeGate, name: "OR", permtype: permtype, implicit: true, children: this.parseChildren(tree, map_permissions, permtype, path)}
+	}
+
+	tree.addError(path, &InvalidArgumentValueError{CustomError{fmt.Sprintf("A permission value must either be a boolean, a string, a slice or a map. Evaluated permissions: %v", permissions)}})
+	return nil
+}
+
+func (this *LogicalPermissions) parseGate(tree *permissionTree, gate string, permissions interface{}, permtype string, path string) []*permissionNode {
+	if gate == "NOT" {
+		if map_permissions, ok := permissions.(map[string]interface{}); ok {
+			if len(map_permissions) != 1 {
+				tree.addError(path, &InvalidValueForLogicGateError{CustomError{fmt.Sprintf("A NOT permission must have exactly one child in the value map. Current value: %v", map_permissions)}})
+				return nil
+			}
+		} else if str_permissions, ok := permissions.(string); ok {
+			if str_permissions == "" {
+				tree.addError(path, &InvalidValueForLogicGateError{CustomError{"A NOT permission cannot have an empty string as its value."}})
+				return nil
+			}
+		} else {
+			tree.addError(path, &InvalidValueForLogicGateError{CustomError{fmt.Sprintf("The value of a NOT gate must be a map or string. Current value: %v", permissions)}})
+			return nil
+		}
+		if child := this.parseNode(tree, permissions, permtype, path); child != nil {
+			return []*permissionNode{child}
+		}
+		return nil
+	}
+
+	min_children := 1
+	count := "one element"
+	if gate == "XOR" {
+		min_children = 2
+		count = "two elements"
+	}
+	article := this.getIndefiniteArticle(gate)
+	if slice_permissions, ok := permissions.([]interface{}); ok {
+		if len(slice_permissions) < min_children {
+			tree.addError(path, &InvalidValueForLogicGateError{CustomError{fmt.Sprintf("The value slice of %s %s gate must contain a minimum of %s. Current value: %v", article, gate, count, slice_permissions)}})
+			return nil
+		}
+	} else if map_permissions, ok := permissions.(map[string]interface{}); ok {
+		if len(map_permissions) < min_children {
+			tree.addError(path, &InvalidValueForLogicGateError{CustomError{fmt.Sprintf("The value map of %s %s gate must contain a minimum of %s. Current value: %v", article, gate, count, map_permissions)}})
+			return nil
+		}
+	} else {
+		tree.addError(path, &InvalidValueForLogicGateError{CustomError{fmt.Sprintf("The value of %s %s gate must be a slice or map. Current value: %v", article, gate, permissions)}})
+		return nil
+	}
+	return this.parseChildren(tree, permissions, permtype, path)
+}
+
+// parseNoBypass parses a NO_BYPASS value. A boolean, a boolean string or a
+// slice is returned as the first value, and a map as a node.
+func (this *LogicalPermissions) parseNoBypass(tree *permissionTree, no_bypass interface{}, path string) (interface{}, *permissionNode) {
+	if slice_value, ok := this.getNoBypassSlice(no_bypass); ok {
+		if _, err_custom := this.parseBypassRefusal(slice_value); err_custom != nil {
+			tree.addError(path, err_custom)
+			return nil, nil
+		}
+		return no_bypass, nil
+	}
+	if mapval, ok := no_bypass.(map[string]interface{}); ok {
+		if _, ok := this.getNoBypassKey(mapval); ok {
+			tree.addError(path, &InvalidArgumentValueError{CustomError{fmt.Sprintf("The NO_BYPASS permissions cannot have a NO_BYPASS key. Current value: %v", no_bypass)}})
+			return nil, nil
+		}
+		node := &permissionNode{path: path, kind: TraceNodeGate, name: "OR", implicit: true}
+		node.children = this.parseChildren(tree, mapval, "", path)
+		return nil, node
+	}
+	if _, err_custom := this.checkAllowBypass(no_bypass, nil, nil); err_custom != nil {
+		tree.addError(path, err_custom)
+		return nil, nil
+	}
+	return no_bypass, nil
+}
+
+// parseScopedValue parses the value of a gate, a permission type or a numeric
+// key with parse, which receives the value without its NO_BYPASS key if it has
+// one.
+func (this *LogicalPermissions) parseScopedValue(tree *permissionTree, value interface{}, permtype string, path string, parse func(permissions interface{}) *permissionNode) *permissionNode {
+	if map_value, ok := value.(map[string]interface{}); ok {
+		if key, ok := this.getNoBypassKey(map_value); ok {
+			return this.parseNoBypassScope(tree, map_value, key, permtype, path, parse)
+		}
+	}
+	return parse(value)
+}
+
+// parseNoBypassScope parses a permission map with a NO_BYPASS key below the
+// root into a NO_BYPASS node, whose child is parsed from the other keys of the
+// map with parse.
+func (this *LogicalPermissions) parseNoBypassScope(tree *permissionTree, map_permissions map[string]interface{}, no_bypass_key string, permtype string, path string, parse func(permissions interface{}) *permissionNode) *permissionNode {
+	no_bypass_path := path + "/NO_BYPASS"
+	if permtype != "" {
+		tree.addError(no_bypass_path, &InvalidArgumentValueError{CustomError{fmt.Sprintf("The NO_BYPASS key cannot be placed below a permission type. Existing type: %s. Evaluated permissions: %v", permtype, map_permissions)}})
+		return nil
+	}
+	scoped, err_custom := this.getScopedPermissions(map_permissions, no_bypass_key)
+	if err_custom != nil {
+		tree.addError(no_bypass_path, err_custom)
+		return nil
+	}
+	node := &permissionNode{path: path, kind: TraceNodeNoBypass, name: "NO_BYPASS"}
+	node.no_bypass, node.no_bypass_node = this.parseNoBypass(tree, map_permissions[no_bypass_key], no_bypass_path)
+	child := parse(scoped)
+	if child == nil {
+		return nil
+	}
+	node.children = []*permissionNode{child}
+	return node
+}
@@ -0,0 +1,228 @@
+package logicalpermissions
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultBreakerCooldown is the time that the circuit breaker of a permission
+// type stays open if the type options do not set a cooldown.
+const DefaultBreakerCooldown = 30 * time.Second
+
+// TypeOptions holds the limits that are enforced around the callback of a
+// permission type. A zero value disables a limit.
+type TypeOptions struct {
+	// Timeout is the maximum duration of a callback call. The result of a call
+	// that times out is discarded.
+	Timeout time.Duration
+	// MaxConcurrent is the maximum number of callback calls in flight. Calls
+	// that time out are in flight until they return.
+	MaxConcurrent int
+	// BreakerThreshold is the number of consecutive callback errors after
+	// which the circuit breaker opens and calls are refused.
+	BreakerThreshold int
+	// BreakerCooldown is the time that the circuit breaker stays open before
+	// a single trial call is let through. A successful trial call closes the
+	// circuit breaker.
+	BreakerCooldown time.Duration
+}
+
+// typeLimiter enforces the type options of a permission type.
+type typeLimiter struct {
+	options  TypeOptions
+	inflight chan struct{}
+	mutex    sync.Mutex
+	// consecutive_errors is the number of consecutive callback errors, and the
+	// circuit breaker is open until open_until once it reaches the threshold.
+	// trial is set while a trial call is in flight.
+	consecutive_errors int
+	open_until         time.Time
+	trial              bool
+}
+
+type limitedResult struct {
+	access bool
+	err    error
+}
+
+func newTypeLimiter(options TypeOptions) *typeLimiter {
+	limiter := &typeLimiter{options: options}
+	if options.MaxConcurrent > 0 {
+		limiter.inflight = make(chan struct{}, options.MaxConcurrent)
+	}
+	return limiter
+}
+
+func (this *LogicalPermissions) AddTypeWithOptions(name string, callback func(string, map[string]interface{}) (bool, error), options TypeOptions) error {
+	if err := this.validateTypeOptions(options); err != nil {
+		return err
+	}
+	if err := this.AddType(name, callback); err != nil {
+		return err
+	}
+	return this.SetTypeOptions(name, options)
+}
+
+func (this *LogicalPermissions) GetTypeOptions(name string) (TypeOptions, error) {
+	if name == "" {
+		return TypeOptions{}, &InvalidArgumentValueError{CustomError{"The name parameter cannot be empty."}}
+	}
+	exists, _ := this.TypeExists(name)
+	if !exists {
+		return TypeOptions{}, &PermissionTypeNotRegisteredError{CustomError{fmt.Sprintf("The permission type \"%s\" has not been registered. Please use LogicalPermissions::AddType() or LogicalPermissions::SetTypes() to register permission types.", name)}}
+	}
+	if limiter, ok := this.type_limiters[name]; ok {
+		return limiter.options, nil
+	}
+	return TypeOptions{}, nil
+}
+
+// SetTypeOptions replaces the type options of a permission type, which also
+// resets its circuit breaker.
+func (this *LogicalPermissions) SetTypeOptions(name string, options TypeOptions) error {
+	if name == "" {
+		return &InvalidArgumentValueError{CustomError{"The name parameter cannot be empty."}}
+	}
+	exists, _ := this.TypeExists(name)
+	if !exists {
+		return &PermissionTypeNotRegisteredError{CustomError{fmt.Sprintf("The permission type \"%s\" has not been registered. Please use LogicalPermissions::AddType() or LogicalPermissions::SetTypes() to register permission types.", name)}}
+	}
+	if err := this.validateTypeOptions(options); err != nil {
+		return err
+	}
+	if options == (TypeOptions{}) {
+		delete(this.type_limiters, name)
+		return nil
+	}
+	if this.type_limiters == nil {
+		this.type_limiters = make(map[string]*typeLimiter)
+	}
+	this.type_limiters[name] = newTypeLimiter(options)
+	return nil
+}
+
+func (this *LogicalPermissions) validateTypeOptions(options TypeOptions) error {
+	if options.Timeout < 0 {
+		return &InvalidArgumentValueError{CustomError{fmt.Sprintf("The Timeout option cannot be negative. Current value: %v", options.Timeout)}}
+	}
+	if options.MaxConcurrent < 0 {
+		return &InvalidArgumentValueError{CustomError{fmt.Sprintf("The MaxConcurrent option cannot be negative. Current value: %d", options.MaxConcurrent)}}
+	}
+	if options.BreakerThreshold < 0 {
+		return &InvalidArgumentValueError{CustomError{fmt.Sprintf("The BreakerThreshold option cannot be negative. Current value: %d", options.BreakerThreshold)}}
+	}
+	if options.BreakerCooldown < 0 {
+		return &InvalidArgumentValueError{CustomError{fmt.Sprintf("The BreakerCooldown option cannot be negative. Current value: %v", options.BreakerCooldown)}}
+	}
+	return nil
+}
+
+// callLimited calls the callback of a permission type within the limits of its
+// type options.
+func (this *LogicalPermissions) callLimited(permtype string, callback func() (bool, error)) (bool, error) {
+	limiter, ok := this.type_limiters[permtype]
+	if !ok {
+		return callback()
+	}
+	if !limiter.allow() {
+		return false, &CircuitOpenError{CustomError{fmt.Sprintf("The circuit breaker of the permission type \"%s\" is open after %d consecutive errors.", permtype, limiter.options.BreakerThreshold)}}
+	}
+	if limiter.inflight != nil {
+		select {
+		case limiter.inflight <- struct{}{}:
+		default:
+			limiter.cancelTrial()
+			return false, &CallbackConcurrencyLimitError{CustomError{fmt.Sprintf("The permission type \"%s\" has reached its limit of %d concurrent callback calls.", permtype, limiter.options.MaxConcurrent)}}
+		}
+	}
+	// A panic that is not recovered counts as an error.
+	failed := true
+	defer func() { limiter.record(failed) }()
+	access, err := limiter.call(permtype, callback)
+	failed = err != nil
+	return access, err
+}
+
+// call calls the callback, in a new goroutine if it has a timeout. A panic in
+// that goroutine is raised again in the current goroutine, unless the call has
+// timed out and its result is discarded.
+func (this *typeLimiter) call(permtype string, callback func() (bool, error)) (bool, error) {
+	if this.options.Timeout == 0 {
+		defer this.release()
+		return callback()
+	}
+	done := make(chan limitedResult, 1)
+	recovered := make(chan interface{}, 1)
+	go func() {
+		defer this.release()
+		defer func() {
+			if value := recover(); value != nil {
+				recovered <- value
+			}
+		}()
+		access, err := callback()
+		done <- limitedResult{access: access, err: err}
+	}()
+	timer := time.NewTimer(this.options.Timeout)
+	defer timer.Stop()
+	select {
+	case result := <-done:
+		return result.access, result.err
+	case value := <-recovered:
+		panic(value)
+	case <-timer.C:
+		return false, &CallbackTimeoutError{CustomError{fmt.Sprintf("The callback of the permission type \"%s\" did not return within %v.", permtype, this.options.Timeout)}}
+	}
+}
+
+func (this *typeLimiter) release() {
+	if this.inflight != nil {
+		<-this.inflight
+	}
+}
+
+// allow tells whether the circuit breaker lets a call through. Once the
+// cooldown has passed, a single trial call is let through.
+func (this *typeLimiter) allow() bool {
+	if this.options.BreakerThreshold == 0 {
+		return true
+	}
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if this.consecutive_errors < this.options.BreakerThreshold {
+		return true
+	}
+	if this.trial || time.Now().Before(this.open_until) {
+		return false
+	}
+	this.trial = true
+	return true
+}
+
+func (this *typeLimiter) cancelTrial() {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.trial = false
+}
+
+func (this *typeLimiter) record(failed bool) {
+	if this.options.BreakerThreshold == 0 {
+		return
+	}
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.trial = false
+	if !failed {
+		this.consecutive_errors = 0
+		return
+	}
+	this.consecutive_errors++
+	if this.consecutive_errors >= this.options.BreakerThreshold {
+		cooldown := this.options.BreakerCooldown
+		if cooldown == 0 {
+			cooldown = DefaultBreakerCooldown
+		}
+		this.open_until = time.Now().Add(cooldown)
+	}
+}
@@ -0,0 +1,176 @@
+package logicalpermissions_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/ordermind/logical-permissions-go"
+	"github.com/stretchr/testify/assert"
+)
+
+/*-------------LogicalPermissions::AddTypeWithOptions()--------------*/
+
+func TestAddTypeWithOptionsParams(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	callback := func(string, map[string]interface{}) (bool, error) { return true, nil }
+	err := lp.AddTypeWithOptions("", callback, TypeOptions{})
+	assert.IsType(t, &InvalidArgumentValueError{}, err)
+	err = lp.AddTypeWithOptions("role", callback, TypeOptions{Timeout: -1})
+	assert.IsType(t, &InvalidArgumentValueError{}, err)
+	exists, _ := lp.TypeExists("role")
+	assert.False(t, exists)
+	err = lp.AddTypeWithOptions("role", callback, TypeOptions{MaxConcurrent: -1})
+	assert.IsType(t, &InvalidArgumentValueError{}, err)
+	err = lp.AddTypeWithOptions("role", callback, TypeOptions{BreakerThreshold: -1})
+	assert.IsType(t, &InvalidArgumentValueError{}, err)
+	err = lp.AddTypeWithOptions("role", callback, TypeOptions{BreakerCooldown: -1})
+	assert.IsType(t, &InvalidArgumentValueError{}, err)
+	err = lp.SetTypeOptions("unregistered", TypeOptions{})
+	assert.IsType(t, &PermissionTypeNotRegisteredError{}, err)
+	_, err = lp.GetTypeOptions("")
+	assert.IsType(t, &InvalidArgumentValueError{}, err)
+	_, err = lp.GetTypeOptions("unregistered")
+	assert.IsType(t, &PermissionTypeNotRegisteredError{}, err)
+}
+
+func TestAddTypeWithOptions(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	callback := func(string, map[string]interface{}) (bool, error) { return true, nil }
+	options := TypeOptions{Timeout: time.Second, MaxConcurrent: 10, BreakerThreshold: 5, BreakerCooldown: time.Minute}
+	assert.Nil(t, lp.AddTypeWithOptions("role", callback, options))
+	result, err := lp.GetTypeOptions("role")
+	assert.Nil(t, err)
+	assert.Equal(t, options, result)
+	err = lp.AddTypeWithOptions("role", callback, options)
+	assert.IsType(t, &PermissionTypeAlreadyExistsError{}, err)
+
+	assert.Nil(t, lp.SetTypeOptions("role", TypeOptions{}))
+	result, _ = lp.GetTypeOptions("role")
+	assert.Equal(t, TypeOptions{}, result)
+
+	lp.SetTypeOptions("role", options)
+	lp.RemoveType("role")
+	lp.AddType("role", callback)
+	result, _ = lp.GetTypeOptions("role")
+	assert.Equal(t, TypeOptions{}, result)
+}
+
+/*-------------Type options--------------*/
+
+func TestTypeOptionsTimeout(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	lp.AddTypeWithOptions("remote", func(permission string, context map[string]interface{}) (bool, error) {
+		if permission == "slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		return true, nil
+	}, TypeOptions{Timeout: 20 * time.Millisecond})
+
+	access, err := lp.CheckAccess(map[string]interface{}{"remote": "fast"}, map[string]interface{}{})
+	assert.Nil(t, err)
+	assert.True(t, access)
+	start := time.Now()
+	access, err = lp.CheckAccess(map[string]interface{}{"remote": "slow"}, map[string]interface{}{})
+	assert.True(t, time.Since(start) < 150*time.Millisecond)
+	assert.False(t, access)
+	assert.IsType(t, &CallbackTimeoutError{}, err)
+	assert.EqualError(t, err, "Error checking access: The callback of the permission type \"remote\" did not return within 20ms.")
+
+	// The error policies apply to limit errors.
+	lp.SetTypeErrorPolicy("remote", ErrorPolicyFalse)
+	access, err = lp.CheckAccess(map[string]interface{}{"OR": []interface{}{map[string]interface{}{"remote": "slow"}, "TRUE"}}, map[string]interface{}{})
+	assert.Nil(t, err)
+	assert.True(t, access)
+}
+
+func TestTypeOptionsTimeoutPanic(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	lp.AddTypeWithOptions("remote", func(permission string, context map[string]interface{}) (bool, error) {
+		panic("remote panic")
+	}, TypeOptions{Timeout: time.Second})
+	assert.PanicsWithValue(t, "remote panic", func() {
+		lp.CheckAccess(map[string]interface{}{"remote": "x"}, map[string]interface{}{})
+	})
+	lp.SetRecoverPanics(true)
+	_, err := lp.CheckAccess(map[string]interface{}{"remote": "x"}, map[string]interface{}{})
+	assert.IsType(t, &CallbackPanicError{}, err)
+}
+
+func TestTypeOptionsMaxConcurrent(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	started := make(chan bool)
+	release := make(chan bool)
+	lp.AddTypeWithOptions("remote", func(permission string, context map[string]interface{}) (bool, error) {
+		if permission == "blocking" {
+			started <- true
+			<-release
+		}
+		return true, nil
+	}, TypeOptions{MaxConcurrent: 1})
+
+	done := make(chan error)
+	go func() {
+		_, err := lp.CheckAccess(map[string]interface{}{"remote": "blocking"}, map[string]interface{}{})
+		done <- err
+	}()
+	<-started
+	access, err := lp.CheckAccess(map[string]interface{}{"remote": "other"}, map[string]interface{}{})
+	assert.False(t, access)
+	assert.IsType(t, &CallbackConcurrencyLimitError{}, err)
+	assert.EqualError(t, err, "Error checking access: The permission type \"remote\" has reached its limit of 1 concurrent callback calls.")
+	release <- true
+	assert.Nil(t, <-done)
+
+	access, err = lp.CheckAccess(map[string]interface{}{"remote": "other"}, map[string]interface{}{})
+	assert.Nil(t, err)
+	assert.True(t, access)
+}
+
+func TestTypeOptionsCircuitBreaker(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	counter := &callCounter{calls: make(map[string]int)}
+	healthy := false
+	lp.AddTypeWithOptions("remote", func(permission string, context map[string]interface{}) (bool, error) {
+		counter.add(permission)
+		if !healthy {
+			return false, errors.New("The remote service is down.")
+		}
+		return true, nil
+	}, TypeOptions{BreakerThreshold: 2, BreakerCooldown: 50 * time.Millisecond})
+	permissions := map[string]interface{}{"remote": "x"}
+
+	for i := 0; i < 2; i++ {
+		_, err := lp.CheckAccess(permissions, map[string]interface{}{})
+		assert.EqualError(t, err, "Error checking access: The remote service is down.")
+	}
+	_, err := lp.CheckAccess(permissions, map[string]interface{}{})
+	assert.IsType(t, &CircuitOpenError{}, err)
+	assert.EqualError(t, err, "Error checking access: The circuit breaker of the permission type \"remote\" is open after 2 consecutive errors.")
+	assert.Equal(t, 2, counter.get("x"))
+
+	// A failed trial call opens the circuit breaker again.
+	time.Sleep(60 * time.Millisecond)
+	_, err = lp.CheckAccess(permissions, map[string]interface{}{})
+	assert.EqualError(t, err, "Error checking access: The remote service is down.")
+	_, err = lp.CheckAccess(permissions, map[string]interface{}{})
+	assert.IsType(t, &CircuitOpenError{}, err)
+	assert.Equal(t, 3, counter.get("x"))
+
+	// A successful trial call closes the circuit breaker.
+	time.Sleep(60 * time.Millisecond)
+	healthy = true
+	access, err := lp.CheckAccess(permissions, map[string]interface{}{})
+	assert.Nil(t, err)
+	assert.True(t, access)
+	access, err = lp.CheckAccess(permissions, map[string]interface{}{})
+	assert.Nil(t, err)
+	assert.True(t, access)
+	assert.Equal(t, 5, counter.get("x"))
+}
@@ -0,0 +1,102 @@
+package logicalpermissions
+
+import (
+	"errors"
+)
+
+// TriState is the result of an access check in three-valued mode.
+type TriState int
+
+const (
+	TriStateFalse TriState = iota
+	TriStateTrue
+	TriStateUnknown
+)
+
+func (this TriState) String() string {
+	switch this {
+	case TriStateTrue:
+		return "true"
+	case TriStateUnknown:
+		return "unknown"
+	}
+	return "false"
+}
+
+// ErrUnknown is returned by a callback that cannot decide, for example because
+// an attribute is missing or a service is down. In three-valued mode the
+// permission is unknown, and unknown results propagate through the gates
+// according to Kleene logic. Otherwise it is an error like any other.
+var ErrUnknown = errors.New("The result is unknown.")
+
+// unknownResult is returned instead of an error by the evaluation of
+// permissions whose result is unknown. It never leaves the evaluation. The
+// cause is set if the result is unknown because of a callback error that is
+// only returned if it affects the result of the access check.
+type unknownResult struct {
+	CustomError
+	cause CustomErrorInterface
+}
+
+func newUnknownResult() *unknownResult {
+	return &unknownResult{CustomError: CustomError{ErrUnknown.Error()}}
+}
+
+func isUnknownResult(err error) bool {
+	_, ok := err.(*unknownResult)
+	return ok
+}
+
+// mergeUnknown merges the unknown results of the children of a gate. The first
+// result with a cause is kept, so that a callback error is returned if the
+// result of the gate affects the result of the access check.
+func mergeUnknown(unknown *unknownResult, err error) *unknownResult {
+	result := err.(*unknownResult)
+	if unknown == nil || (unknown.cause == nil && result.cause != nil) {
+		return result
+	}
+	return unknown
+}
+
+// setUnknown marks the result of an access check as unknown, which is reported
+// as denied access by the methods that return a bool. If the result is unknown
+// because of a callback error, the error is returned instead.
+func (this *LogicalPermissions) setUnknown(unknown *unknownResult, eval *evaluation) (bool, error) {
+	if unknown.cause != nil {
+		eval.setErrorCategory(MetricsErrorCallback)
+		return false, unknown.cause
+	}
+	eval.unknown = true
+	return false, nil
+}
+
+func (this *LogicalPermissions) GetThreeValued() bool {
+	return this.three_valued
+}
+
+func (this *LogicalPermissions) SetThreeValued(three_valued bool) {
+	this.three_valued = three_valued
+}
+
+func (this *LogicalPermissions) CheckAccessTriState(permissions interface{}, context map[string]interface{}) (TriState, error) {
+	return this.checkTriState(permissions, context, true)
+}
+
+func (this *LogicalPermissions) CheckAccessNoBypassTriState(permissions interface{}, context map[string]interface{}) (TriState, error) {
+	return this.checkTriState(permissions, context, false)
+}
+
+func (this *LogicalPermissions) checkTriState(permissions interface{}, context map[string]interface{}, allow_bypass bool) (TriState, error) {
+	eval := &evaluation{}
+	access, err := this.check(permissions, context, allow_bypass, eval)
+	if err != nil {
+		return TriStateFalse, err
+	}
+	if eval.unknown {
+		return TriStateUnknown, nil
+	}
+	if access {
+		return TriStateTrue, nil
+	}
+	return TriStateFalse, nil
+}
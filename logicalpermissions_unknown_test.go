@@ -0,0 +1,172 @@
+package logicalpermissions_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	. "github.com/ordermind/logical-permissions-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// unknownFlag grants the flag "yes" and cannot decide the flag "maybe".
+func unknownFlag(flag string, context map[string]interface{}) (bool, error) {
+	switch flag {
+	case "yes":
+		return true, nil
+	case "maybe":
+		return false, ErrUnknown
+	}
+	return false, nil
+}
+
+// unknownBypass grants access if context["bypass"] is "yes" and cannot decide
+// if it is "maybe".
+func unknownBypass(context map[string]interface{}) (bool, error) {
+	switch context["bypass"] {
+	case "yes":
+		return true, nil
+	case "maybe":
+		return false, ErrUnknown
+	}
+	return false, nil
+}
+
+/*-------------TriState--------------*/
+
+func TestTriStateString(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "false", TriStateFalse.String())
+	assert.Equal(t, "true", TriStateTrue.String())
+	assert.Equal(t, "unknown", TriStateUnknown.String())
+}
+
+/*-------------LogicalPermissions::SetThreeValued()--------------*/
+
+func TestSetThreeValued(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	lp.AddType("flag", unknownFlag)
+	lp.SetBypassCallback(unknownBypass)
+	lp.SetThreeValued(true)
+	assert.True(t, lp.GetThreeValued())
+	lp.SetThreeValued(false)
+	assert.False(t, lp.GetThreeValued())
+
+	// Without three-valued mode ErrUnknown is an error like any other.
+	result, err := lp.CheckAccessNoBypassTriState(map[string]interface{}{"flag": "maybe"}, map[string]interface{}{})
+	assert.EqualError(t, err, "Error checking access: The result is unknown.")
+	assert.Equal(t, TriStateFalse, result)
+}
+
+/*-------------LogicalPermissions::CheckAccessTriState()--------------*/
+
+func TestCheckAccessTriStateGates(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	lp.AddType("flag", unknownFlag)
+	lp.SetBypassCallback(unknownBypass)
+	lp.SetThreeValued(true)
+	parallel := LogicalPermissions{}
+	parallel.AddType("flag", unknownFlag)
+	parallel.SetBypassCallback(unknownBypass)
+	parallel.SetThreeValued(true)
+	parallel.SetParallelism(4)
+	yes := map[string]interface{}{"flag": "yes"}
+	no := map[string]interface{}{"flag": "no"}
+	maybe := map[string]interface{}{"flag": "maybe"}
+	tests := []struct {
+		permissions interface{}
+		expected    TriState
+	}{
+		{maybe, TriStateUnknown},
+		{map[string]interface{}{"NOT": maybe}, TriStateUnknown},
+		{map[string]interface{}{"OR": []interface{}{maybe, yes}}, TriStateTrue},
+		{map[string]interface{}{"OR": []interface{}{maybe, no}}, TriStateUnknown},
+		{map[string]interface{}{"NOR": []interface{}{maybe, yes}}, TriStateFalse},
+		{map[string]interface{}{"NOR": []interface{}{maybe, no}}, TriStateUnknown},
+		{map[string]interface{}{"AND": []interface{}{maybe, no}}, TriStateFalse},
+		{map[string]interface{}{"AND": []interface{}{maybe, yes}}, TriStateUnknown},
+		{map[string]interface{}{"NAND": []interface{}{maybe, no}}, TriStateTrue},
+		{map[string]interface{}{"NAND": []interface{}{maybe, yes}}, TriStateUnknown},
+		{map[string]interface{}{"XOR": []interface{}{maybe, yes}}, TriStateUnknown},
+		{map[string]interface{}{"XOR": []interface{}{maybe, yes, no}}, TriStateTrue},
+		{map[string]interface{}{"AND": map[string]interface{}{"flag": "maybe", "OR": []interface{}{no, "FALSE"}}}, TriStateFalse},
+		{map[string]interface{}{"flag": []interface{}{"maybe", "yes"}}, TriStateTrue},
+		{map[string]interface{}{"flag": []interface{}{"maybe", "no"}}, TriStateUnknown},
+	}
+	for _, test := range tests {
+		message := fmt.Sprintf("Permissions: %v", test.permissions)
+		result, err := lp.CheckAccessNoBypassTriState(test.permissions, map[string]interface{}{})
+		assert.Nil(t, err, message)
+		assert.Equal(t, test.expected, result, message)
+		result, err = parallel.CheckAccessNoBypassTriState(test.permissions, map[string]interface{}{})
+		assert.Nil(t, err, message)
+		assert.Equal(t, test.expected, result, message)
+		// CheckAccess() denies access if the result is unknown.
+		access, err := lp.CheckAccessNoBypass(test.permissions, map[string]interface{}{})
+		assert.Nil(t, err, message)
+		assert.Equal(t, test.expected == TriStateTrue, access, message)
+	}
+}
+
+func TestCheckAccessTriStateBypass(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	lp.AddType("flag", unknownFlag)
+	lp.SetBypassCallback(unknownBypass)
+	lp.SetThreeValued(true)
+	yes := map[string]interface{}{"flag": "yes"}
+	no := map[string]interface{}{"flag": "no"}
+
+	result, err := lp.CheckAccessTriState(no, map[string]interface{}{"bypass": "maybe"})
+	assert.Nil(t, err)
+	assert.Equal(t, TriStateUnknown, result)
+	result, _ = lp.CheckAccessTriState(yes, map[string]interface{}{"bypass": "maybe"})
+	assert.Equal(t, TriStateTrue, result)
+	result, _ = lp.CheckAccessTriState(no, map[string]interface{}{"bypass": "yes"})
+	assert.Equal(t, TriStateTrue, result)
+
+	// An unknown NO_BYPASS makes a granted bypass unknown.
+	permissions := map[string]interface{}{"NO_BYPASS": map[string]interface{}{"flag": "maybe"}, "flag": "no"}
+	result, _ = lp.CheckAccessTriState(permissions, map[string]interface{}{"bypass": "yes"})
+	assert.Equal(t, TriStateUnknown, result)
+	result, _ = lp.CheckAccessTriState(permissions, map[string]interface{}{"bypass": "no"})
+	assert.Equal(t, TriStateFalse, result)
+}
+
+func TestCheckAccessTriStateTrace(t *testing.T) {
+	t.Parallel()
+	lp := LogicalPermissions{}
+	lp.AddType("flag", unknownFlag)
+	lp.SetBypassCallback(unknownBypass)
+	lp.SetThreeValued(true)
+	var events []CheckEndEvent
+	lp.AddObserver(&checkEndRecorder{events: &events})
+	access, trace, err := lp.CheckAccessNoBypassWithTrace(map[string]interface{}{"OR": []interface{}{map[string]interface{}{"flag": "maybe"}, "FALSE"}}, map[string]interface{}{})
+	assert.Nil(t, err)
+	assert.False(t, access)
+	assert.False(t, trace.Result)
+	assert.True(t, trace.Unknown)
+	assert.Equal(t, "", trace.Error)
+	gate := trace.Root.Children[0]
+	assert.True(t, gate.Unknown)
+	assert.True(t, gate.Children[0].Unknown)
+	assert.Equal(t, "", gate.Children[0].Error)
+	assert.False(t, gate.Children[1].Unknown)
+	assert.True(t, strings.HasPrefix(trace.String(), "Result: unknown\n"))
+	assert.Contains(t, trace.String(), "flag: \"maybe\" [/OR/0/flag]: unknown")
+
+	assert.Len(t, events, 1)
+	assert.True(t, events[0].Unknown)
+	assert.False(t, events[0].Access)
+}
+
+type checkEndRecorder struct {
+	BaseObserver
+	events *[]CheckEndEvent
+}
+
+func (this *checkEndRecorder) OnCheckEnd(event CheckEndEvent) {
+	*this.events = append(*this.events, event)
+}
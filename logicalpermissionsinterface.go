@@ -34,13 +34,61 @@ type LogicalPermissionsInterface interface {
 	GetTypeCallback(name string) (func(string, map[string]interface{}) (bool, error), error)
 
 	/**
-	 * Changes the callback for an existing permission type.
+	 * Changes the callback for an existing permission type. The cached results of the permission type and the cached decisions that depend on it are removed.
 	 * @param {string} name - The name of the permission type.
 	 * @param {func(string, map[string]interface{}) (bool, error)} callback - The callback that evaluates the permission type. Upon calling CheckAccess() the registered callback will be passed two parameters: a permission string (such as a role) and the context map passed to CheckAccess(). The permission will always be a single string even if for example multiple roles are accepted. In that case the callback will be called once for each role that is to be evaluated. The callback should return a boolean which determines whether access should be granted. It should also return an error, or nil if no error occurred.
 	 * @returns {error} if something goes wrong, or nil if no error occurs.
 	 */
 	SetTypeCallback(name string, callback func(string, map[string]interface{}) (bool, error)) error
 
+	/**
+	 * Gets the batch callback for a permission type.
+	 * @param {string} name - The name of the permission type.
+	 * @returns {func([]string, map[string]interface{}) (map[string]bool, error)} Batch callback for the permission type, or nil if it has none.
+	 * @returns {error} if something goes wrong, or nil if no error occurs.
+	 */
+	GetTypeBatchCallback(name string) (func([]string, map[string]interface{}) (map[string]bool, error), error)
+
+	/**
+	 * Sets a batch callback for an existing permission type, which resolves several permissions of the type in one call.
+	 * @param {string} name - The name of the permission type.
+	 * @param {func([]string, map[string]interface{}) (map[string]bool, error)} callback - The batch callback, or nil in order to remove it. The first time a permission of the type is evaluated during an access check, the batch callback is passed every permission of the type in the permission tree and the context map passed to CheckAccess(). It should return the access of each permission, and an error or nil if no error occurred. Permissions that are missing from the returned map are evaluated with the callback of the permission type. The batch callback is subject to the type options of the permission type, like the callback.
+	 * @returns {error} if something goes wrong, or nil if no error occurs.
+	 */
+	SetTypeBatchCallback(name string, callback func([]string, map[string]interface{}) (map[string]bool, error)) error
+
+	/**
+	 * Gets the description template for a permission type.
+	 * @param {string} name - The name of the permission type.
+	 * @returns {string} the template, or an empty string if no template has been set.
+	 * @returns {error} if something goes wrong, or nil if no error occurs.
+	 */
+	GetTypeTemplate(name string) (string, error)
+
+	/**
+	 * Sets the description template for an existing permission type, which is used by Describe().
+	 * @param {string} name - The name of the permission type.
+	 * @param {string} template - The template for describing a permission of this type, such as "the %s flag" or "has role %s". Every occurrence of %s is replaced with the permission. Verb phrases such as "has role %s" also need a negated template, see SetTypeNegatedTemplate().
+	 * @returns {error} if something goes wrong, or nil if no error occurs.
+	 */
+	SetTypeTemplate(name string, template string) error
+
+	/**
+	 * Gets the negated description template for a permission type.
+	 * @param {string} name - The name of the permission type.
+	 * @returns {string} the negated template, or an empty string if no negated template has been set.
+	 * @returns {error} if something goes wrong, or nil if no error occurs.
+	 */
+	GetTypeNegatedTemplate(name string) (string, error)
+
+	/**
+	 * Sets the negated description template for an existing permission type, which describes a permission of this type in a NOT gate. A negated template also marks the templates of the type as verb phrases, so that descriptions containing them are not phrased as "Requires ...".
+	 * @param {string} name - The name of the permission type.
+	 * @param {string} template - The negated template, such as "does not have role %s". Every occurrence of %s is replaced with the permission.
+	 * @returns {error} if something goes wrong, or nil if no error occurs.
+	 */
+	SetTypeNegatedTemplate(name string, template string) error
+
 	/**
 	 * Gets all defined permission types.
 	 * @returns {map[string]func(string, map[string]interface{}) (bool, error)} permission types with the structure {"name": callback, "name2": callback2, ...}. This map is shallow copied.
@@ -61,17 +109,416 @@ type LogicalPermissionsInterface interface {
 	GetBypassCallback() func(map[string]interface{}) (bool, error)
 
 	/**
-	 * Sets the bypass access callback.
+	 * Sets the bypass access callback. The cached results of the bypass callback and the cached decisions that depend on it are removed.
 	 * @param {func(map[string]interface{}) (bool, error)} callback - The callback that evaluates access bypassing. Upon calling CheckAccess() the registered bypass callback will be passed one parameter, which is the context map passed to CheckAccess(). It should return a boolean which determines whether bypass access should be granted. It should also return an error, or nil if no error occurred.
 	 */
 	SetBypassCallback(callback func(map[string]interface{}) (bool, error))
 
+	/**
+	 * Adds a named bypass, which grants bypass access like the bypass callback, but only to permissions that accept it with a NO_BYPASS slice.
+	 * @param {string} name - The name of the bypass. It cannot be "default", which is the name of the bypass callback, or "*", and it cannot start with "!".
+	 * @param {func(map[string]interface{}) (bool, error)} callback - The callback that evaluates the bypass. Upon calling CheckAccess() it will be passed the context map passed to CheckAccess(). It should return a boolean which determines whether bypass access should be granted. It should also return an error, or nil if no error occurred.
+	 * @returns {error} if something goes wrong, or nil if no error occurs.
+	 */
+	AddBypass(name string, callback func(map[string]interface{}) (bool, error)) error
+
+	/**
+	 * Removes a named bypass.
+	 * @param {string} name - The name of the bypass.
+	 * @returns {error} if something goes wrong, or nil if no error occurs.
+	 */
+	RemoveBypass(name string) error
+
+	/**
+	 * Checks whether a named bypass is registered.
+	 * @param {string} name - The name of the bypass.
+	 * @returns {bool} true if the bypass is found or false if the bypass isn't found.
+	 * @returns {error} if something goes wrong, or nil if no error occurs.
+	 */
+	BypassExists(name string) (bool, error)
+
+	/**
+	 * Gets all named bypasses.
+	 * @returns {map[string]func(map[string]interface{}) (bool, error)} named bypasses with the structure {"name": callback, "name2": callback2, ...}. This map is shallow copied.
+	 */
+	GetBypasses() map[string]func(map[string]interface{}) (bool, error)
+
+	/**
+	 * Registers an observer that is notified about every access check, bypass callback and permission type callback. Observers should be registered before access is checked.
+	 * @param {Observer} observer - The observer.
+	 */
+	AddObserver(observer Observer)
+
+	/**
+	 * Gets all registered observers.
+	 * @returns {[]Observer} the registered observers in the order they are notified. This slice is shallow copied.
+	 */
+	GetObservers() []Observer
+
+	/**
+	 * Overwrites all registered observers.
+	 * @param {[]Observer} observers - The observers in the order they are notified. This slice is shallow copied.
+	 */
+	SetObservers(observers []Observer)
+
+	/**
+	 * Gets the observer that is notified about evaluations of candidate permission trees in shadow mode.
+	 * @returns {ShadowObserver} the shadow observer, or nil if none is set.
+	 */
+	GetShadowObserver() ShadowObserver
+
+	/**
+	 * Sets the observer that is notified about evaluations of candidate permission trees in shadow mode. Candidate permission trees are only evaluated if a shadow observer is set.
+	 * @param {ShadowObserver} observer - The shadow observer. It is called from other goroutines and must be safe for concurrent use.
+	 */
+	SetShadowObserver(observer ShadowObserver)
+
+	/**
+	 * Gets the maximum number of candidate permission trees that are evaluated in shadow mode at the same time.
+	 * @returns {int} the maximum number of concurrent candidate evaluations. The default is DefaultMaxShadowChecks.
+	 */
+	GetMaxShadowChecks() int
+
+	/**
+	 * Sets the maximum number of candidate permission trees that are evaluated in shadow mode at the same time. While the maximum is reached, further candidates are dropped without being evaluated, and the live result is returned as usual.
+	 * @param {int} max_shadow_checks - The maximum number of concurrent candidate evaluations, or 0 for DefaultMaxShadowChecks.
+	 * @returns {error} if something goes wrong, or nil if no error occurs.
+	 */
+	SetMaxShadowChecks(max_shadow_checks int) error
+
+	/**
+	 * Gets the number of candidate permission trees that have been dropped because the maximum number of concurrent candidate evaluations was reached.
+	 * @returns {uint64} the number of dropped candidate evaluations.
+	 */
+	GetDroppedShadowChecks() uint64
+
+	/**
+	 * Gets the metrics collector.
+	 * @returns {*Metrics} the metrics collector, or nil if none is set.
+	 */
+	GetMetrics() *Metrics
+
+	/**
+	 * Sets the metrics collector that measures access checks, permission type callbacks and logic gates.
+	 * @param {*Metrics} metrics - The metrics collector, or nil in order to stop collecting metrics.
+	 */
+	SetMetrics(metrics *Metrics)
+
+	/**
+	 * Gets the tracer.
+	 * @returns {Tracer} the tracer, or nil if none is set.
+	 */
+	GetTracer() Tracer
+
+	/**
+	 * Sets the tracer that starts a span for every access check and every bypass or permission type callback.
+	 * @param {Tracer} tracer - The tracer, or nil in order to stop tracing.
+	 */
+	SetTracer(tracer Tracer)
+
+	/**
+	 * Gets the cache of callback results and decisions.
+	 * @returns {Cache} the cache, or nil if none is set.
+	 */
+	GetCache() Cache
+
+	/**
+	 * Sets the cache of callback results and decisions. Only the results of callbacks that declared their cache keys are cached.
+	 * @param {Cache} cache - The cache, or nil in order to stop caching.
+	 */
+	SetCache(cache Cache)
+
+	/**
+	 * Gets the context keys that the result of a permission type depends on.
+	 * @param {string} name - The name of the permission type.
+	 * @returns {[]string} the context keys, or nil if the results of the permission type are not cached.
+	 * @returns {error} if something goes wrong, or nil if no error occurs.
+	 */
+	GetTypeCacheKeys(name string) ([]string, error)
+
+	/**
+	 * Declares the context keys that the result of a permission type depends on, which makes its results cacheable.
+	 * @param {string} name - The name of the permission type.
+	 * @param {[]string} context_keys - The context keys, where nested keys are separated by dots, such as "user.id". An empty slice means that the result does not depend on the context, and nil means that the results are not cached.
+	 * @returns {error} if something goes wrong, or nil if no error occurs.
+	 */
+	SetTypeCacheKeys(name string, context_keys []string) error
+
+	/**
+	 * Gets the context keys that the result of the bypass callback depends on.
+	 * @returns {[]string} the context keys, or nil if the results of the bypass callback are not cached.
+	 */
+	GetBypassCacheKeys() []string
+
+	/**
+	 * Declares the context keys that the result of the bypass callback depends on, which makes its results cacheable.
+	 * @param {[]string} context_keys - The context keys, where nested keys are separated by dots. An empty slice means that the result does not depend on the context, and nil means that the results are not cached.
+	 */
+	SetBypassCacheKeys(context_keys []string)
+
+	/**
+	 * Gets whether whole access decisions are cached.
+	 * @returns {bool} true if decisions are cached.
+	 */
+	GetCacheDecisions() bool
+
+	/**
+	 * Sets whether whole access decisions are cached. A decision is only cached if every callback that it may depend on declared its cache keys.
+	 * @param {bool} cache_decisions - true in order to cache decisions.
+	 */
+	SetCacheDecisions(cache_decisions bool)
+
+	/**
+	 * Removes the cached results of a permission type and the cached decisions that depend on it.
+	 * @param {string} name - The name of the permission type.
+	 */
+	InvalidateCacheType(name string)
+
+	/**
+	 * Removes the cached results of the bypass callback and the cached decisions that depend on it.
+	 */
+	InvalidateCacheBypass()
+
+	/**
+	 * Removes the cached results and decisions that depend on a value of a context key, for example when a user changes.
+	 * @param {string} context_key - The context key, as declared with SetTypeCacheKeys() or SetBypassCacheKeys().
+	 * @param {interface{}} value - The value of the context key.
+	 */
+	InvalidateCacheSubject(context_key string, value interface{})
+
+	/**
+	 * Gets the maximum number of goroutines that evaluate the children of AND, OR and XOR gates at the same time during an access check.
+	 * @returns {int} the parallelism, where 0 and 1 mean that gates are evaluated sequentially.
+	 */
+	GetParallelism() int
+
+	/**
+	 * Sets the maximum number of goroutines that evaluate the children of AND, OR and XOR gates at the same time during an access check, including the goroutine of the access check. The results are the same as those of a sequential evaluation, and children that have not been evaluated when the result of a gate is decided are cancelled. Callbacks and observers must be safe for concurrent use.
+	 * @param {int} parallelism - The parallelism, where 0 and 1 mean that gates are evaluated sequentially.
+	 * @returns {error} if something goes wrong, or nil if no error occurs.
+	 */
+	SetParallelism(parallelism int) error
+
+	/**
+	 * Gets the estimated cost of a permission of a permission type, which is the declared cost, the learned average latency or DefaultTypeCost.
+	 * @param {string} name - The name of the permission type.
+	 * @returns {float64} the estimated cost in microseconds.
+	 * @returns {error} if something goes wrong, or nil if no error occurs.
+	 */
+	GetTypeCost(name string) (float64, error)
+
+	/**
+	 * Declares the cost of a permission of a permission type, which takes precedence over the learned average latency.
+	 * @param {string} name - The name of the permission type.
+	 * @param {float64} cost - The cost in microseconds, which cannot be negative.
+	 * @returns {error} if something goes wrong, or nil if no error occurs.
+	 */
+	SetTypeCost(name string, cost float64) error
+
+	/**
+	 * Gets whether the average latency and the share of granted results of each permission type are learned from its callback calls.
+	 * @returns {bool} whether type costs are learned.
+	 */
+	GetLearnTypeCosts() bool
+
+	/**
+	 * Sets whether the average latency and the share of granted results of each permission type are learned from its callback calls. Disabling learning discards the learned costs.
+	 * @param {bool} learn - Whether type costs are learned.
+	 */
+	SetLearnTypeCosts(learn bool)
+
+	/**
+	 * Gets whether the children of AND, OR and XOR gates are evaluated in the order of their estimated cost and likelihood of deciding the gate.
+	 * @returns {bool} whether the order is optimized.
+	 */
+	GetOptimizeOrder() bool
+
+	/**
+	 * Sets whether the children of AND, OR and XOR gates are evaluated in the order of their estimated cost and likelihood of deciding the gate. The order is only optimized while callback errors cannot abort the access check, that is if the access check continues on errors or if every permission type in the permission tree has an error policy other than ErrorPolicyAbort, and the result is then the same as with the authored order. With the default settings callback errors abort the access check, so enabling this has no effect until SetContinueOnError() is enabled or error policies other than ErrorPolicyAbort are set.
+	 * @param {bool} optimize_order - Whether the order is optimized.
+	 */
+	SetOptimizeOrder(optimize_order bool)
+
+	/**
+	 * Gets whether permissions are evaluated in three-valued mode, where callbacks may return ErrUnknown.
+	 * @returns {bool} whether three-valued mode is enabled.
+	 */
+	GetThreeValued() bool
+
+	/**
+	 * Sets whether permissions are evaluated in three-valued mode. In three-valued mode a callback that returns the error ErrUnknown makes its permission unknown instead of failing the access check, and unknown results propagate through the gates according to Kleene logic. Access checks that return a bool deny access if the result is unknown.
+	 * @param {bool} three_valued - Whether three-valued mode is enabled.
+	 */
+	SetThreeValued(three_valued bool)
+
+	/**
+	 * Gets the global error policy, which decides how an error returned by the callback of a permission type is handled.
+	 * @returns {ErrorPolicy} the global error policy.
+	 */
+	GetErrorPolicy() ErrorPolicy
+
+	/**
+	 * Sets the global error policy, which applies to every permission type that does not have its own error policy. The default policy ErrorPolicyAbort aborts the access check and returns the error.
+	 * @param {ErrorPolicy} policy - ErrorPolicyAbort, ErrorPolicyFalse, ErrorPolicyTrue or ErrorPolicyUnknown.
+	 * @returns {error} if the policy is not valid, or nil if no error occurs.
+	 */
+	SetErrorPolicy(policy ErrorPolicy) error
+
+	/**
+	 * Gets the error policy of a permission type, which is the global error policy unless the type has its own.
+	 * @param {string} name - The name of the permission type.
+	 * @returns {ErrorPolicy} the error policy of the permission type.
+	 * @returns {error} if something goes wrong, or nil if no error occurs.
+	 */
+	GetTypeErrorPolicy(name string) (ErrorPolicy, error)
+
+	/**
+	 * Sets the error policy of a permission type, which takes precedence over the global error policy.
+	 * @param {string} name - The name of the permission type.
+	 * @param {ErrorPolicy} policy - ErrorPolicyAbort, ErrorPolicyFalse, ErrorPolicyTrue or ErrorPolicyUnknown.
+	 * @returns {error} if something goes wrong, or nil if no error occurs.
+	 */
+	SetTypeErrorPolicy(name string, policy ErrorPolicy) error
+
+	/**
+	 * Gets whether access checks continue evaluating other permissions after a callback error.
+	 * @returns {bool} whether access checks continue on errors.
+	 */
+	GetContinueOnError() bool
+
+	/**
+	 * Sets whether access checks continue evaluating other permissions after a callback error that would abort the access check. The result of the failed permission is then unknown, and the error is only returned if it affects the result of the access check.
+	 * @param {bool} continue_on_error - Whether access checks continue on errors.
+	 */
+	SetContinueOnError(continue_on_error bool)
+
+	/**
+	 * Gets whether panics in callbacks are recovered.
+	 * @returns {bool} whether panics are recovered.
+	 */
+	GetRecoverPanics() bool
+
+	/**
+	 * Sets whether panics in callbacks are recovered. A recovered panic is returned as a CallbackPanicError, which is handled like any other callback error.
+	 * @param {bool} recover_panics - Whether panics are recovered.
+	 */
+	SetRecoverPanics(recover_panics bool)
+
+	/**
+	 * Adds a permission type with type options, which limit the duration, the concurrent calls and the consecutive errors of its callback.
+	 * @param {string} name - The name of the permission type.
+	 * @param {func(string, map[string]interface{}) (bool, error)} callback - The callback that evaluates the permission type.
+	 * @param {TypeOptions} options - The type options of the permission type.
+	 * @returns {error} if something goes wrong, or nil if no error occurs.
+	 */
+	AddTypeWithOptions(name string, callback func(string, map[string]interface{}) (bool, error), options TypeOptions) error
+
+	/**
+	 * Gets the type options of a permission type.
+	 * @param {string} name - The name of the permission type.
+	 * @returns {TypeOptions} the type options of the permission type, which are zero if it has none.
+	 * @returns {error} if something goes wrong, or nil if no error occurs.
+	 */
+	GetTypeOptions(name string) (TypeOptions, error)
+
+	/**
+	 * Sets the type options of a permission type, which also resets its circuit breaker. Zero type options remove the limits.
+	 * @param {string} name - The name of the permission type.
+	 * @param {TypeOptions} options - The type options of the permission type.
+	 * @returns {error} if something goes wrong, or nil if no error occurs.
+	 */
+	SetTypeOptions(name string, options TypeOptions) error
+
+	/**
+	 * Gets the policy limits, which limit the permission trees that are evaluated.
+	 * @returns {PolicyLimits} the policy limits.
+	 */
+	GetPolicyLimits() PolicyLimits
+
+	/**
+	 * Sets the policy limits, which limit the depth, the node count and the input size of the permission trees that are evaluated, and the callback calls of access checks. A zero value disables a limit.
+	 * @param {PolicyLimits} limits - The policy limits.
+	 * @returns {error} if a limit is negative, or nil if no error occurs.
+	 */
+	SetPolicyLimits(limits PolicyLimits) error
+
+	/**
+	 * Gets whether permission trees are parsed in strict mode.
+	 * @returns {bool} whether strict mode is enabled.
+	 */
+	GetStrict() bool
+
+	/**
+	 * Sets whether permission trees are parsed in strict mode, which rejects lowercase core keys, numeric keys, maps with more than one key that are not the value of a gate, and JSON objects with duplicate keys.
+	 * @param {bool} strict - Whether strict mode is enabled.
+	 */
+	SetStrict(strict bool)
+
 	/**
 	 * Gets all keys that can be part of a permission tree.
 	 * @returns []string valid permission keys
 	 */
 	GetValidPermissionKeys() []string
 
+	/**
+	 * Gets a JSON Schema (draft-07) document describing the permission format for the currently registered permission types.
+	 * @returns {map[string]interface{}} the JSON Schema document, which can be serialized with json.Marshal().
+	 */
+	GetJSONSchema() map[string]interface{}
+
+	/**
+	 * Finds problems in a permission tree without evaluating it. Besides invalid permissions, which are reported with the severity "error", this includes smells such as XOR gates with constant children, double negations, duplicate children and unreachable children of OR gates, NO_BYPASS set to FALSE or to an empty slice, and permission types that differ only by case. Names of unregistered bypasses in NO_BYPASS slices are reported with the severity "error" and the rule "unregistered-bypass".
+	 * @param {interface{}} permissions - The permission tree to be checked. The permission tree can either be a map[string]interface{} or a string containing a json object. It also accepts a slice, a boolean string or a real boolean.
+	 * @returns {[]Diagnostic} the problems that were found, sorted by their location in the permission tree.
+	 */
+	Lint(permissions interface{}) []Diagnostic
+
+	/**
+	 * Rewrites a permission tree in canonical json form. Core keys are uppercased, including the legacy no_bypass key, keys are sorted with NO_BYPASS first and the json is indented with two spaces.
+	 * @param {interface{}} permissions - The permission tree to be formatted. The permission tree can either be a map[string]interface{} or a string containing a json object. It also accepts a slice, a boolean string or a real boolean.
+	 * @returns {string} the formatted permission tree, terminated by a newline.
+	 * @returns {error} if something goes wrong, or nil if no error occurs.
+	 */
+	Format(permissions interface{}) (string, error)
+
+	/**
+	 * Renders a permission tree as a Graphviz DOT graph.
+	 * @param {interface{}} permissions - The permission tree to be rendered. The permission tree can either be a map[string]interface{} or a string containing a json object. It also accepts a slice, a boolean string or a real boolean.
+	 * @param {*Trace} trace - An optional trace from CheckAccessWithTrace() for the same permission tree. If it is not nil, the nodes are colored according to whether they were skipped or evaluated to true or false.
+	 * @returns {string} the DOT graph.
+	 * @returns {error} if something goes wrong, or nil if no error occurs.
+	 */
+	ExportDOT(permissions interface{}, trace *Trace) (string, error)
+
+	/**
+	 * Renders a permission tree as a Mermaid flowchart.
+	 * @param {interface{}} permissions - The permission tree to be rendered. The permission tree can either be a map[string]interface{} or a string containing a json object. It also accepts a slice, a boolean string or a real boolean.
+	 * @param {*Trace} trace - An optional trace from CheckAccessWithTrace() for the same permission tree. If it is not nil, the nodes are colored according to whether they were skipped or evaluated to true or false.
+	 * @returns {string} the Mermaid flowchart.
+	 * @returns {error} if something goes wrong, or nil if no error occurs.
+	 */
+	ExportMermaid(permissions interface{}, trace *Trace) (string, error)
+
+	/**
+	 * Gets the phrasebook that is used by Describe().
+	 * @returns {Phrasebook} the current phrasebook. Defaults to EnglishPhrasebook.
+	 */
+	GetPhrasebook() Phrasebook
+
+	/**
+	 * Sets the phrasebook that is used by Describe(), for example in order to translate descriptions into other languages.
+	 * @param {Phrasebook} phrasebook - The phrasebook. Pass nil to restore the default EnglishPhrasebook.
+	 */
+	SetPhrasebook(phrasebook Phrasebook)
+
+	/**
+	 * Describes a permission tree in natural language, such as "Requires role admin, and either the beta flag or not being a guest."
+	 * @param {interface{}} permissions - The permission tree to be described. The permission tree can either be a map[string]interface{} or a string containing a json object. It also accepts a slice, a boolean string or a real boolean.
+	 * @returns {string} the description.
+	 * @returns {error} if something goes wrong, or nil if no error occurs.
+	 */
+	Describe(permissions interface{}) (string, error)
+
 	/**
 	 * Checks access for a permission tree.
 	 * @param {interface{}} permissions - The permission tree to be evaluated. The permission tree can either be a map[string]interface{} or a string containing a json object. It also accepts a slice, a boolean string or a real boolean.
@@ -89,4 +536,76 @@ type LogicalPermissionsInterface interface {
 	 * @returns {error} if something goes wrong, or nil if no error occurs.
 	 */
 	CheckAccessNoBypass(permissions interface{}, context map[string]interface{}) (bool, error)
+
+	/**
+	 * Checks access for a permission tree and explains how the decision was reached.
+	 * @param {interface{}} permissions - The permission tree to be evaluated. The permission tree can either be a map[string]interface{} or a string containing a json object. It also accepts a slice, a boolean string or a real boolean.
+	 * @param {map[string]interface{}} context - A context map that could for example contain the evaluated user and document.
+	 * @returns {bool} true if access is granted or false if access is denied.
+	 * @returns {*Trace} the evaluated nodes of the permission tree together with the outcome of the bypass check. Nodes that were skipped because of short-circuiting are not included.
+	 * @returns {error} if something goes wrong, or nil if no error occurs.
+	 */
+	CheckAccessWithTrace(permissions interface{}, context map[string]interface{}) (bool, *Trace, error)
+
+	/**
+	 * Checks access for a permission tree while explicitly disallowing access bypass, and explains how the decision was reached.
+	 * @param {interface{}} permissions - The permission tree to be evaluated. The permission tree can either be a map[string]interface{} or a string containing a json object. It also accepts a slice, a boolean string or a real boolean.
+	 * @param {map[string]interface{}} context - A context map that could for example contain the evaluated user and document.
+	 * @returns {bool} true if access is granted or false if access is denied.
+	 * @returns {*Trace} the evaluated nodes of the permission tree. Nodes that were skipped because of short-circuiting are not included.
+	 * @returns {error} if something goes wrong, or nil if no error occurs.
+	 */
+	CheckAccessNoBypassWithTrace(permissions interface{}, context map[string]interface{}) (bool, *Trace, error)
+
+	/**
+	 * Checks access for a permission tree and returns the three-valued result, so that the caller can decide whether an unknown result grants access.
+	 * @param {interface{}} permissions - The permission tree to be evaluated.
+	 * @param {map[string]interface{}} context - A context map that could for example contain the evaluated user and document.
+	 * @returns {TriState} TriStateTrue if access is granted, TriStateFalse if access is denied or TriStateUnknown if the result is unknown in three-valued mode.
+	 * @returns {error} if something goes wrong, or nil if no error occurs.
+	 */
+	CheckAccessTriState(permissions interface{}, context map[string]interface{}) (TriState, error)
+
+	/**
+	 * Checks access for a permission tree while explicitly disallowing access bypass, and returns the three-valued result.
+	 * @param {interface{}} permissions - The permission tree to be evaluated.
+	 * @param {map[string]interface{}} context - A context map that could for example contain the evaluated user and document.
+	 * @returns {TriState} TriStateTrue if access is granted, TriStateFalse if access is denied or TriStateUnknown if the result is unknown in three-valued mode.
+	 * @returns {error} if something goes wrong, or nil if no error occurs.
+	 */
+	CheckAccessNoBypassTriState(permissions interface{}, context map[string]interface{}) (TriState, error)
+
+	/**
+	 * Checks access for a live permission tree and evaluates a candidate permission tree in the background. The candidate reuses the callback results of the live evaluation, and its result is reported to the shadow observer.
+	 * @param {interface{}} live - The permission tree that decides access.
+	 * @param {interface{}} candidate - The permission tree that is evaluated in shadow mode. Its result and errors never affect the returned values.
+	 * @param {map[string]interface{}} context - A context map that could for example contain the evaluated user and document. The candidate is evaluated with a copy of its maps and slices, so they may be modified after the call, but other values such as pointers are shared and must not be modified.
+	 * @returns {bool} true if access is granted by the live permission tree or false if access is denied.
+	 * @returns {error} if something goes wrong in the live evaluation, or nil if no error occurs.
+	 */
+	CheckAccessShadow(live interface{}, candidate interface{}, context map[string]interface{}) (bool, error)
+
+	/**
+	 * Checks access for a live permission tree while explicitly disallowing access bypass, and evaluates a candidate permission tree in the background in the same way.
+	 * @param {interface{}} live - The permission tree that decides access.
+	 * @param {interface{}} candidate - The permission tree that is evaluated in shadow mode. Its result and errors never affect the returned values.
+	 * @param {map[string]interface{}} context - A context map that could for example contain the evaluated user and document. The candidate is evaluated with a copy of its maps and slices, so they may be modified after the call, but other values such as pointers are shared and must not be modified.
+	 * @returns {bool} true if access is granted by the live permission tree or false if access is denied.
+	 * @returns {error} if something goes wrong in the live evaluation, or nil if no error occurs.
+	 */
+	CheckAccessNoBypassShadow(live interface{}, candidate interface{}, context map[string]interface{}) (bool, error)
+
+	/**
+	 * Creates an evaluation session that memoizes callback results across the access checks of a single request.
+	 * @returns {*Session} the session, which has the methods CheckAccess(), CheckAccessNoBypass(), CheckAccessWithTrace(), CheckAccessNoBypassWithTrace() and Reset().
+	 */
+	NewSession() *Session
+
+	/**
+	 * Evaluates candidate permission trees against recorded decisions with the recorded callback results, and with the error policies, continuing on errors, strict mode, three-valued mode and policy limits of this LogicalPermissions.
+	 * @param {[]*DecisionRecord} records - The recorded decisions, as returned by ReadDecisionRecords().
+	 * @param {map[string]interface{}} candidates - The candidate permission trees, keyed by policy ID. Records without a matching candidate are skipped.
+	 * @returns {*ReplayReport} the report of unchanged, changed and inconclusive decisions.
+	 */
+	ReplayDecisions(records []*DecisionRecord, candidates map[string]interface{}) *ReplayReport
 }